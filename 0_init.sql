@@ -27,10 +27,35 @@
 
 BEGIN;
 
+DROP TABLE IF EXISTS stock_count_lines CASCADE;
+DROP TABLE IF EXISTS stock_counts CASCADE;
+DROP TABLE IF EXISTS stock_ledger CASCADE;
+DROP TABLE IF EXISTS accounting_periods CASCADE;
+DROP TABLE IF EXISTS supplier_payments CASCADE;
+DROP TABLE IF EXISTS invoice_lines CASCADE;
+DROP TABLE IF EXISTS supplier_invoices CASCADE;
+DROP TABLE IF EXISTS supplier_returns CASCADE;
+DROP TABLE IF EXISTS delivery_inspections CASCADE;
+DROP TABLE IF EXISTS delivery_lots CASCADE;
+DROP TABLE IF EXISTS transfer_lines CASCADE;
+DROP TABLE IF EXISTS transfers CASCADE;
+DROP TABLE IF EXISTS issues CASCADE;
 DROP TABLE IF EXISTS deliveries CASCADE;
 DROP TABLE IF EXISTS contracts CASCADE;
 DROP TABLE IF EXISTS warehouses CASCADE;
+DROP TABLE IF EXISTS parts CASCADE;
+DROP TABLE IF EXISTS contract_headers CASCADE;
+DROP TABLE IF EXISTS suppliers CASCADE;
 DROP TABLE IF EXISTS proc_result CASCADE;
+DROP TABLE IF EXISTS key_changes CASCADE;
+DROP TABLE IF EXISTS contract_versions CASCADE;
+DROP TABLE IF EXISTS contract_amendments CASCADE;
+DROP TABLE IF EXISTS contract_schedules CASCADE;
+DROP TABLE IF EXISTS contract_penalty_terms CASCADE;
+DROP TABLE IF EXISTS exchange_rates CASCADE;
+DROP TABLE IF EXISTS budgets CASCADE;
+DROP TABLE IF EXISTS contract_assignment_events CASCADE;
+DROP TABLE IF EXISTS contract_assignments CASCADE;
 
 DROP FUNCTION IF EXISTS fn_cascade_delete_deliveries() CASCADE;
 DROP TRIGGER IF EXISTS trg_deliveries_after_insert ON deliveries;
@@ -38,7 +63,54 @@ DROP TRIGGER IF EXISTS trg_deliveries_after_insert ON deliveries;
 DROP FUNCTION IF EXISTS fn_check_received_date() CASCADE;
 DROP TRIGGER IF EXISTS trg_check_received_date ON deliveries;
 
+DROP FUNCTION IF EXISTS fn_check_live_references() CASCADE;
+DROP TRIGGER IF EXISTS trg_check_live_references ON deliveries;
+
+DROP FUNCTION IF EXISTS fn_contract_versioning() CASCADE;
+DROP TRIGGER IF EXISTS trg_contract_versioning ON contracts;
+
 DROP PROCEDURE IF EXISTS p_contract_summary(INT, TEXT, OUT DECIMAL(10,2), OUT DECIMAL(10,2));
+DROP PROCEDURE IF EXISTS p_contract_summary(INT, TEXT, DATE, OUT DECIMAL(10,2), OUT DECIMAL(10,2));
+DROP FUNCTION IF EXISTS fn_contract_version_at(INT, TEXT, DATE);
+DROP FUNCTION IF EXISTS fn_exchange_rate(TEXT, DATE);
+DROP FUNCTION IF EXISTS fn_full_deliveries_as_of(DATE);
+
+DROP VIEW IF EXISTS stock_balance CASCADE;
+DROP VIEW IF EXISTS stock_count_variances CASCADE;
+DROP FUNCTION IF EXISTS fn_supplier_scorecard(DATE, DATE);
+DROP FUNCTION IF EXISTS fn_supplier_line_performance(DATE, DATE);
+DROP FUNCTION IF EXISTS fn_schedule_priority(DATE);
+DROP FUNCTION IF EXISTS fn_contract_queue(DATE, DECIMAL, DECIMAL, DECIMAL, DECIMAL, DECIMAL);
+DROP FUNCTION IF EXISTS fn_log_assignment() CASCADE;
+DROP FUNCTION IF EXISTS fn_tranche_status(DATE);
+DROP FUNCTION IF EXISTS fn_penalty_claims(DATE);
+DROP FUNCTION IF EXISTS fn_penalty_events(DATE);
+DROP VIEW IF EXISTS invoice_matching CASCADE;
+DROP FUNCTION IF EXISTS fn_ap_aging(DATE);
+DROP FUNCTION IF EXISTS fn_abc_xyz(DATE, DATE, DECIMAL, DECIMAL, DECIMAL, DECIMAL);
+DROP VIEW IF EXISTS budget_usage CASCADE;
+DROP VIEW IF EXISTS commitment_by_month CASCADE;
+DROP VIEW IF EXISTS stock_movements CASCADE;
+DROP FUNCTION IF EXISTS fn_check_transfer_stock() CASCADE;
+DROP TRIGGER IF EXISTS trg_check_transfer_stock ON transfer_lines;
+DROP FUNCTION IF EXISTS fn_period_is_closed(DATE);
+DROP FUNCTION IF EXISTS fn_check_closed_period() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_append_only() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_check_period() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_post_delivery() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_post_inspection() CASCADE;
+DROP FUNCTION IF EXISTS fn_check_inspected_delivery() CASCADE;
+DROP FUNCTION IF EXISTS fn_check_supplier_return() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_post_issue() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_post_transfer_line() CASCADE;
+DROP FUNCTION IF EXISTS fn_ledger_post_transfer_receipt() CASCADE;
+DROP FUNCTION IF EXISTS fn_stock_balance(INT, TEXT, TEXT);
+DROP VIEW IF EXISTS lot_balance CASCADE;
+DROP FUNCTION IF EXISTS fn_lot_balance(INT, INT, INT, TEXT);
+DROP FUNCTION IF EXISTS fn_fefo_pick(INT, TEXT, TEXT, DECIMAL, DATE);
+DROP FUNCTION IF EXISTS fn_check_issue_stock() CASCADE;
+DROP FUNCTION IF EXISTS fn_check_lot_stock(INT, INT, INT, TEXT, TEXT, TEXT, DECIMAL);
+DROP TRIGGER IF EXISTS trg_check_issue_stock ON issues;
 DROP FUNCTION IF EXISTS fn_warehouse_count(fn_manager_surname text);
 DROP FUNCTION IF EXISTS fn_deliveries_in_range(DATE, DATE);
 DROP VIEW IF EXISTS full_deliveries_view CASCADE;
@@ -47,7 +119,39 @@ DROP VIEW IF EXISTS full_deliveries_view CASCADE;
 -- Склады
 CREATE TABLE warehouses (
     warehouse_no         INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-    manager_surname      TEXT NOT NULL 
+    manager_surname      TEXT NOT NULL,
+    deleted_at           TIMESTAMPTZ
+);
+
+-- Справочник деталей
+CREATE TABLE parts (
+    part_code            TEXT PRIMARY KEY CHECK (btrim(part_code) <> ''),
+    name                 TEXT NOT NULL,
+    description          TEXT,
+    default_unit         TEXT NOT NULL CHECK (default_unit IN ('pcs','kg','m','set')),
+    weight_per_piece     DECIMAL(10,3) CHECK (weight_per_piece > 0),
+    category             TEXT
+);
+
+-- Поставщики (контрагенты по договорам)
+CREATE TABLE suppliers (
+    supplier_id          INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name                 TEXT NOT NULL,
+    inn                  TEXT NOT NULL UNIQUE CHECK (inn ~ '^([0-9]{10}|[0-9]{12})$'),
+    address              TEXT,
+    contact_person       TEXT,
+    phone                TEXT,
+    email                TEXT
+);
+
+-- Шапка договора: контрагент, дата подписания, статус. Строки по деталям - в contracts
+CREATE TABLE contract_headers (
+    contract_no          INT PRIMARY KEY,
+    supplier_id          INT NOT NULL,
+    signed_date          DATE NOT NULL,
+    status               TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('draft','active','closed','terminated')),
+    CONSTRAINT fk_header_supplier FOREIGN KEY (supplier_id)
+        REFERENCES suppliers(supplier_id)
 );
 
 -- Договорные поставки деталей 
@@ -59,8 +163,14 @@ CREATE TABLE contracts (
     end_date             DATE NOT NULL,
     plan_qty             DECIMAL(10,2) NOT NULL CHECK (plan_qty > 0),
     contract_price       DECIMAL(10,2) NOT NULL CHECK (contract_price >= 0),
+    currency             CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+    deleted_at           TIMESTAMPTZ,
     PRIMARY KEY (contract_no, part_code),
-    CONSTRAINT chk_dates CHECK (start_date < end_date)
+    CONSTRAINT chk_dates CHECK (start_date < end_date),
+    CONSTRAINT fk_contract_part FOREIGN KEY (part_code)
+        REFERENCES parts(part_code),
+    CONSTRAINT fk_contract_header FOREIGN KEY (contract_no)
+        REFERENCES contract_headers(contract_no)
 );
 -- Учет поставок деталей
 CREATE TABLE deliveries (
@@ -71,14 +181,459 @@ CREATE TABLE deliveries (
     unit                 TEXT NOT NULL CHECK (unit IN ('pcs','kg','m','set')),
     qty                  DECIMAL(10,2) NOT NULL CHECK (qty > 0),
     received_date        DATE NOT NULL DEFAULT CURRENT_DATE,
+    deleted_at           TIMESTAMPTZ,
     PRIMARY KEY (warehouse_no, receipt_doc_no),
     CONSTRAINT fk_delivery_warehouse FOREIGN KEY (warehouse_no)
         REFERENCES warehouses(warehouse_no)
         ON DELETE CASCADE ON UPDATE CASCADE,
     CONSTRAINT fk_delivery_contract FOREIGN KEY (contract_no, part_code) 
         REFERENCES contracts(contract_no, part_code)
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_delivery_part FOREIGN KEY (part_code)
+        REFERENCES parts(part_code)
+);
+
+-- Входной контроль поставки: принятое и забракованное количество.
+-- Забракованное на склад не приходуется и в исполнение договора не идёт
+CREATE TABLE delivery_inspections (
+    warehouse_no         INT NOT NULL,
+    receipt_doc_no       INT NOT NULL,
+    accepted_qty         DECIMAL(10,2) NOT NULL CHECK (accepted_qty >= 0),
+    rejected_qty         DECIMAL(10,2) NOT NULL CHECK (rejected_qty >= 0),
+    reason               TEXT,
+    inspector            TEXT NOT NULL,
+    inspected_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (warehouse_no, receipt_doc_no),
+    CONSTRAINT fk_inspection_delivery FOREIGN KEY (warehouse_no, receipt_doc_no)
+        REFERENCES deliveries(warehouse_no, receipt_doc_no)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    CHECK (rejected_qty = 0 OR reason IS NOT NULL)
+);
+
+-- Возврат забракованных деталей поставщику
+CREATE TABLE supplier_returns (
+    return_no            INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    warehouse_no         INT NOT NULL,
+    receipt_doc_no       INT NOT NULL,
+    qty                  DECIMAL(10,2) NOT NULL CHECK (qty > 0),
+    returned_date        DATE NOT NULL,
+    reason               TEXT NOT NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_return_inspection FOREIGN KEY (warehouse_no, receipt_doc_no)
+        REFERENCES delivery_inspections(warehouse_no, receipt_doc_no)
+        ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Счета поставщиков
+CREATE TABLE supplier_invoices (
+    invoice_id           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    supplier_id          INT NOT NULL REFERENCES suppliers(supplier_id),
+    invoice_no           TEXT NOT NULL CHECK (btrim(invoice_no) <> ''),
+    invoice_date         DATE NOT NULL,
+    due_date             DATE NOT NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (supplier_id, invoice_no),
+    CONSTRAINT chk_invoice_dates CHECK (due_date >= invoice_date)
+);
+
+-- Строки счёта: каждая выставлена за одну поставку, поставка - не более чем
+-- в одном счёте. После окончательного удаления поставки ссылка обнуляется
+CREATE TABLE invoice_lines (
+    invoice_id           INT NOT NULL REFERENCES supplier_invoices(invoice_id) ON DELETE CASCADE,
+    line_no              INT NOT NULL,
+    warehouse_no         INT,
+    receipt_doc_no       INT,
+    qty                  DECIMAL(10,2) NOT NULL CHECK (qty > 0),
+    unit_price           DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0),
+    amount               DECIMAL(12,2) GENERATED ALWAYS AS (round(qty * unit_price, 2)) STORED,
+    PRIMARY KEY (invoice_id, line_no),
+    UNIQUE (warehouse_no, receipt_doc_no),
+    CONSTRAINT fk_invoice_line_delivery FOREIGN KEY (warehouse_no, receipt_doc_no)
+        REFERENCES deliveries(warehouse_no, receipt_doc_no)
+        ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+-- Оплаты по счетам
+CREATE TABLE supplier_payments (
+    payment_id           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    invoice_id           INT NOT NULL REFERENCES supplier_invoices(invoice_id),
+    paid_date            DATE NOT NULL,
+    amount               DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    reference            TEXT,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Партии (лоты) в составе поставки: номер партии производителя, даты
+-- изготовления и годности. Партия определяется поставкой и своим номером
+CREATE TABLE delivery_lots (
+    warehouse_no         INT NOT NULL,
+    receipt_doc_no       INT NOT NULL,
+    lot_no               TEXT NOT NULL CHECK (btrim(lot_no) <> ''),
+    qty                  DECIMAL(10,2) NOT NULL CHECK (qty > 0),
+    manufactured_date    DATE NOT NULL,
+    expiry_date          DATE NOT NULL,
+    PRIMARY KEY (warehouse_no, receipt_doc_no, lot_no),
+    CONSTRAINT fk_lot_delivery FOREIGN KEY (warehouse_no, receipt_doc_no)
+        REFERENCES deliveries(warehouse_no, receipt_doc_no)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT chk_lot_dates CHECK (expiry_date >= manufactured_date)
+);
+
+-- Отпуск деталей со склада (расходные документы)
+CREATE TABLE issues (
+    warehouse_no         INT NOT NULL,
+    issue_doc_no         INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    unit                 TEXT NOT NULL CHECK (unit IN ('pcs','kg','m','set')),
+    qty                  DECIMAL(10,2) NOT NULL CHECK (qty > 0),
+    issued_date          DATE NOT NULL DEFAULT CURRENT_DATE,
+    recipient            TEXT NOT NULL,
+    -- партия, из которой отпущено (необязательно)
+    origin_warehouse_no  INT,
+    origin_receipt_doc_no INT,
+    lot_no               TEXT,
+    PRIMARY KEY (warehouse_no, issue_doc_no),
+    CONSTRAINT fk_issue_warehouse FOREIGN KEY (warehouse_no)
+        REFERENCES warehouses(warehouse_no)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_issue_lot FOREIGN KEY (origin_warehouse_no, origin_receipt_doc_no, lot_no)
+        REFERENCES delivery_lots(warehouse_no, receipt_doc_no, lot_no)
+        ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT chk_issue_lot CHECK (
+        (origin_warehouse_no IS NULL) = (origin_receipt_doc_no IS NULL)
+        AND (origin_warehouse_no IS NULL) = (lot_no IS NULL))
+);
+
+-- Перемещение деталей между складами: shipped (списано с источника, в пути) -> received
+CREATE TABLE transfers (
+    transfer_no          INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    from_warehouse_no    INT NOT NULL REFERENCES warehouses(warehouse_no) ON UPDATE CASCADE,
+    to_warehouse_no      INT NOT NULL REFERENCES warehouses(warehouse_no) ON UPDATE CASCADE,
+    status               TEXT NOT NULL DEFAULT 'shipped' CHECK (status IN ('shipped','received')),
+    shipped_date         DATE NOT NULL DEFAULT CURRENT_DATE,
+    received_date        DATE,
+    CONSTRAINT chk_transfer_warehouses CHECK (from_warehouse_no <> to_warehouse_no),
+    CONSTRAINT chk_transfer_received CHECK (
+        (status = 'shipped' AND received_date IS NULL)
+        OR (status = 'received' AND received_date >= shipped_date))
+);
+
+-- Строки перемещения; origin_* - исходная договорная поставка, из которой пришли детали
+CREATE TABLE transfer_lines (
+    transfer_no          INT NOT NULL REFERENCES transfers(transfer_no) ON DELETE CASCADE,
+    line_no              INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    unit                 TEXT NOT NULL CHECK (unit IN ('pcs','kg','m','set')),
+    qty                  DECIMAL(10,2) NOT NULL CHECK (qty > 0),
+    origin_warehouse_no  INT,
+    origin_receipt_doc_no INT,
+    lot_no               TEXT,
+    PRIMARY KEY (transfer_no, line_no),
+    CONSTRAINT fk_transfer_line_origin FOREIGN KEY (origin_warehouse_no, origin_receipt_doc_no)
+        REFERENCES deliveries(warehouse_no, receipt_doc_no)
+        ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT fk_transfer_line_lot FOREIGN KEY (origin_warehouse_no, origin_receipt_doc_no, lot_no)
+        REFERENCES delivery_lots(warehouse_no, receipt_doc_no, lot_no)
+        ON DELETE SET NULL (lot_no) ON UPDATE CASCADE
+);
+
+-- Журнал движений (только добавление). Исправления проводятся сторнирующими
+-- записями: reverses_id указывает на отменяемую запись
+CREATE TABLE stock_ledger (
+    id                   INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    posted_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    movement_date        DATE NOT NULL,
+    warehouse_no         INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    unit                 TEXT NOT NULL,
+    qty                  DECIMAL(10,2) NOT NULL CHECK (qty <> 0),
+    source_type          TEXT NOT NULL CHECK (source_type IN ('delivery','issue','transfer','adjustment')),
+    source_ref           TEXT NOT NULL,
+    reverses_id          INT REFERENCES stock_ledger(id),
+    comment              TEXT
+);
+CREATE INDEX idx_stock_ledger_reverses ON stock_ledger(reverses_id);
+CREATE INDEX idx_stock_ledger_source ON stock_ledger(source_type, source_ref);
+
+-- Закрытые учётные периоды (месяцы)
+CREATE TABLE accounting_periods (
+    period               DATE PRIMARY KEY CHECK (period = date_trunc('month', period)::date),
+    closed_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closed_by            TEXT NOT NULL
+);
+
+-- Инвентаризация: ведомость пересчёта по складу на дату. Фамилия МОЛ
+-- запоминается на момент пересчёта, чтобы история расхождений не менялась
+-- при смене ответственного
+CREATE TABLE stock_counts (
+    count_no             INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    warehouse_no         INT NOT NULL,
+    manager_surname      TEXT NOT NULL,
+    count_date           DATE NOT NULL,
+    status               TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft','approved')),
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    approved_by          TEXT,
+    approved_at          TIMESTAMPTZ,
+    CONSTRAINT fk_count_warehouse FOREIGN KEY (warehouse_no)
+        REFERENCES warehouses(warehouse_no) ON DELETE CASCADE ON UPDATE CASCADE,
+    CHECK ((status = 'approved') = (approved_at IS NOT NULL))
+);
+
+-- Строки ведомости: учётный остаток на дату пересчёта и фактическое количество
+CREATE TABLE stock_count_lines (
+    count_no             INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    unit                 TEXT NOT NULL,
+    expected_qty         DECIMAL(10,2) NOT NULL,
+    counted_qty          DECIMAL(10,2) CHECK (counted_qty >= 0),
+    PRIMARY KEY (count_no, part_code, unit),
+    CONSTRAINT fk_count_line_count FOREIGN KEY (count_no)
+        REFERENCES stock_counts(count_no) ON DELETE CASCADE
+);
+
+-- Журнал изменений ключей (перенос поставки, перенумерация договора)
+CREATE TABLE key_changes (
+    id                   INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    entity               TEXT NOT NULL CHECK (entity IN ('delivery','contract','issue')),
+    old_key              TEXT NOT NULL,
+    new_key              TEXT NOT NULL,
+    changed_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Версии условий договорной поставки, действующие в интервале [valid_from, valid_to)
+CREATE TABLE contract_versions (
+    contract_no          INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    valid_from           DATE NOT NULL,
+    valid_to             DATE,
+    unit                 TEXT NOT NULL,
+    start_date           DATE NOT NULL,
+    end_date             DATE NOT NULL,
+    plan_qty             DECIMAL(10,2) NOT NULL,
+    contract_price       DECIMAL(10,2) NOT NULL,
+    PRIMARY KEY (contract_no, part_code, valid_from),
+    CONSTRAINT chk_version_interval CHECK (valid_to IS NULL OR valid_from < valid_to),
+    CONSTRAINT fk_version_contract FOREIGN KEY (contract_no, part_code)
+        REFERENCES contracts(contract_no, part_code)
+        ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Первая версия действует с начала поставки, каждое изменение условий
+-- закрывает текущую версию и открывает новую с сегодняшнего дня
+CREATE OR REPLACE FUNCTION fn_contract_versioning()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO contract_versions
+            (contract_no, part_code, valid_from, unit, start_date, end_date, plan_qty, contract_price)
+        VALUES
+            (NEW.contract_no, NEW.part_code, LEAST(NEW.start_date, CURRENT_DATE),
+             NEW.unit, NEW.start_date, NEW.end_date, NEW.plan_qty, NEW.contract_price);
+        RETURN NEW;
+    END IF;
+
+    IF (OLD.unit, OLD.start_date, OLD.end_date, OLD.plan_qty, OLD.contract_price)
+       IS NOT DISTINCT FROM
+       (NEW.unit, NEW.start_date, NEW.end_date, NEW.plan_qty, NEW.contract_price) THEN
+        RETURN NEW;
+    END IF;
+
+    -- Повторное изменение в тот же день правит открытую версию
+    UPDATE contract_versions
+    SET unit = NEW.unit, start_date = NEW.start_date, end_date = NEW.end_date,
+        plan_qty = NEW.plan_qty, contract_price = NEW.contract_price
+    WHERE contract_no = NEW.contract_no AND part_code = NEW.part_code
+      AND valid_to IS NULL AND valid_from >= CURRENT_DATE;
+    IF FOUND THEN
+        RETURN NEW;
+    END IF;
+
+    UPDATE contract_versions
+    SET valid_to = CURRENT_DATE
+    WHERE contract_no = NEW.contract_no AND part_code = NEW.part_code
+      AND valid_to IS NULL;
+
+    INSERT INTO contract_versions
+        (contract_no, part_code, valid_from, unit, start_date, end_date, plan_qty, contract_price)
+    VALUES
+        (NEW.contract_no, NEW.part_code, CURRENT_DATE,
+         NEW.unit, NEW.start_date, NEW.end_date, NEW.plan_qty, NEW.contract_price);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_contract_versioning
+AFTER INSERT OR UPDATE ON contracts
+FOR EACH ROW
+EXECUTE FUNCTION fn_contract_versioning();
+
+-- Изменения коммерческих условий договора: draft -> submitted -> approved/rejected.
+-- NULL в new_* означает, что поле не меняется
+CREATE TABLE contract_amendments (
+    id                   INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    contract_no          INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    status               TEXT NOT NULL DEFAULT 'draft'
+                         CHECK (status IN ('draft','submitted','approved','rejected')),
+    new_start_date       DATE,
+    new_end_date         DATE,
+    new_plan_qty         DECIMAL(10,2) CHECK (new_plan_qty > 0),
+    new_contract_price   DECIMAL(10,2) CHECK (new_contract_price >= 0),
+    reason               TEXT NOT NULL,
+    requested_by         TEXT NOT NULL,
+    decided_by           TEXT,
+    decision_comment     TEXT,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    submitted_at         TIMESTAMPTZ,
+    decided_at           TIMESTAMPTZ,
+    CONSTRAINT fk_amendment_contract FOREIGN KEY (contract_no, part_code)
+        REFERENCES contracts(contract_no, part_code)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT chk_amendment_changes CHECK (
+        new_start_date IS NOT NULL OR new_end_date IS NOT NULL
+        OR new_plan_qty IS NOT NULL OR new_contract_price IS NOT NULL)
+);
+
+-- График поставок по строке договора: плановые партии (транши) со сроками.
+-- Сумма траншей равна plan_qty строки на момент сохранения графика
+CREATE TABLE contract_schedules (
+    contract_no          INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    tranche_no           INT NOT NULL CHECK (tranche_no > 0),
+    due_date             DATE NOT NULL,
+    planned_qty          DECIMAL(10,2) NOT NULL CHECK (planned_qty > 0),
+    PRIMARY KEY (contract_no, part_code, tranche_no),
+    CONSTRAINT fk_schedule_contract FOREIGN KEY (contract_no, part_code)
+        REFERENCES contracts(contract_no, part_code)
+        ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Неустойка за просрочку поставки по строке договора: процент от стоимости
+-- просроченного количества за каждый день, но не более cap_pct процентов
+-- от стоимости всей строки
+CREATE TABLE contract_penalty_terms (
+    contract_no          INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    daily_rate_pct       DECIMAL(6,3) NOT NULL CHECK (daily_rate_pct > 0),
+    cap_pct              DECIMAL(5,2) NOT NULL CHECK (cap_pct > 0 AND cap_pct <= 100),
+    PRIMARY KEY (contract_no, part_code),
+    CONSTRAINT fk_penalty_contract FOREIGN KEY (contract_no, part_code)
+        REFERENCES contracts(contract_no, part_code)
+        ON DELETE CASCADE ON UPDATE CASCADE
 );
 
+-- Курсы валют к базовой валюте (RUB): сколько рублей за одну единицу валюты.
+-- Курс действует с rate_date до следующей загруженной даты
+CREATE TABLE exchange_rates (
+    currency             CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$' AND currency <> 'RUB'),
+    rate_date            DATE NOT NULL,
+    rate                 DECIMAL(14,6) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (currency, rate_date)
+);
+
+-- Бюджет закупок на месяц: лимит обязательств в базовой валюте по категории
+-- деталей (NULL - по всем категориям). При превышении warn - договор
+-- сохраняется с предупреждением, block - изменение отклоняется
+CREATE TABLE budgets (
+    budget_id            INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    period_month         DATE NOT NULL CHECK (period_month = date_trunc('month', period_month)::date),
+    category             TEXT,
+    limit_amount         DECIMAL(14,2) NOT NULL CHECK (limit_amount >= 0),
+    enforcement          TEXT NOT NULL DEFAULT 'warn' CHECK (enforcement IN ('warn','block')),
+    UNIQUE NULLS NOT DISTINCT (period_month, category)
+);
+
+-- Закрепление строк договоров за закупщиками для ускорения поставок
+CREATE TABLE contract_assignments (
+    contract_no          INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    buyer                TEXT NOT NULL CHECK (btrim(buyer) <> ''),
+    status               TEXT NOT NULL DEFAULT 'assigned'
+                         CHECK (status IN ('assigned','in_progress','escalated','resolved')),
+    note                 TEXT,
+    assigned_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (contract_no, part_code),
+    CONSTRAINT fk_assignment_contract FOREIGN KEY (contract_no, part_code)
+        REFERENCES contracts(contract_no, part_code)
+        ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- История закрепления: закупщик, статус и комментарий после каждого изменения.
+-- Привязана к строке договора, а не к закреплению: снятие закупщика остаётся
+-- в истории событием со статусом unassigned
+CREATE TABLE contract_assignment_events (
+    event_id             INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    contract_no          INT NOT NULL,
+    part_code            TEXT NOT NULL,
+    buyer                TEXT NOT NULL,
+    status               TEXT NOT NULL,
+    note                 TEXT,
+    changed_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_assignment_event FOREIGN KEY (contract_no, part_code)
+        REFERENCES contracts(contract_no, part_code)
+        ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Удаление строки договора уносит закрепление каскадом - тогда история
+-- удаляется вместе со строкой и событие не пишется
+CREATE OR REPLACE FUNCTION fn_log_assignment()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF EXISTS (SELECT 1 FROM contracts
+                   WHERE contract_no = OLD.contract_no AND part_code = OLD.part_code) THEN
+            INSERT INTO contract_assignment_events (contract_no, part_code, buyer, status, note)
+            VALUES (OLD.contract_no, OLD.part_code, OLD.buyer, 'unassigned', NULL);
+        END IF;
+        RETURN OLD;
+    END IF;
+
+    IF TG_OP = 'UPDATE'
+       AND (OLD.buyer, OLD.status, OLD.note) IS NOT DISTINCT FROM (NEW.buyer, NEW.status, NEW.note) THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO contract_assignment_events (contract_no, part_code, buyer, status, note)
+    VALUES (NEW.contract_no, NEW.part_code, NEW.buyer, NEW.status, NEW.note);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_log_assignment
+AFTER INSERT OR UPDATE OR DELETE ON contract_assignments
+FOR EACH ROW
+EXECUTE FUNCTION fn_log_assignment();
+
+-- Версия условий, действующая на дату; до первой версии берётся самая ранняя
+CREATE OR REPLACE FUNCTION fn_contract_version_at(p_contract_no INT, p_part_code TEXT, p_date DATE)
+RETURNS SETOF contract_versions
+LANGUAGE sql STABLE
+AS $$
+    SELECT *
+    FROM contract_versions v
+    WHERE v.contract_no = p_contract_no AND v.part_code = p_part_code
+    ORDER BY CASE WHEN v.valid_from <= p_date THEN v.valid_from END DESC NULLS LAST,
+             v.valid_from
+    LIMIT 1;
+$$;
+
+-- Курс валюты на дату: последний загруженный не позже p_date, для базовой
+-- валюты 1, NULL если курса ещё нет
+CREATE OR REPLACE FUNCTION fn_exchange_rate(p_currency TEXT, p_date DATE)
+RETURNS DECIMAL(14,6)
+LANGUAGE sql STABLE
+AS $$
+    SELECT CASE
+        WHEN p_currency = 'RUB' THEN 1
+        ELSE (SELECT r.rate
+              FROM exchange_rates r
+              WHERE r.currency = p_currency AND r.rate_date <= p_date
+              ORDER BY r.rate_date DESC
+              LIMIT 1)
+    END;
+$$;
+
 CREATE OR REPLACE FUNCTION fn_check_received_date()
 RETURNS TRIGGER AS $$
 DECLARE
@@ -108,28 +663,65 @@ BEFORE INSERT OR UPDATE ON deliveries
 FOR EACH ROW
 EXECUTE FUNCTION fn_check_received_date();
 
+-- Живая поставка не может ссылаться на удалённый (в корзине) склад или договор
+CREATE OR REPLACE FUNCTION fn_check_live_references()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.deleted_at IS NOT NULL THEN
+        RETURN NEW;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM warehouses
+               WHERE warehouse_no = NEW.warehouse_no AND deleted_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'warehouse % is in the trash', NEW.warehouse_no;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM contracts
+               WHERE contract_no = NEW.contract_no AND part_code = NEW.part_code
+                 AND deleted_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'contract %/% is in the trash', NEW.contract_no, NEW.part_code;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_check_live_references
+BEFORE INSERT OR UPDATE ON deliveries
+FOR EACH ROW
+EXECUTE FUNCTION fn_check_live_references();
+
 
 
 -- хранимая процедура с выходными параметрами
 -- возвращает суммарное количество поставок и договорную цену по договору и детали
+-- p_as_of: учитывать поставки по эту дату и цену, действовавшую на неё (NULL - текущее состояние)
 CREATE OR REPLACE PROCEDURE p_contract_summary(
     IN p_contract_no INT,
     IN p_part_code TEXT,
+    IN p_as_of DATE,
     OUT total_delivered DECIMAL(10,2),
     OUT contract_price DECIMAL(10,2)
 )
 LANGUAGE plpgsql
 AS $$
 BEGIN
-    -- Суммарное количество поставленных деталей
-    SELECT SUM(d.qty) INTO total_delivered
+    -- Суммарное количество поставленных деталей без забракованных
+    SELECT SUM(d.qty - COALESCE(i.rejected_qty, 0)) INTO total_delivered
     FROM deliveries d
-    WHERE d.contract_no = p_contract_no AND d.part_code = p_part_code;
+    LEFT JOIN delivery_inspections i
+        ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+    WHERE d.contract_no = p_contract_no AND d.part_code = p_part_code
+      AND d.deleted_at IS NULL
+      AND (p_as_of IS NULL OR d.received_date <= p_as_of);
 
     -- Договорная цена
-    SELECT c.contract_price INTO contract_price
+    SELECT COALESCE(v.contract_price, c.contract_price) INTO contract_price
     FROM contracts c
-    WHERE c.contract_no = p_contract_no AND c.part_code = p_part_code;
+    LEFT JOIN LATERAL fn_contract_version_at(c.contract_no, c.part_code, p_as_of) v
+        ON p_as_of IS NOT NULL
+    WHERE c.contract_no = p_contract_no AND c.part_code = p_part_code
+      AND c.deleted_at IS NULL;
 
     -- Если договора нет, вернуть 0 и NULL
     IF NOT FOUND THEN
@@ -152,6 +744,7 @@ AS $$
     SELECT count(*)
 	FROM warehouses w
 	WHERE w.manager_surname = fn_manager_surname
+	  AND w.deleted_at IS NULL
 $$;
 
 -- Табличная функция: список поставок в интервале дат
@@ -169,9 +762,12 @@ AS $$
     SELECT warehouse_no, receipt_doc_no, contract_no, part_code, qty, received_date
     FROM deliveries
     WHERE received_date BETWEEN p_start AND p_end
+      AND deleted_at IS NULL
     ORDER BY received_date;
 $$;
 
+-- Полное представление поставок: value и base_value - принятое количество
+-- (без брака) по цене версии договора на дату поставки
 CREATE VIEW full_deliveries_view AS
 	SELECT
 		d.warehouse_no,
@@ -180,6 +776,7 @@ CREATE VIEW full_deliveries_view AS
 		d.receipt_doc_no,
 		d.received_date,
 		d.qty,
+		d.qty - COALESCE(i.rejected_qty, 0) AS accepted_qty,
 		d.unit AS delivery_unit,
 
 		d.contract_no,
@@ -189,7 +786,14 @@ CREATE VIEW full_deliveries_view AS
 		c.start_date,
 		c.end_date,
 		c.plan_qty,
-		c.contract_price
+		c.contract_price,
+
+		s.name AS supplier_name,
+
+		c.currency,
+		r.rate AS exchange_rate,
+		(d.qty - COALESCE(i.rejected_qty, 0)) * COALESCE(p.contract_price, c.contract_price) AS value,
+		round((d.qty - COALESCE(i.rejected_qty, 0)) * COALESCE(p.contract_price, c.contract_price) * r.rate, 2) AS base_value
 
 	FROM deliveries d
 	LEFT JOIN warehouses w 
@@ -197,9 +801,1546 @@ CREATE VIEW full_deliveries_view AS
 	LEFT JOIN contracts c
 		ON d.contract_no = c.contract_no
 	AND d.part_code = c.part_code
+	LEFT JOIN delivery_inspections i
+		ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+	LEFT JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) p
+		ON TRUE
+	JOIN contract_headers h
+		ON h.contract_no = d.contract_no
+	JOIN suppliers s
+		ON s.supplier_id = h.supplier_id
+	LEFT JOIN LATERAL (SELECT fn_exchange_rate(c.currency, d.received_date) AS rate) r
+		ON TRUE
+	WHERE d.deleted_at IS NULL
     ORDER BY d.warehouse_no, d.receipt_doc_no;
+
+-- Представление на дату: поставки по p_as_of и условия договоров, действовавшие на неё
+CREATE OR REPLACE FUNCTION fn_full_deliveries_as_of(p_as_of DATE)
+RETURNS TABLE(
+    warehouse_no INT,
+    manager_surname TEXT,
+    receipt_doc_no INT,
+    received_date DATE,
+    qty DECIMAL(10,2),
+    accepted_qty DECIMAL(10,2),
+    delivery_unit TEXT,
+    contract_no INT,
+    part_code TEXT,
+    contract_unit TEXT,
+    start_date DATE,
+    end_date DATE,
+    plan_qty DECIMAL(10,2),
+    contract_price DECIMAL(10,2),
+    supplier_name TEXT,
+    currency CHAR(3),
+    exchange_rate DECIMAL(14,6),
+    value DECIMAL,
+    base_value DECIMAL
+)
+LANGUAGE sql STABLE
+AS $$
+    SELECT
+        d.warehouse_no,
+        w.manager_surname,
+        d.receipt_doc_no,
+        d.received_date,
+        d.qty,
+        d.qty - COALESCE(i.rejected_qty, 0),
+        d.unit,
+        d.contract_no,
+        d.part_code,
+        v.unit,
+        v.start_date,
+        v.end_date,
+        v.plan_qty,
+        v.contract_price,
+        s.name,
+        c.currency,
+        r.rate,
+        (d.qty - COALESCE(i.rejected_qty, 0)) * p.contract_price,
+        round((d.qty - COALESCE(i.rejected_qty, 0)) * p.contract_price * r.rate, 2)
+    FROM deliveries d
+    LEFT JOIN warehouses w
+        ON d.warehouse_no = w.warehouse_no
+    LEFT JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, p_as_of) v
+        ON TRUE
+    LEFT JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) p
+        ON TRUE
+    LEFT JOIN delivery_inspections i
+        ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+    JOIN contracts c
+        ON c.contract_no = d.contract_no AND c.part_code = d.part_code
+    JOIN contract_headers h
+        ON h.contract_no = d.contract_no
+    JOIN suppliers s
+        ON s.supplier_id = h.supplier_id
+    LEFT JOIN LATERAL (SELECT fn_exchange_rate(c.currency, d.received_date) AS rate) r
+        ON TRUE
+    WHERE d.deleted_at IS NULL
+      AND d.received_date <= p_as_of
+    ORDER BY d.warehouse_no, d.receipt_doc_no;
+$$;
     
+-- Движения по складам: приход (+), отпуск и отгрузка перемещения (-),
+-- приёмка перемещения (+). contract_no - договор исходной поставки
+CREATE VIEW stock_movements AS
+    SELECT d.warehouse_no, d.received_date AS movement_date, 'receipt' AS movement_type,
+           d.receipt_doc_no AS doc_no, d.part_code, d.unit, d.qty,
+           d.contract_no, d.warehouse_no AS origin_warehouse_no, d.receipt_doc_no AS origin_receipt_doc_no
+    FROM deliveries d
+    WHERE d.deleted_at IS NULL
+    UNION ALL
+    SELECT d.warehouse_no, d.received_date, 'rejection',
+           d.receipt_doc_no, d.part_code, d.unit, -i.rejected_qty,
+           d.contract_no, d.warehouse_no, d.receipt_doc_no
+    FROM deliveries d
+    JOIN delivery_inspections i
+        ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+    WHERE d.deleted_at IS NULL AND i.rejected_qty > 0
+    UNION ALL
+    SELECT i.warehouse_no, i.issued_date, 'issue',
+           i.issue_doc_no, i.part_code, i.unit, -i.qty,
+           NULL, NULL, NULL
+    FROM issues i
+    UNION ALL
+    SELECT t.from_warehouse_no, t.shipped_date, 'transfer_out',
+           t.transfer_no, l.part_code, l.unit, -l.qty,
+           o.contract_no, l.origin_warehouse_no, l.origin_receipt_doc_no
+    FROM transfers t
+    JOIN transfer_lines l ON l.transfer_no = t.transfer_no
+    LEFT JOIN deliveries o
+        ON o.warehouse_no = l.origin_warehouse_no AND o.receipt_doc_no = l.origin_receipt_doc_no
+    UNION ALL
+    SELECT t.to_warehouse_no, t.received_date, 'transfer_in',
+           t.transfer_no, l.part_code, l.unit, l.qty,
+           o.contract_no, l.origin_warehouse_no, l.origin_receipt_doc_no
+    FROM transfers t
+    JOIN transfer_lines l ON l.transfer_no = t.transfer_no
+    LEFT JOIN deliveries o
+        ON o.warehouse_no = l.origin_warehouse_no AND o.receipt_doc_no = l.origin_receipt_doc_no
+    WHERE t.status = 'received';
+
+-- Остатки на складах по журналу движений. Сторнированные записи и сами
+-- сторно в приход/расход не попадают, на остаток они в сумме не влияют
+CREATE VIEW stock_balance AS
+    SELECT
+        l.warehouse_no,
+        w.manager_surname,
+        l.part_code,
+        l.unit,
+        COALESCE(SUM(l.qty) FILTER (WHERE l.qty > 0), 0)  AS received_qty,
+        COALESCE(-SUM(l.qty) FILTER (WHERE l.qty < 0), 0) AS issued_qty,
+        SUM(l.qty)                                        AS balance_qty
+    FROM stock_ledger l
+    JOIN warehouses w
+        ON w.warehouse_no = l.warehouse_no
+    WHERE w.deleted_at IS NULL
+      AND l.reverses_id IS NULL
+      AND NOT EXISTS (SELECT 1 FROM stock_ledger r WHERE r.reverses_id = l.id)
+    GROUP BY l.warehouse_no, w.manager_surname, l.part_code, l.unit
+    ORDER BY l.warehouse_no, l.part_code, l.unit;
+
+CREATE OR REPLACE FUNCTION fn_stock_balance(p_warehouse_no INT, p_part_code TEXT, p_unit TEXT)
+RETURNS DECIMAL(10,2)
+LANGUAGE sql
+AS $$
+    SELECT COALESCE((
+        SELECT balance_qty
+        FROM stock_balance
+        WHERE warehouse_no = p_warehouse_no AND part_code = p_part_code AND unit = p_unit
+    ), 0);
+$$;
+
+-- Остатки партий по складам: приход партии на склад поставки, отпуски
+-- и перемещения с указанной партией. Товар в пути не числится нигде
+CREATE VIEW lot_balance AS
+    WITH moves AS (
+        SELECT l.warehouse_no AS holder_warehouse_no, l.warehouse_no, l.receipt_doc_no, l.lot_no, l.qty
+        FROM delivery_lots l
+        UNION ALL
+        SELECT i.warehouse_no, i.origin_warehouse_no, i.origin_receipt_doc_no, i.lot_no, -i.qty
+        FROM issues i
+        WHERE i.lot_no IS NOT NULL
+        UNION ALL
+        SELECT t.from_warehouse_no, tl.origin_warehouse_no, tl.origin_receipt_doc_no, tl.lot_no, -tl.qty
+        FROM transfers t
+        JOIN transfer_lines tl ON tl.transfer_no = t.transfer_no
+        WHERE tl.lot_no IS NOT NULL
+        UNION ALL
+        SELECT t.to_warehouse_no, tl.origin_warehouse_no, tl.origin_receipt_doc_no, tl.lot_no, tl.qty
+        FROM transfers t
+        JOIN transfer_lines tl ON tl.transfer_no = t.transfer_no
+        WHERE tl.lot_no IS NOT NULL AND t.status = 'received'
+    )
+    SELECT
+        m.holder_warehouse_no AS warehouse_no,
+        w.manager_surname,
+        l.lot_no,
+        l.warehouse_no        AS origin_warehouse_no,
+        l.receipt_doc_no      AS origin_receipt_doc_no,
+        d.contract_no,
+        d.part_code,
+        d.unit,
+        l.manufactured_date,
+        l.expiry_date,
+        SUM(m.qty)            AS qty
+    FROM moves m
+    JOIN delivery_lots l
+        ON l.warehouse_no = m.warehouse_no AND l.receipt_doc_no = m.receipt_doc_no AND l.lot_no = m.lot_no
+    JOIN deliveries d
+        ON d.warehouse_no = l.warehouse_no AND d.receipt_doc_no = l.receipt_doc_no
+    JOIN warehouses w
+        ON w.warehouse_no = m.holder_warehouse_no
+    WHERE d.deleted_at IS NULL
+      AND w.deleted_at IS NULL
+    GROUP BY m.holder_warehouse_no, w.manager_surname, l.lot_no, l.warehouse_no, l.receipt_doc_no,
+             d.contract_no, d.part_code, d.unit, l.manufactured_date, l.expiry_date
+    HAVING SUM(m.qty) <> 0;
+
+CREATE OR REPLACE FUNCTION fn_lot_balance(p_warehouse_no INT, p_origin_warehouse_no INT, p_origin_receipt_doc_no INT, p_lot_no TEXT)
+RETURNS DECIMAL(10,2)
+LANGUAGE sql
+AS $$
+    SELECT COALESCE((
+        SELECT qty
+        FROM lot_balance
+        WHERE warehouse_no = p_warehouse_no
+          AND origin_warehouse_no = p_origin_warehouse_no
+          AND origin_receipt_doc_no = p_origin_receipt_doc_no
+          AND lot_no = p_lot_no
+    ), 0);
+$$;
+
+-- Подбор партий для отпуска по FEFO: сначала партии с ближайшим сроком
+-- годности, просроченные на p_on не предлагаются. take_qty - сколько взять
+-- из партии, чтобы набрать p_qty
+CREATE OR REPLACE FUNCTION fn_fefo_pick(p_warehouse_no INT, p_part_code TEXT, p_unit TEXT, p_qty DECIMAL, p_on DATE)
+RETURNS TABLE(
+    lot_no TEXT,
+    origin_warehouse_no INT,
+    origin_receipt_doc_no INT,
+    contract_no INT,
+    manufactured_date DATE,
+    expiry_date DATE,
+    available_qty DECIMAL(10,2),
+    take_qty DECIMAL(10,2)
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH lots AS (
+        SELECT b.*,
+               SUM(b.qty) OVER (ORDER BY b.expiry_date, b.manufactured_date, b.lot_no,
+                                         b.origin_warehouse_no, b.origin_receipt_doc_no) - b.qty AS before_qty
+        FROM lot_balance b
+        WHERE b.warehouse_no = p_warehouse_no
+          AND b.part_code = p_part_code
+          AND b.unit = p_unit
+          AND b.qty > 0
+          AND b.expiry_date >= p_on
+    )
+    SELECT lot_no, origin_warehouse_no, origin_receipt_doc_no, contract_no,
+           manufactured_date, expiry_date, qty, LEAST(qty, p_qty - before_qty)
+    FROM lots
+    WHERE before_qty < p_qty
+    ORDER BY expiry_date, manufactured_date, lot_no, origin_warehouse_no, origin_receipt_doc_no;
+$$;
+
+-- Партия должна быть партией той же детали и иметь на складе остаток не
+-- меньше списываемого. Вызывается под блокировкой позиции склада
+CREATE OR REPLACE FUNCTION fn_check_lot_stock(p_warehouse_no INT, p_origin_warehouse_no INT, p_origin_receipt_doc_no INT,
+                                              p_lot_no TEXT, p_part_code TEXT, p_unit TEXT, p_qty DECIMAL)
+RETURNS void AS $$
+DECLARE
+    v_balance DECIMAL(10,2);
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM deliveries
+        WHERE warehouse_no = p_origin_warehouse_no AND receipt_doc_no = p_origin_receipt_doc_no
+          AND part_code = p_part_code AND unit = p_unit) THEN
+        RAISE EXCEPTION 'lot % of delivery %/% is not a lot of % %',
+            p_lot_no, p_origin_warehouse_no, p_origin_receipt_doc_no, p_part_code, p_unit
+            USING ERRCODE = 'KP003';
+    END IF;
+
+    v_balance := fn_lot_balance(p_warehouse_no, p_origin_warehouse_no, p_origin_receipt_doc_no, p_lot_no);
+    IF p_qty > v_balance THEN
+        RAISE EXCEPTION 'insufficient stock of lot % in warehouse %: balance %, requested %',
+            p_lot_no, p_warehouse_no, v_balance, p_qty
+            USING ERRCODE = 'KP001';
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Отпуск не может увести остаток в минус. Блокировка по (склад, деталь, ед.)
+-- сериализует параллельные отпуски одной позиции. Код KP001 - нехватка остатка
+CREATE OR REPLACE FUNCTION fn_check_issue_stock()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_balance DECIMAL(10,2);
+BEGIN
+    IF EXISTS (SELECT 1 FROM warehouses
+               WHERE warehouse_no = NEW.warehouse_no AND deleted_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'warehouse % is in the trash', NEW.warehouse_no;
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(hashtext(NEW.warehouse_no || '/' || NEW.part_code || '/' || NEW.unit));
+
+    v_balance := fn_stock_balance(NEW.warehouse_no, NEW.part_code, NEW.unit);
+    IF NEW.qty > v_balance THEN
+        RAISE EXCEPTION 'insufficient stock of % % in warehouse %: balance %, requested %',
+            NEW.part_code, NEW.unit, NEW.warehouse_no, v_balance, NEW.qty
+            USING ERRCODE = 'KP001';
+    END IF;
+
+    IF NEW.lot_no IS NOT NULL THEN
+        PERFORM fn_check_lot_stock(NEW.warehouse_no, NEW.origin_warehouse_no, NEW.origin_receipt_doc_no,
+                                   NEW.lot_no, NEW.part_code, NEW.unit, NEW.qty);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_check_issue_stock
+BEFORE INSERT ON issues
+FOR EACH ROW
+EXECUTE FUNCTION fn_check_issue_stock();
+
+-- Отгрузка перемещения проверяет остаток на складе-источнике так же, как отпуск
+CREATE OR REPLACE FUNCTION fn_check_transfer_stock()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_from_warehouse_no INT;
+    v_balance DECIMAL(10,2);
+BEGIN
+    SELECT from_warehouse_no INTO v_from_warehouse_no
+    FROM transfers
+    WHERE transfer_no = NEW.transfer_no;
+
+    IF NEW.origin_warehouse_no IS NOT NULL AND NOT EXISTS (
+        SELECT 1 FROM deliveries
+        WHERE warehouse_no = NEW.origin_warehouse_no AND receipt_doc_no = NEW.origin_receipt_doc_no
+          AND part_code = NEW.part_code AND unit = NEW.unit) THEN
+        RAISE EXCEPTION 'origin delivery %/% is not a delivery of % %',
+            NEW.origin_warehouse_no, NEW.origin_receipt_doc_no, NEW.part_code, NEW.unit;
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(hashtext(v_from_warehouse_no || '/' || NEW.part_code || '/' || NEW.unit));
+
+    v_balance := fn_stock_balance(v_from_warehouse_no, NEW.part_code, NEW.unit);
+    IF NEW.qty > v_balance THEN
+        RAISE EXCEPTION 'insufficient stock of % % in warehouse %: balance %, requested %',
+            NEW.part_code, NEW.unit, v_from_warehouse_no, v_balance, NEW.qty
+            USING ERRCODE = 'KP001';
+    END IF;
+
+    IF NEW.lot_no IS NOT NULL THEN
+        PERFORM fn_check_lot_stock(v_from_warehouse_no, NEW.origin_warehouse_no, NEW.origin_receipt_doc_no,
+                                   NEW.lot_no, NEW.part_code, NEW.unit, NEW.qty);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_check_transfer_stock
+BEFORE INSERT ON transfer_lines
+FOR EACH ROW
+EXECUTE FUNCTION fn_check_transfer_stock();
+
+CREATE OR REPLACE FUNCTION fn_period_is_closed(p_date DATE)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT EXISTS (SELECT 1 FROM accounting_periods WHERE period = date_trunc('month', p_date)::date);
+$$;
+
+-- Поставки с датой в закрытом периоде нельзя создавать, менять и удалять.
+-- Смена только номера договора (перенумерация) движений не меняет и разрешена,
+-- как и окончательное удаление из корзины (сторно уже проведено).
+-- Код KP002 - закрытый период
+CREATE OR REPLACE FUNCTION fn_check_closed_period()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE','DELETE') AND fn_period_is_closed(OLD.received_date) THEN
+        IF (TG_OP = 'DELETE' AND OLD.deleted_at IS NULL)
+           OR (TG_OP = 'UPDATE' AND (OLD.warehouse_no, OLD.receipt_doc_no, OLD.part_code, OLD.unit, OLD.qty, OLD.received_date, OLD.deleted_at)
+              IS DISTINCT FROM
+              (NEW.warehouse_no, NEW.receipt_doc_no, NEW.part_code, NEW.unit, NEW.qty, NEW.received_date, NEW.deleted_at)) THEN
+            RAISE EXCEPTION 'period % is closed', to_char(OLD.received_date, 'YYYY-MM')
+                USING ERRCODE = 'KP002';
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT','UPDATE') AND fn_period_is_closed(NEW.received_date) THEN
+        IF TG_OP = 'INSERT' OR OLD.received_date IS DISTINCT FROM NEW.received_date THEN
+            RAISE EXCEPTION 'period % is closed', to_char(NEW.received_date, 'YYYY-MM')
+                USING ERRCODE = 'KP002';
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_check_closed_period
+BEFORE INSERT OR UPDATE OR DELETE ON deliveries
+FOR EACH ROW
+EXECUTE FUNCTION fn_check_closed_period();
+
+CREATE OR REPLACE FUNCTION fn_ledger_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_ledger is append-only, post a reversing entry instead';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_append_only
+BEFORE UPDATE OR DELETE ON stock_ledger
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_append_only();
+
+CREATE TRIGGER trg_ledger_no_truncate
+BEFORE TRUNCATE ON stock_ledger
+FOR EACH STATEMENT
+EXECUTE FUNCTION fn_ledger_append_only();
+
+CREATE OR REPLACE FUNCTION fn_ledger_check_period()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF fn_period_is_closed(NEW.movement_date) THEN
+        RAISE EXCEPTION 'period % is closed', to_char(NEW.movement_date, 'YYYY-MM')
+            USING ERRCODE = 'KP002';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_check_period
+BEFORE INSERT ON stock_ledger
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_check_period();
+
+-- Проводки по поставкам: новая поставка - приход, изменение - сторно старой
+-- записи и новая запись, удаление в корзину - сторно, восстановление - приход.
+-- Приходуется количество за вычетом забракованного при входном контроле.
+-- Сторно, как и отпуск, не может увести остаток в минус (KP001): проверяется
+-- под той же блокировкой позиции с учётом новой записи на ту же позицию.
+-- Остаток склада в корзине не проверяется - он уже не числится
+CREATE OR REPLACE FUNCTION fn_ledger_post_delivery()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_qty DECIMAL(10,2);
+    v_entry stock_ledger%ROWTYPE;
+    v_balance DECIMAL(10,2);
+    v_decrease DECIMAL(10,2);
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND (OLD.warehouse_no, OLD.receipt_doc_no, OLD.part_code, OLD.unit, OLD.qty, OLD.received_date, OLD.deleted_at IS NULL)
+           IS NOT DISTINCT FROM
+           (NEW.warehouse_no, NEW.receipt_doc_no, NEW.part_code, NEW.unit, NEW.qty, NEW.received_date, NEW.deleted_at IS NULL) THEN
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP IN ('INSERT','UPDATE') AND NEW.deleted_at IS NULL THEN
+        -- при смене ключа акт контроля уже перенесён каскадом, старый ключ - на всякий случай
+        SELECT NEW.qty - COALESCE(MAX(i.rejected_qty), 0) INTO v_qty
+        FROM delivery_inspections i
+        WHERE (i.warehouse_no, i.receipt_doc_no) IN ((NEW.warehouse_no, NEW.receipt_doc_no), (OLD.warehouse_no, OLD.receipt_doc_no));
+    END IF;
+
+    IF TG_OP IN ('UPDATE','DELETE') AND OLD.deleted_at IS NULL THEN
+        SELECT l.* INTO v_entry
+        FROM stock_ledger l
+        WHERE l.source_type = 'delivery' AND l.source_ref = OLD.warehouse_no || '/' || OLD.receipt_doc_no
+          AND l.reverses_id IS NULL
+          AND NOT EXISTS (SELECT 1 FROM stock_ledger r WHERE r.reverses_id = l.id)
+        ORDER BY l.id DESC
+        LIMIT 1;
+
+        IF FOUND THEN
+            IF NOT EXISTS (SELECT 1 FROM warehouses
+                           WHERE warehouse_no = v_entry.warehouse_no AND deleted_at IS NOT NULL) THEN
+                PERFORM pg_advisory_xact_lock(hashtext(v_entry.warehouse_no || '/' || v_entry.part_code || '/' || v_entry.unit));
+
+                v_decrease := v_entry.qty;
+                IF (NEW.warehouse_no, NEW.part_code, NEW.unit) IS NOT DISTINCT FROM (v_entry.warehouse_no, v_entry.part_code, v_entry.unit)
+                   AND v_qty > 0 THEN
+                    v_decrease := v_decrease - v_qty;
+                END IF;
+
+                v_balance := fn_stock_balance(v_entry.warehouse_no, v_entry.part_code, v_entry.unit);
+                IF v_decrease > v_balance THEN
+                    RAISE EXCEPTION 'insufficient stock of % % in warehouse % to reverse delivery %/%: balance %, reversed %',
+                        v_entry.part_code, v_entry.unit, v_entry.warehouse_no, OLD.warehouse_no, OLD.receipt_doc_no,
+                        v_balance, v_decrease
+                        USING ERRCODE = 'KP001';
+                END IF;
+            END IF;
+
+            INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref, reverses_id)
+            VALUES (v_entry.movement_date, v_entry.warehouse_no, v_entry.part_code, v_entry.unit, -v_entry.qty,
+                    'delivery', v_entry.source_ref, v_entry.id);
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT','UPDATE') AND NEW.deleted_at IS NULL THEN
+        IF v_qty > 0 THEN
+            INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref)
+            VALUES (NEW.received_date, NEW.warehouse_no, NEW.part_code, NEW.unit, v_qty,
+                    'delivery', NEW.warehouse_no || '/' || NEW.receipt_doc_no);
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_post_delivery
+AFTER INSERT OR UPDATE OR DELETE ON deliveries
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_post_delivery();
+
+-- Проводки по выдачам: выдача - расход, перенос выдачи на другой склад
+-- (переназначение при удалении склада) - сторно и новая запись
+CREATE OR REPLACE FUNCTION fn_ledger_post_issue()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF (OLD.warehouse_no, OLD.issue_doc_no, OLD.part_code, OLD.unit, OLD.qty, OLD.issued_date)
+           IS NOT DISTINCT FROM
+           (NEW.warehouse_no, NEW.issue_doc_no, NEW.part_code, NEW.unit, NEW.qty, NEW.issued_date) THEN
+            RETURN NEW;
+        END IF;
+
+        INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref, reverses_id)
+        SELECT OLD.issued_date, OLD.warehouse_no, OLD.part_code, OLD.unit, OLD.qty,
+               'issue', OLD.warehouse_no || '/' || OLD.issue_doc_no, l.id
+        FROM stock_ledger l
+        WHERE l.source_type = 'issue' AND l.source_ref = OLD.warehouse_no || '/' || OLD.issue_doc_no
+          AND l.reverses_id IS NULL
+          AND NOT EXISTS (SELECT 1 FROM stock_ledger r WHERE r.reverses_id = l.id)
+        ORDER BY l.id DESC
+        LIMIT 1;
+    END IF;
+
+    INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref)
+    VALUES (NEW.issued_date, NEW.warehouse_no, NEW.part_code, NEW.unit, -NEW.qty,
+            'issue', NEW.warehouse_no || '/' || NEW.issue_doc_no);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_post_issue
+AFTER INSERT OR UPDATE ON issues
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_post_issue();
+
+-- Акт контроля с браком: приход поставки сторнируется и проводится заново
+-- на принятое количество. Забракованное должно ещё быть на остатке
+CREATE OR REPLACE FUNCTION fn_ledger_post_inspection()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_delivery deliveries%ROWTYPE;
+    v_balance DECIMAL(10,2);
+BEGIN
+    IF NEW.rejected_qty = 0 THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT * INTO v_delivery
+    FROM deliveries
+    WHERE warehouse_no = NEW.warehouse_no AND receipt_doc_no = NEW.receipt_doc_no;
+
+    PERFORM pg_advisory_xact_lock(hashtext(v_delivery.warehouse_no || '/' || v_delivery.part_code || '/' || v_delivery.unit));
+
+    v_balance := fn_stock_balance(v_delivery.warehouse_no, v_delivery.part_code, v_delivery.unit);
+    IF NEW.rejected_qty > v_balance THEN
+        RAISE EXCEPTION 'insufficient stock of % % in warehouse % to reject %: balance %',
+            v_delivery.part_code, v_delivery.unit, v_delivery.warehouse_no, NEW.rejected_qty, v_balance
+            USING ERRCODE = 'KP001';
+    END IF;
+
+    INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref, reverses_id, comment)
+    SELECT l.movement_date, l.warehouse_no, l.part_code, l.unit, -l.qty,
+           'delivery', l.source_ref, l.id, 'inspection'
+    FROM stock_ledger l
+    WHERE l.source_type = 'delivery' AND l.source_ref = NEW.warehouse_no || '/' || NEW.receipt_doc_no
+      AND l.reverses_id IS NULL
+      AND NOT EXISTS (SELECT 1 FROM stock_ledger r WHERE r.reverses_id = l.id)
+    ORDER BY l.id DESC
+    LIMIT 1;
+
+    IF NEW.accepted_qty > 0 THEN
+        INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref, comment)
+        VALUES (v_delivery.received_date, v_delivery.warehouse_no, v_delivery.part_code, v_delivery.unit, NEW.accepted_qty,
+                'delivery', NEW.warehouse_no || '/' || NEW.receipt_doc_no, 'accepted by inspection');
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_post_inspection
+AFTER INSERT ON delivery_inspections
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_post_inspection();
+
+-- После контроля количество, деталь и единицу поставки менять нельзя.
+-- Код KP003 - документ в состоянии, не допускающем операцию
+CREATE OR REPLACE FUNCTION fn_check_inspected_delivery()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (OLD.qty, OLD.part_code, OLD.unit) IS DISTINCT FROM (NEW.qty, NEW.part_code, NEW.unit)
+       AND EXISTS (SELECT 1 FROM delivery_inspections
+                   WHERE warehouse_no = OLD.warehouse_no AND receipt_doc_no = OLD.receipt_doc_no) THEN
+        RAISE EXCEPTION 'delivery %/% is inspected, its qty, part and unit are fixed',
+            OLD.warehouse_no, OLD.receipt_doc_no
+            USING ERRCODE = 'KP003';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_check_inspected_delivery
+BEFORE UPDATE ON deliveries
+FOR EACH ROW
+EXECUTE FUNCTION fn_check_inspected_delivery();
+
+-- Вернуть поставщику можно не больше забракованного
+CREATE OR REPLACE FUNCTION fn_check_supplier_return()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_rejected DECIMAL(10,2);
+    v_returned DECIMAL(10,2);
+BEGIN
+    SELECT rejected_qty INTO v_rejected
+    FROM delivery_inspections
+    WHERE warehouse_no = NEW.warehouse_no AND receipt_doc_no = NEW.receipt_doc_no
+    FOR UPDATE;
+
+    SELECT COALESCE(SUM(qty), 0) INTO v_returned
+    FROM supplier_returns
+    WHERE warehouse_no = NEW.warehouse_no AND receipt_doc_no = NEW.receipt_doc_no;
+
+    IF v_returned + NEW.qty > v_rejected THEN
+        RAISE EXCEPTION 'return of % exceeds rejected qty % of delivery %/% (already returned %)',
+            NEW.qty, v_rejected, NEW.warehouse_no, NEW.receipt_doc_no, v_returned
+            USING ERRCODE = 'KP003';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_check_supplier_return
+BEFORE INSERT ON supplier_returns
+FOR EACH ROW
+EXECUTE FUNCTION fn_check_supplier_return();
+
+-- Отгрузка перемещения - расход на складе-источнике
+CREATE OR REPLACE FUNCTION fn_ledger_post_transfer_line()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref)
+    SELECT t.shipped_date, t.from_warehouse_no, NEW.part_code, NEW.unit, -NEW.qty,
+           'transfer', NEW.transfer_no || '/' || NEW.line_no
+    FROM transfers t
+    WHERE t.transfer_no = NEW.transfer_no;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_post_transfer_line
+AFTER INSERT ON transfer_lines
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_post_transfer_line();
+
+-- Приёмка перемещения - приход на складе-получателе
+CREATE OR REPLACE FUNCTION fn_ledger_post_transfer_receipt()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'shipped' AND NEW.status = 'received' THEN
+        INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref)
+        SELECT NEW.received_date, NEW.to_warehouse_no, l.part_code, l.unit, l.qty,
+               'transfer', l.transfer_no || '/' || l.line_no
+        FROM transfer_lines l
+        WHERE l.transfer_no = NEW.transfer_no;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_ledger_post_transfer_receipt
+AFTER UPDATE ON transfers
+FOR EACH ROW
+EXECUTE FUNCTION fn_ledger_post_transfer_receipt();
+
+-- Расхождения по утверждённым инвентаризациям (излишек > 0, недостача < 0)
+CREATE VIEW stock_count_variances AS
+    SELECT
+        c.count_no,
+        c.warehouse_no,
+        c.manager_surname,
+        c.count_date,
+        c.approved_by,
+        l.part_code,
+        l.unit,
+        l.expected_qty,
+        l.counted_qty,
+        l.counted_qty - l.expected_qty AS variance_qty
+    FROM stock_counts c
+    JOIN stock_count_lines l
+        ON l.count_no = c.count_no
+    WHERE c.status = 'approved'
+      AND l.counted_qty <> l.expected_qty;
+
+-- Исполнение строк договоров со сроком end_date в периоде [p_from, p_to]
+-- (NULL - без ограничения). Поставленным считается принятое на входном
+-- контроле, брак и возвраты - отдельно. Сроки - транши графика, а без графика
+-- один транш на весь plan_qty со сроком end_date. Принятое закрывает транши
+-- по порядку сроков, как в fn_tranche_status: транш в срок - набран не позже
+-- своего срока; due_tranches - набранные транши и транши с прошедшим сроком.
+-- Поставка частичная, если не добирает транш, который начинает закрывать
+CREATE OR REPLACE FUNCTION fn_supplier_line_performance(p_from DATE, p_to DATE)
+RETURNS TABLE(
+    supplier_id INT,
+    contract_no INT,
+    part_code TEXT,
+    unit TEXT,
+    end_date DATE,
+    plan_qty DECIMAL(10,2),
+    delivered_qty DECIMAL(10,2),
+    rejected_qty DECIMAL(10,2),
+    returned_qty DECIMAL(10,2),
+    deliveries INT,
+    due_tranches INT,
+    on_time_tranches INT,
+    partial_deliveries INT,
+    last_received_date DATE
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH tranches AS (
+        SELECT s.contract_no, s.part_code, s.tranche_no, s.due_date, s.planned_qty
+        FROM contract_schedules s
+        UNION ALL
+        SELECT c.contract_no, c.part_code, 1, c.end_date, c.plan_qty
+        FROM contracts c
+        WHERE NOT EXISTS (SELECT 1 FROM contract_schedules s
+                          WHERE s.contract_no = c.contract_no AND s.part_code = c.part_code)
+    ), bounds AS (
+        SELECT t.*,
+               SUM(t.planned_qty) OVER (PARTITION BY t.contract_no, t.part_code
+                                        ORDER BY t.due_date, t.tranche_no) AS cum_planned
+        FROM tranches t
+    ), received AS (
+        SELECT d.warehouse_no, d.receipt_doc_no, d.contract_no, d.part_code, d.received_date,
+               d.qty - COALESCE(i.rejected_qty, 0) AS accepted_qty,
+               COALESCE(i.rejected_qty, 0) AS rejected_qty,
+               SUM(d.qty - COALESCE(i.rejected_qty, 0)) OVER (PARTITION BY d.contract_no, d.part_code
+                   ORDER BY d.received_date, d.warehouse_no, d.receipt_doc_no) AS cum_received
+        FROM deliveries d
+        LEFT JOIN delivery_inspections i
+            ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+        WHERE d.deleted_at IS NULL
+    ), receipts AS (
+        -- транш, который поставка начинает закрывать; сверх плана - нет
+        SELECT r.*, t.cum_planned AS tranche_cum_planned
+        FROM received r
+        LEFT JOIN LATERAL (
+            SELECT b.cum_planned
+            FROM bounds b
+            WHERE b.contract_no = r.contract_no AND b.part_code = r.part_code
+              AND b.cum_planned > r.cum_received - r.accepted_qty
+            ORDER BY b.cum_planned
+            LIMIT 1
+        ) t ON true
+    ), covered AS (
+        SELECT
+            b.contract_no,
+            b.part_code,
+            COUNT(*) FILTER (WHERE cov.covered_date IS NOT NULL OR b.due_date < CURRENT_DATE) AS due_tranches,
+            COUNT(*) FILTER (WHERE cov.covered_date <= b.due_date) AS on_time_tranches
+        FROM bounds b
+        LEFT JOIN LATERAL (
+            SELECT MIN(r.received_date) AS covered_date
+            FROM received r
+            WHERE r.contract_no = b.contract_no AND r.part_code = b.part_code
+              AND r.cum_received >= b.cum_planned
+        ) cov ON true
+        GROUP BY b.contract_no, b.part_code
+    )
+    SELECT
+        h.supplier_id,
+        c.contract_no,
+        c.part_code,
+        c.unit,
+        c.end_date,
+        c.plan_qty,
+        COALESCE(SUM(d.accepted_qty), 0),
+        COALESCE(SUM(d.rejected_qty), 0),
+        COALESCE(SUM(r.qty), 0),
+        COUNT(d.receipt_doc_no)::int,
+        COALESCE(cv.due_tranches, 0)::int,
+        COALESCE(cv.on_time_tranches, 0)::int,
+        (COUNT(d.receipt_doc_no) FILTER (WHERE d.cum_received < d.tranche_cum_planned))::int,
+        MAX(d.received_date)
+    FROM contracts c
+    JOIN contract_headers h
+        ON h.contract_no = c.contract_no
+    LEFT JOIN receipts d
+        ON d.contract_no = c.contract_no
+       AND d.part_code = c.part_code
+    LEFT JOIN LATERAL (
+        SELECT SUM(sr.qty) AS qty
+        FROM supplier_returns sr
+        WHERE sr.warehouse_no = d.warehouse_no AND sr.receipt_doc_no = d.receipt_doc_no
+    ) r ON true
+    LEFT JOIN covered cv
+        ON cv.contract_no = c.contract_no AND cv.part_code = c.part_code
+    WHERE c.deleted_at IS NULL
+      AND (p_from IS NULL OR c.end_date >= p_from)
+      AND (p_to IS NULL OR c.end_date <= p_to)
+    GROUP BY h.supplier_id, c.contract_no, c.part_code, c.unit, c.end_date, c.plan_qty,
+             cv.due_tranches, cv.on_time_tranches;
+$$;
+
+-- Рейтинг поставщиков: доля траншей, набранных в срок, средняя выполняемость плана
+-- (перепоставка не засчитывается сверх 100%), доля брака от поставленного,
+-- итоговый балл - среднее долей в срок, выполнения и годного
+CREATE OR REPLACE FUNCTION fn_supplier_scorecard(p_from DATE, p_to DATE)
+RETURNS TABLE(
+    supplier_id INT,
+    supplier_name TEXT,
+    lines INT,
+    deliveries INT,
+    on_time_rate DECIMAL(5,4),
+    fill_rate DECIMAL(5,4),
+    partial_deliveries INT,
+    reject_rate DECIMAL(5,4),
+    score DECIMAL(5,4),
+    rank INT
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH agg AS (
+        SELECT
+            p.supplier_id,
+            COUNT(*)::int AS lines,
+            SUM(p.deliveries)::int AS deliveries,
+            SUM(p.on_time_tranches)::decimal / NULLIF(SUM(p.due_tranches), 0) AS on_time_rate,
+            AVG(LEAST(p.delivered_qty / p.plan_qty, 1)) AS fill_rate,
+            SUM(p.partial_deliveries)::int AS partial_deliveries,
+            COALESCE(SUM(p.rejected_qty) / NULLIF(SUM(p.delivered_qty + p.rejected_qty), 0), 0) AS reject_rate
+        FROM fn_supplier_line_performance(p_from, p_to) p
+        GROUP BY p.supplier_id
+    ), scored AS (
+        SELECT agg.*, (COALESCE(agg.on_time_rate, 0) + agg.fill_rate + (1 - agg.reject_rate)) / 3 AS score
+        FROM agg
+    )
+    SELECT
+        s.supplier_id,
+        s.name,
+        sc.lines,
+        sc.deliveries,
+        round(sc.on_time_rate, 4),
+        round(sc.fill_rate, 4),
+        sc.partial_deliveries,
+        round(sc.reject_rate, 4),
+        round(sc.score, 4),
+        (RANK() OVER (ORDER BY sc.score DESC))::int
+    FROM scored sc
+    JOIN suppliers s
+        ON s.supplier_id = sc.supplier_id
+    ORDER BY 10, s.name;
+$$;
+
+-- Сопоставление фактических поставок траншам графика на дату p_on.
+-- Принятое количество (без брака) по строке закрывает транши по порядку
+-- сроков; covered_date - дата поставки, которой транш набран полностью.
+-- Статус: on_time / late - набран в срок или позже, short - срок прошёл,
+-- а транш не набран, open - срок ещё не наступил
+CREATE OR REPLACE FUNCTION fn_tranche_status(p_on DATE)
+RETURNS TABLE(
+    contract_no INT,
+    part_code TEXT,
+    unit TEXT,
+    tranche_no INT,
+    due_date DATE,
+    planned_qty DECIMAL(10,2),
+    matched_qty DECIMAL(10,2),
+    covered_date DATE,
+    status TEXT,
+    days_late INT
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH tranches AS (
+        SELECT s.contract_no, s.part_code, c.unit, s.tranche_no, s.due_date, s.planned_qty,
+               SUM(s.planned_qty) OVER (PARTITION BY s.contract_no, s.part_code
+                                        ORDER BY s.due_date, s.tranche_no) AS cum_planned
+        FROM contract_schedules s
+        JOIN contracts c
+            ON c.contract_no = s.contract_no AND c.part_code = s.part_code
+        WHERE c.deleted_at IS NULL
+    ), received AS (
+        SELECT d.contract_no, d.part_code, d.received_date,
+               SUM(d.qty - COALESCE(i.rejected_qty, 0)) OVER (PARTITION BY d.contract_no, d.part_code
+                   ORDER BY d.received_date, d.warehouse_no, d.receipt_doc_no) AS cum_received
+        FROM deliveries d
+        LEFT JOIN delivery_inspections i
+            ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+        WHERE d.deleted_at IS NULL
+          AND d.received_date <= p_on
+    ), matched AS (
+        SELECT t.*,
+               LEAST(GREATEST(COALESCE(tot.total, 0) - (t.cum_planned - t.planned_qty), 0), t.planned_qty) AS matched_qty,
+               (SELECT MIN(r.received_date) FROM received r
+                WHERE r.contract_no = t.contract_no AND r.part_code = t.part_code
+                  AND r.cum_received >= t.cum_planned) AS covered_date
+        FROM tranches t
+        LEFT JOIN (
+            SELECT r.contract_no, r.part_code, MAX(r.cum_received) AS total
+            FROM received r
+            GROUP BY r.contract_no, r.part_code
+        ) tot ON tot.contract_no = t.contract_no AND tot.part_code = t.part_code
+    )
+    SELECT
+        m.contract_no,
+        m.part_code,
+        m.unit,
+        m.tranche_no,
+        m.due_date,
+        m.planned_qty,
+        m.matched_qty,
+        m.covered_date,
+        CASE
+            WHEN m.covered_date IS NOT NULL AND m.covered_date <= m.due_date THEN 'on_time'
+            WHEN m.covered_date IS NOT NULL THEN 'late'
+            WHEN m.due_date < p_on THEN 'short'
+            ELSE 'open'
+        END,
+        GREATEST(COALESCE(m.covered_date, p_on) - m.due_date, 0)
+    FROM matched m
+    ORDER BY m.contract_no, m.part_code, m.due_date, m.tranche_no;
+$$;
+
+-- Очерёдность строк договоров по графику на дату p_on (в духе задания 2):
+-- сначала строки с просроченными недопоставками, затем по сроку ближайшего
+-- незакрытого транша. Строки без недопоставок не выводятся
+CREATE OR REPLACE FUNCTION fn_schedule_priority(p_on DATE)
+RETURNS TABLE(
+    contract_no INT,
+    part_code TEXT,
+    unit TEXT,
+    plan_qty DECIMAL(10,2),
+    end_date DATE,
+    next_due_date DATE,
+    overdue_qty DECIMAL(10,2),
+    outstanding_qty DECIMAL(10,2),
+    priority INT
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH lines AS (
+        SELECT t.contract_no, t.part_code, t.unit,
+               MIN(t.due_date) FILTER (WHERE t.status IN ('short','open')) AS next_due_date,
+               COALESCE(SUM(t.planned_qty - t.matched_qty) FILTER (WHERE t.status = 'short'), 0) AS overdue_qty,
+               SUM(t.planned_qty - t.matched_qty) AS outstanding_qty
+        FROM fn_tranche_status(p_on) t
+        GROUP BY t.contract_no, t.part_code, t.unit
+    )
+    SELECT
+        l.contract_no,
+        l.part_code,
+        l.unit,
+        c.plan_qty,
+        c.end_date,
+        l.next_due_date,
+        l.overdue_qty,
+        l.outstanding_qty,
+        (DENSE_RANK() OVER (ORDER BY l.overdue_qty > 0 DESC, l.next_due_date))::int
+    FROM lines l
+    JOIN contracts c
+        ON c.contract_no = l.contract_no AND c.part_code = l.part_code
+    WHERE l.outstanding_qty > 0
+    ORDER BY 9, l.contract_no, l.part_code;
+$$;
+
+-- Очередь ускорения поставок на дату p_on: живые строки, цена которых в
+-- базовой валюте по курсу на p_on выше p_min_price, принятое по которым меньше плана. Факторы приводятся к [0, 1]: срочность -
+-- чем меньше дней до end_date, тем ближе к 1; остаток - доля плана, которую
+-- ещё не поставили; стоимость - plan_qty * contract_price в базовой валюте
+-- по курсу на p_on; поставщик - 1 - балл рейтинга (без рейтинга 0.5).
+-- Балл строки - среднее факторов с весами p_w_*. Без курса валюты цену не с
+-- чем сравнить: строка остаётся в очереди, фактор стоимости в её балле не
+-- участвует, а rate_missing помечает такую строку.
+CREATE OR REPLACE FUNCTION fn_contract_queue(p_on DATE, p_min_price DECIMAL,
+    p_w_days DECIMAL, p_w_remaining DECIMAL, p_w_value DECIMAL, p_w_supplier DECIMAL)
+RETURNS TABLE(
+    contract_no INT,
+    part_code TEXT,
+    supplier_id INT,
+    supplier_name TEXT,
+    unit TEXT,
+    plan_qty DECIMAL(10,2),
+    accepted_qty DECIMAL(10,2),
+    remaining_qty DECIMAL(10,2),
+    end_date DATE,
+    days_left INT,
+    contract_value DECIMAL(14,2),
+    supplier_score DECIMAL(5,4),
+    score DECIMAL(5,4),
+    priority INT,
+    buyer TEXT,
+    assignment_status TEXT,
+    rate_missing BOOLEAN
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH lines AS (
+        SELECT
+            c.contract_no,
+            c.part_code,
+            h.supplier_id,
+            s.name AS supplier_name,
+            c.unit,
+            c.plan_qty,
+            c.end_date,
+            COALESCE(a.qty, 0) AS accepted_qty,
+            c.end_date - p_on AS days_left,
+            c.plan_qty * c.contract_price * fn_exchange_rate(c.currency, p_on) AS contract_value
+        FROM contracts c
+        JOIN contract_headers h
+            ON h.contract_no = c.contract_no
+        JOIN suppliers s
+            ON s.supplier_id = h.supplier_id
+        LEFT JOIN LATERAL (
+            SELECT SUM(d.qty - COALESCE(i.rejected_qty, 0)) AS qty
+            FROM deliveries d
+            LEFT JOIN delivery_inspections i
+                ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+            WHERE d.contract_no = c.contract_no AND d.part_code = c.part_code
+              AND d.deleted_at IS NULL AND d.received_date <= p_on
+        ) a ON true
+        WHERE c.deleted_at IS NULL
+          AND COALESCE(c.contract_price * fn_exchange_rate(c.currency, p_on) > p_min_price, true)
+    ), factors AS (
+        SELECT
+            l.*,
+            sc.score AS supplier_score,
+            1 - COALESCE((l.days_left - MIN(l.days_left) OVER ())::decimal
+                / NULLIF(MAX(l.days_left) OVER () - MIN(l.days_left) OVER (), 0), 0) AS f_days,
+            (l.plan_qty - l.accepted_qty) / l.plan_qty AS f_remaining,
+            CASE WHEN l.contract_value IS NOT NULL THEN
+                COALESCE((l.contract_value - MIN(l.contract_value) OVER ())
+                    / NULLIF(MAX(l.contract_value) OVER () - MIN(l.contract_value) OVER (), 0), 0)
+            END AS f_value,
+            1 - COALESCE(sc.score, 0.5) AS f_supplier
+        FROM lines l
+        LEFT JOIN fn_supplier_scorecard(NULL, NULL) sc
+            ON sc.supplier_id = l.supplier_id
+        WHERE l.accepted_qty < l.plan_qty
+    ), scored AS (
+        SELECT
+            f.*,
+            (p_w_days * f.f_days + p_w_remaining * f.f_remaining
+                + COALESCE(p_w_value * f.f_value, 0) + p_w_supplier * f.f_supplier)
+                / NULLIF(p_w_days + p_w_remaining
+                    + CASE WHEN f.f_value IS NULL THEN 0 ELSE p_w_value END + p_w_supplier, 0) AS line_score
+        FROM factors f
+    )
+    SELECT
+        q.contract_no,
+        q.part_code,
+        q.supplier_id,
+        q.supplier_name,
+        q.unit,
+        q.plan_qty,
+        q.accepted_qty,
+        q.plan_qty - q.accepted_qty,
+        q.end_date,
+        q.days_left,
+        round(q.contract_value, 2),
+        q.supplier_score,
+        round(COALESCE(q.line_score, 0), 4),
+        (DENSE_RANK() OVER (ORDER BY COALESCE(q.line_score, 0) DESC, q.end_date))::int,
+        asg.buyer,
+        asg.status,
+        q.contract_value IS NULL
+    FROM scored q
+    LEFT JOIN contract_assignments asg
+        ON asg.contract_no = q.contract_no AND asg.part_code = q.part_code
+    ORDER BY 14, q.contract_no, q.part_code;
+$$;
+
+-- Начисления неустойки на дату p_on по строкам с условиями неустойки.
+-- Срок - транш графика, а без графика - end_date строки на весь plan_qty.
+-- Принятое количество закрывает сроки по порядку, как в fn_tranche_status:
+-- часть поставки, пришедшая после срока своего транша, начисляет неустойку
+-- за дни от срока до received_date; не поставленное к p_on по прошедшим
+-- срокам - за дни до p_on (receipt_doc_no пустой). Цена - по версии условий
+-- на дату срока
+CREATE OR REPLACE FUNCTION fn_penalty_events(p_on DATE)
+RETURNS TABLE(
+    contract_no INT,
+    part_code TEXT,
+    unit TEXT,
+    tranche_no INT,
+    due_date DATE,
+    warehouse_no INT,
+    receipt_doc_no INT,
+    received_date DATE,
+    late_qty DECIMAL(10,2),
+    days_late INT,
+    price DECIMAL(10,2),
+    amount DECIMAL(12,2)
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH lines AS (
+        SELECT c.contract_no, c.part_code, c.unit, c.end_date, c.plan_qty, c.contract_price, pt.daily_rate_pct
+        FROM contracts c
+        JOIN contract_penalty_terms pt
+            ON pt.contract_no = c.contract_no AND pt.part_code = c.part_code
+        WHERE c.deleted_at IS NULL
+    ), tranches AS (
+        SELECT s.contract_no, s.part_code, s.tranche_no, s.due_date,
+               SUM(s.planned_qty) OVER w - s.planned_qty AS from_qty,
+               SUM(s.planned_qty) OVER w AS to_qty
+        FROM lines l
+        JOIN contract_schedules s
+            ON s.contract_no = l.contract_no AND s.part_code = l.part_code
+        WINDOW w AS (PARTITION BY s.contract_no, s.part_code ORDER BY s.due_date, s.tranche_no)
+        UNION ALL
+        SELECT l.contract_no, l.part_code, NULL, l.end_date, 0, l.plan_qty
+        FROM lines l
+        WHERE NOT EXISTS (SELECT 1 FROM contract_schedules s
+                          WHERE s.contract_no = l.contract_no AND s.part_code = l.part_code)
+    ), accepted AS (
+        SELECT d.contract_no, d.part_code, d.warehouse_no, d.receipt_doc_no, d.received_date,
+               d.qty - COALESCE(i.rejected_qty, 0) AS qty
+        FROM lines l
+        JOIN deliveries d
+            ON d.contract_no = l.contract_no AND d.part_code = l.part_code
+        LEFT JOIN delivery_inspections i
+            ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+        WHERE d.deleted_at IS NULL
+          AND d.received_date <= p_on
+    ), received AS (
+        SELECT a.*,
+               SUM(a.qty) OVER w - a.qty AS from_qty,
+               SUM(a.qty) OVER w AS to_qty
+        FROM accepted a
+        WINDOW w AS (PARTITION BY a.contract_no, a.part_code
+                     ORDER BY a.received_date, a.warehouse_no, a.receipt_doc_no)
+    ), totals AS (
+        SELECT r.contract_no, r.part_code, MAX(r.to_qty) AS total
+        FROM received r
+        GROUP BY r.contract_no, r.part_code
+    ), events AS (
+        SELECT t.contract_no, t.part_code, t.tranche_no, t.due_date,
+               r.warehouse_no, r.receipt_doc_no, r.received_date,
+               LEAST(t.to_qty, r.to_qty) - GREATEST(t.from_qty, r.from_qty) AS late_qty,
+               r.received_date - t.due_date AS days_late
+        FROM tranches t
+        JOIN received r
+            ON r.contract_no = t.contract_no AND r.part_code = t.part_code
+        WHERE r.received_date > t.due_date
+          AND LEAST(t.to_qty, r.to_qty) > GREATEST(t.from_qty, r.from_qty)
+        UNION ALL
+        SELECT t.contract_no, t.part_code, t.tranche_no, t.due_date,
+               NULL, NULL, NULL,
+               t.to_qty - GREATEST(t.from_qty, COALESCE(tot.total, 0)),
+               p_on - t.due_date
+        FROM tranches t
+        LEFT JOIN totals tot
+            ON tot.contract_no = t.contract_no AND tot.part_code = t.part_code
+        WHERE t.due_date < p_on
+          AND t.to_qty > COALESCE(tot.total, 0)
+    )
+    SELECT
+        e.contract_no,
+        e.part_code,
+        l.unit,
+        e.tranche_no,
+        e.due_date,
+        e.warehouse_no,
+        e.receipt_doc_no,
+        e.received_date,
+        e.late_qty,
+        e.days_late,
+        COALESCE(v.contract_price, l.contract_price),
+        round(e.late_qty * COALESCE(v.contract_price, l.contract_price) * l.daily_rate_pct / 100 * e.days_late, 2)
+    FROM events e
+    JOIN lines l
+        ON l.contract_no = e.contract_no AND l.part_code = e.part_code
+    LEFT JOIN LATERAL fn_contract_version_at(e.contract_no, e.part_code, e.due_date) v ON true
+    ORDER BY e.contract_no, e.part_code, e.due_date, e.received_date NULLS LAST, e.warehouse_no, e.receipt_doc_no;
+$$;
+
+-- Претензии по неустойке на дату p_on: начисленное по строке ограничено
+-- cap_pct процентов от plan_qty * contract_price по версии договора на
+-- последний просроченный срок (события оценены так же - по дате срока)
+CREATE OR REPLACE FUNCTION fn_penalty_claims(p_on DATE)
+RETURNS TABLE(
+    supplier_id INT,
+    supplier_name TEXT,
+    contract_no INT,
+    part_code TEXT,
+    unit TEXT,
+    daily_rate_pct DECIMAL(6,3),
+    cap_pct DECIMAL(5,2),
+    late_qty DECIMAL(10,2),
+    max_days_late INT,
+    accrued DECIMAL(12,2),
+    cap_amount DECIMAL(12,2),
+    penalty DECIMAL(12,2)
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH events AS (
+        SELECT * FROM fn_penalty_events(p_on)
+    ), last_due AS (
+        SELECT ev.contract_no, ev.part_code, MAX(ev.due_date) AS due_date
+        FROM events ev
+        GROUP BY ev.contract_no, ev.part_code
+    )
+    SELECT
+        h.supplier_id,
+        s.name,
+        e.contract_no,
+        e.part_code,
+        e.unit,
+        pt.daily_rate_pct,
+        pt.cap_pct,
+        SUM(e.late_qty),
+        MAX(e.days_late),
+        SUM(e.amount),
+        round(COALESCE(v.plan_qty, c.plan_qty) * COALESCE(v.contract_price, c.contract_price) * pt.cap_pct / 100, 2),
+        LEAST(SUM(e.amount),
+              round(COALESCE(v.plan_qty, c.plan_qty) * COALESCE(v.contract_price, c.contract_price) * pt.cap_pct / 100, 2))
+    FROM events e
+    JOIN contracts c
+        ON c.contract_no = e.contract_no AND c.part_code = e.part_code
+    JOIN last_due ld
+        ON ld.contract_no = e.contract_no AND ld.part_code = e.part_code
+    LEFT JOIN LATERAL fn_contract_version_at(e.contract_no, e.part_code, ld.due_date) v ON true
+    JOIN contract_penalty_terms pt
+        ON pt.contract_no = e.contract_no AND pt.part_code = e.part_code
+    JOIN contract_headers h
+        ON h.contract_no = e.contract_no
+    JOIN suppliers s
+        ON s.supplier_id = h.supplier_id
+    GROUP BY h.supplier_id, s.name, e.contract_no, e.part_code, e.unit, pt.daily_rate_pct, pt.cap_pct,
+             c.plan_qty, c.contract_price, v.plan_qty, v.contract_price
+    ORDER BY s.name, e.contract_no, e.part_code;
+$$;
+
+-- Трёхстороннее сопоставление строк счетов: цена договора на дату поставки,
+-- принятое количество (без брака) и выставленная сумма. Флаги несовпадений
+-- истинны и тогда, когда поставки уже нет или она в корзине
+CREATE VIEW invoice_matching AS
+    SELECT
+        l.invoice_id,
+        i.supplier_id,
+        i.invoice_no,
+        l.line_no,
+        l.warehouse_no,
+        l.receipt_doc_no,
+        d.contract_no,
+        d.part_code,
+        d.unit,
+        d.received_date,
+        d.qty - COALESCE(q.rejected_qty, 0)                          AS received_qty,
+        v.contract_price,
+        l.qty                                                        AS invoiced_qty,
+        l.unit_price,
+        l.amount                                                     AS invoiced_amount,
+        round((d.qty - COALESCE(q.rejected_qty, 0)) * v.contract_price, 2) AS expected_amount,
+        (d.warehouse_no IS NULL OR d.deleted_at IS NOT NULL)          AS delivery_missing,
+        l.qty IS DISTINCT FROM d.qty - COALESCE(q.rejected_qty, 0)    AS qty_mismatch,
+        l.unit_price IS DISTINCT FROM v.contract_price                AS price_mismatch,
+        l.amount IS DISTINCT FROM round((d.qty - COALESCE(q.rejected_qty, 0)) * v.contract_price, 2) AS amount_mismatch
+    FROM invoice_lines l
+    JOIN supplier_invoices i
+        ON i.invoice_id = l.invoice_id
+    LEFT JOIN deliveries d
+        ON d.warehouse_no = l.warehouse_no AND d.receipt_doc_no = l.receipt_doc_no
+    LEFT JOIN delivery_inspections q
+        ON q.warehouse_no = d.warehouse_no AND q.receipt_doc_no = d.receipt_doc_no
+    LEFT JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) v ON true;
+
+-- Кредиторская задолженность на дату p_on: неоплаченный остаток счетов,
+-- выставленных к этой дате, по срокам просрочки
+CREATE OR REPLACE FUNCTION fn_ap_aging(p_on DATE)
+RETURNS TABLE(
+    invoice_id INT,
+    supplier_id INT,
+    supplier_name TEXT,
+    invoice_no TEXT,
+    invoice_date DATE,
+    due_date DATE,
+    amount DECIMAL(12,2),
+    paid DECIMAL(12,2),
+    open_amount DECIMAL(12,2),
+    days_overdue INT,
+    bucket TEXT
+)
+LANGUAGE sql STABLE
+AS $$
+    SELECT
+        i.invoice_id,
+        i.supplier_id,
+        s.name,
+        i.invoice_no,
+        i.invoice_date,
+        i.due_date,
+        t.amount,
+        COALESCE(p.paid, 0),
+        t.amount - COALESCE(p.paid, 0),
+        GREATEST(p_on - i.due_date, 0),
+        CASE
+            WHEN p_on <= i.due_date THEN 'current'
+            WHEN p_on - i.due_date <= 30 THEN '1-30'
+            WHEN p_on - i.due_date <= 60 THEN '31-60'
+            WHEN p_on - i.due_date <= 90 THEN '61-90'
+            ELSE '90+'
+        END
+    FROM supplier_invoices i
+    JOIN suppliers s
+        ON s.supplier_id = i.supplier_id
+    CROSS JOIN LATERAL (
+        SELECT COALESCE(SUM(l.amount), 0) AS amount
+        FROM invoice_lines l
+        WHERE l.invoice_id = i.invoice_id
+    ) t
+    LEFT JOIN LATERAL (
+        SELECT SUM(pm.amount) AS paid
+        FROM supplier_payments pm
+        WHERE pm.invoice_id = i.invoice_id AND pm.paid_date <= p_on
+    ) p ON true
+    WHERE i.invoice_date <= p_on
+      AND t.amount - COALESCE(p.paid, 0) > 0
+    ORDER BY s.name, i.due_date, i.invoice_no;
+$$;
+
+-- Обязательства и расходы по строкам договоров помесячно, в валюте договора
+-- и в базовой. Обязательство (план x текущая цена) ложится на месяц срока
+-- транша, без графика - на месяц окончания поставки, по курсу на этот срок.
+-- Расход - принятое количество поставок по цене и курсу на дату поступления.
+-- rate_missing - часть сумм не пересчитана, курс валюты ещё не загружен
+CREATE VIEW commitment_by_month AS
+    WITH lines AS (
+        SELECT c.contract_no, c.part_code, c.contract_price, c.currency,
+               h.supplier_id, s.name AS supplier_name, p.category
+        FROM contracts c
+        JOIN contract_headers h
+            ON h.contract_no = c.contract_no
+        JOIN suppliers s
+            ON s.supplier_id = h.supplier_id
+        JOIN parts p
+            ON p.part_code = c.part_code
+        WHERE c.deleted_at IS NULL
+    ),
+    planned AS (
+        SELECT cs.contract_no, cs.part_code, cs.due_date, cs.planned_qty AS qty
+        FROM contract_schedules cs
+        UNION ALL
+        SELECT c.contract_no, c.part_code, c.end_date, c.plan_qty
+        FROM contracts c
+        WHERE NOT EXISTS (
+            SELECT 1 FROM contract_schedules cs
+            WHERE cs.contract_no = c.contract_no AND cs.part_code = c.part_code)
+    ),
+    amounts AS (
+        SELECT l.contract_no, l.part_code,
+               date_trunc('month', k.due_date)::date AS month,
+               k.qty * l.contract_price AS committed,
+               k.qty * l.contract_price * fn_exchange_rate(l.currency, k.due_date) AS committed_base,
+               0 AS spent,
+               0 AS spent_base
+        FROM planned k
+        JOIN lines l
+            ON l.contract_no = k.contract_no AND l.part_code = k.part_code
+        UNION ALL
+        SELECT l.contract_no, l.part_code,
+               date_trunc('month', d.received_date)::date,
+               0,
+               0,
+               (d.qty - COALESCE(q.rejected_qty, 0)) * v.contract_price,
+               (d.qty - COALESCE(q.rejected_qty, 0)) * v.contract_price * fn_exchange_rate(l.currency, d.received_date)
+        FROM deliveries d
+        JOIN lines l
+            ON l.contract_no = d.contract_no AND l.part_code = d.part_code
+        LEFT JOIN delivery_inspections q
+            ON q.warehouse_no = d.warehouse_no AND q.receipt_doc_no = d.receipt_doc_no
+        CROSS JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) v
+        WHERE d.deleted_at IS NULL
+    )
+    SELECT
+        l.contract_no,
+        l.part_code,
+        l.supplier_id,
+        l.supplier_name,
+        l.category,
+        l.currency,
+        a.month,
+        SUM(a.committed)                      AS committed,
+        round(SUM(a.committed_base), 2)       AS committed_base,
+        SUM(a.spent)                          AS spent,
+        round(SUM(a.spent_base), 2)           AS spent_base,
+        bool_or(a.committed_base IS NULL OR a.spent_base IS NULL) AS rate_missing
+    FROM amounts a
+    JOIN lines l
+        ON l.contract_no = a.contract_no AND l.part_code = a.part_code
+    GROUP BY l.contract_no, l.part_code, l.supplier_id, l.supplier_name, l.category, l.currency, a.month;
+
+-- Исполнение бюджетов: обязательства и расходы месяца по категории бюджета
+CREATE VIEW budget_usage AS
+    SELECT
+        b.budget_id,
+        b.period_month,
+        b.category,
+        b.limit_amount,
+        b.enforcement,
+        COALESCE(SUM(m.committed_base), 0)                  AS committed,
+        COALESCE(SUM(m.spent_base), 0)                      AS spent,
+        b.limit_amount - COALESCE(SUM(m.committed_base), 0) AS remaining,
+        COALESCE(bool_or(m.rate_missing), false)            AS rate_missing
+    FROM budgets b
+    LEFT JOIN commitment_by_month m
+        ON m.month = b.period_month
+       AND (b.category IS NULL OR m.category = b.category)
+    GROUP BY b.budget_id, b.period_month, b.category, b.limit_amount, b.enforcement;
+
+-- ABC/XYZ-анализ деталей за период [p_from, p_to] (NULL - без ограничения).
+-- Количество - принятое на входном контроле, стоимость - как в
+-- full_deliveries_view: по цене версии на дату поставки в базовой валюте.
+-- ABC - по доле стоимости поставок:
+-- A - детали, набирающие первые p_a% стоимости, B - до p_b%, остальные C.
+-- XYZ - по коэффициенту вариации (%) помесячного принятого количества в основной единице
+-- детали, включая месяцы без поставок: X - до p_x, Y - до p_y, иначе Z
+CREATE OR REPLACE FUNCTION fn_abc_xyz(p_from DATE, p_to DATE, p_a DECIMAL, p_b DECIMAL, p_x DECIMAL, p_y DECIMAL)
+RETURNS TABLE(
+    part_code TEXT,
+    name TEXT,
+    unit TEXT,
+    deliveries INT,
+    qty DECIMAL(12,2),
+    value DECIMAL(14,2),
+    share DECIMAL(7,4),
+    cumulative_share DECIMAL(7,4),
+    months INT,
+    mean_qty DECIMAL(12,2),
+    cv DECIMAL(10,2),
+    abc CHAR(1),
+    xyz CHAR(1),
+    rate_missing BOOLEAN
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH d AS (
+        SELECT
+            d.part_code,
+            date_trunc('month', d.received_date)::date AS month,
+            CASE WHEN d.unit = p.default_unit THEN d.qty - COALESCE(i.rejected_qty, 0) END AS qty,
+            (d.qty - COALESCE(i.rejected_qty, 0)) * COALESCE(v.contract_price, c.contract_price)
+                * fn_exchange_rate(c.currency, d.received_date) AS value
+        FROM deliveries d
+        JOIN contracts c
+            ON c.contract_no = d.contract_no AND c.part_code = d.part_code
+        JOIN parts p
+            ON p.part_code = d.part_code
+        LEFT JOIN delivery_inspections i
+            ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+        LEFT JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) v
+            ON TRUE
+        WHERE d.deleted_at IS NULL
+          AND (p_from IS NULL OR d.received_date >= p_from)
+          AND (p_to IS NULL OR d.received_date <= p_to)
+    ), months AS (
+        SELECT generate_series(
+            COALESCE(date_trunc('month', p_from), MIN(d.month)),
+            COALESCE(date_trunc('month', p_to), MAX(d.month)),
+            interval '1 month')::date AS month
+        FROM d
+    ), monthly AS (
+        SELECT pc.part_code, m.month, COALESCE(SUM(d.qty), 0) AS qty
+        FROM (SELECT DISTINCT d.part_code FROM d) pc
+        CROSS JOIN months m
+        LEFT JOIN d
+            ON d.part_code = pc.part_code AND d.month = m.month
+        GROUP BY pc.part_code, m.month
+    ), variability AS (
+        SELECT
+            monthly.part_code,
+            COUNT(*)::int AS months,
+            AVG(monthly.qty) AS mean_qty,
+            stddev_pop(monthly.qty) / NULLIF(AVG(monthly.qty), 0) * 100 AS cv
+        FROM monthly
+        GROUP BY monthly.part_code
+    ), totals AS (
+        SELECT
+            d.part_code,
+            COUNT(*)::int AS deliveries,
+            COALESCE(SUM(d.qty), 0) AS qty,
+            COALESCE(SUM(d.value), 0) AS value,
+            bool_or(d.value IS NULL) AS rate_missing
+        FROM d
+        GROUP BY d.part_code
+    ), ranked AS (
+        SELECT
+            t.*,
+            COALESCE(t.value / NULLIF(SUM(t.value) OVER (), 0) * 100, 0) AS share,
+            COALESCE(SUM(t.value) OVER (ORDER BY t.value DESC, t.part_code)
+                / NULLIF(SUM(t.value) OVER (), 0) * 100, 0) AS cumulative_share
+        FROM totals t
+    )
+    SELECT
+        r.part_code,
+        p.name,
+        p.default_unit,
+        r.deliveries,
+        r.qty,
+        round(r.value, 2),
+        round(r.share, 4),
+        round(r.cumulative_share, 4),
+        v.months,
+        round(v.mean_qty, 2),
+        round(v.cv, 2),
+        CASE
+            WHEN r.value > 0 AND r.cumulative_share - r.share < p_a THEN 'A'
+            WHEN r.value > 0 AND r.cumulative_share - r.share < p_b THEN 'B'
+            ELSE 'C'
+        END,
+        CASE
+            WHEN v.cv IS NULL OR v.cv > p_y THEN 'Z'
+            WHEN v.cv > p_x THEN 'Y'
+            ELSE 'X'
+        END,
+        r.rate_missing
+    FROM ranked r
+    JOIN parts p
+        ON p.part_code = r.part_code
+    JOIN variability v
+        ON v.part_code = r.part_code
+    ORDER BY r.value DESC, r.part_code;
+$$;
+
 -- filling with example data
+INSERT INTO parts (part_code, name, description, default_unit, weight_per_piece, category) VALUES
+('A100', 'Подшипник шариковый', 'Подшипник 6204, закрытый', 'pcs', 0.110, 'Подшипники'),
+('B200', 'Смазка литиевая', 'Литол-24, фасовка 18 кг', 'kg', NULL, 'Расходные материалы'),
+('C300', 'Комплект прокладок', 'Комплект прокладок двигателя', 'set', 1.200, 'Уплотнения'),
+('D400', 'Кабель силовой', 'ВВГнг 3x2.5', 'm', NULL, 'Электротехника');
+
 INSERT INTO warehouses (manager_surname) VALUES
 ('Иванов'),
 ('Петров'),
@@ -207,13 +2348,38 @@ INSERT INTO warehouses (manager_surname) VALUES
 ('Смирнов'),
 ('Кузнецов');
 
-INSERT INTO contracts VALUES
-(101, 'A100', 'pcs', '2024-03-01', '2024-10-01', 1000, 120.00),
-(101, 'B200', 'kg',  '2024-02-15', '2024-08-15', 500, 800.00),
-(102, 'A100', 'pcs', '2024-03-01', '2024-10-01', 1500, 110.90),
-(103, 'C300', 'set', '2024-01-15', '2024-12-31', 200, 400.00),
-(104, 'D400', 'm',   '2024-04-10', '2024-07-20', 3000, 300.50),
-(105, 'B200', 'kg',  '2024-02-15', '2024-08-15', 700, 700.80);
+INSERT INTO suppliers (name, inn, address, contact_person, phone, email) VALUES
+('ООО "Техснаб"', '1655012345', 'г. Казань, ул. Техническая, 12', 'Гарипов Р.Р.', '+7 843 200-10-10', 'sales@tehsnab.ru'),
+('АО "Волгаметалл"', '1660098765', 'г. Казань, ул. Восстания, 100', 'Миронова Е.А.', '+7 843 210-20-20', 'info@volgametall.ru'),
+('ИП Хасанов А.И.', '165500112233', 'г. Зеленодольск, ул. Ленина, 5', 'Хасанов А.И.', '+7 917 300-30-30', NULL);
+
+INSERT INTO contract_headers (contract_no, supplier_id, signed_date, status) VALUES
+(101, 1, '2024-02-20', 'active'),
+(102, 2, '2024-02-25', 'active'),
+(103, 3, '2024-01-10', 'active'),
+(104, 2, '2024-04-01', 'active'),
+(105, 1, '2024-02-10', 'active'),
+(106, 3, '2024-01-25', 'active'),
+(107, 2, '2024-04-05', 'active');
+
+INSERT INTO contracts (contract_no, part_code, unit, start_date, end_date, plan_qty, contract_price, currency) VALUES
+(101, 'A100', 'pcs', '2024-03-01', '2024-10-01', 1000, 120.00, 'RUB'),
+(101, 'B200', 'kg',  '2024-02-15', '2024-08-15', 500, 800.00, 'RUB'),
+(102, 'A100', 'pcs', '2024-03-01', '2024-10-01', 1500, 110.90, 'RUB'),
+(103, 'C300', 'set', '2024-01-15', '2024-12-31', 200, 400.00, 'RUB'),
+(104, 'D400', 'm',   '2024-04-10', '2024-07-20', 3000, 300.50, 'RUB'),
+(105, 'B200', 'kg',  '2024-02-15', '2024-08-15', 700, 700.80, 'RUB'),
+(106, 'C300', 'set', '2024-02-01', '2024-11-30', 150, 4.80, 'USD'),
+(107, 'D400', 'm',   '2024-04-15', '2024-08-31', 2000, 25.10, 'CNY');
+
+INSERT INTO exchange_rates (currency, rate_date, rate) VALUES
+('USD', '2024-01-01', 89.688800),
+('USD', '2024-02-01', 89.294600),
+('USD', '2024-03-01', 91.601600),
+('USD', '2024-04-01', 92.366300),
+('CNY', '2024-04-01', 12.782100),
+('CNY', '2024-05-01', 12.830600),
+('CNY', '2024-06-01', 12.329400);
 
 INSERT INTO deliveries VALUES
 (1, 1, 101, 'A100', 'pcs', 120, '2024-03-10'),
@@ -227,6 +2393,54 @@ INSERT INTO deliveries VALUES
 (4, 2, 104, 'D400', 'm',   700, '2024-06-14'),
 (5, 1, 105, 'B200', 'kg', 120, '2024-02-28'),
 (5, 2, 105, 'B200', 'kg', 160, '2024-03-20'),
-(5, 3, 105, 'B200', 'kg', 200, '2024-04-25');
+(5, 3, 105, 'B200', 'kg', 200, '2024-04-25'),
+(3, 3, 106, 'C300', 'set', 20,  '2024-03-05'),
+(4, 3, 107, 'D400', 'm',   400, '2024-05-20');
+
+INSERT INTO contract_schedules (contract_no, part_code, tranche_no, due_date, planned_qty) VALUES
+(102, 'A100', 1, '2024-04-01', 500),
+(102, 'A100', 2, '2024-07-01', 500),
+(102, 'A100', 3, '2024-10-01', 500),
+(105, 'B200', 1, '2024-03-15', 200),
+(105, 'B200', 2, '2024-04-15', 200),
+(105, 'B200', 3, '2024-05-15', 150),
+(105, 'B200', 4, '2024-06-15', 150);
+
+INSERT INTO contract_penalty_terms (contract_no, part_code, daily_rate_pct, cap_pct) VALUES
+(101, 'A100', 0.100, 10),
+(102, 'A100', 0.100, 10),
+(105, 'B200', 0.200, 15);
+
+INSERT INTO supplier_invoices (supplier_id, invoice_no, invoice_date, due_date) VALUES
+(1, 'СФ-1024', '2024-04-15', '2024-05-15'),
+(2, '77/2024', '2024-04-05', '2024-05-05');
+
+INSERT INTO invoice_lines (invoice_id, line_no, warehouse_no, receipt_doc_no, qty, unit_price) VALUES
+(1, 1, 1, 1, 120, 120.00),
+(1, 2, 1, 2, 230, 125.00),
+(2, 1, 2, 1, 310, 110.90),
+(2, 2, 2, 2, 410, 110.90);
+
+INSERT INTO supplier_payments (invoice_id, paid_date, amount, reference) VALUES
+(1, '2024-05-10', 14400.00, 'п/п 311'),
+(2, '2024-05-03', 30000.00, 'п/п 298');
+
+INSERT INTO budgets (period_month, category, limit_amount, enforcement) VALUES
+('2024-04-01', NULL,          250000.00, 'warn'),
+('2024-08-01', 'Расходные материалы', 1000000.00, 'block'),
+('2024-10-01', 'Подшипники',  300000.00, 'warn');
+
+INSERT INTO contract_assignments (contract_no, part_code, buyer, status, note) VALUES
+(102, 'A100', 'Смирнова', 'in_progress', 'Запрошен график отгрузки'),
+(104, 'D400', 'Кузнецов', 'assigned', NULL);
+
+INSERT INTO delivery_lots (warehouse_no, receipt_doc_no, lot_no, qty, manufactured_date, expiry_date) VALUES
+(1, 3, 'L24-0211', 50,  '2024-02-11', '2026-02-11'),
+(3, 1, 'G-0412',   10,  '2024-01-15', '2029-01-15'),
+(3, 2, 'G-0431',   15,  '2024-02-26', '2029-02-26'),
+(5, 1, 'L24-0129', 120, '2024-01-29', '2026-01-29'),
+(5, 2, 'L24-0305', 100, '2024-03-05', '2026-03-05'),
+(5, 2, 'L24-0311', 60,  '2024-03-11', '2026-03-11'),
+(5, 3, 'L24-0415', 200, '2024-04-15', '2026-04-15');
 
 COMMIT;
\ No newline at end of file
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/railgorail/kpfu-db-app/internal/config"
 	"github.com/railgorail/kpfu-db-app/internal/database"
 	"github.com/railgorail/kpfu-db-app/internal/handler"
+	"github.com/railgorail/kpfu-db-app/internal/jobs"
 	"github.com/railgorail/kpfu-db-app/internal/repository"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -30,6 +33,8 @@ func main() {
 	repo := repository.NewWithGORM(dbpool, gormDB)
 	h := handler.New(repo)
 
+	go jobs.RunTrashRetention(context.Background(), repo, cfg.TrashRetentionDays, 24*time.Hour)
+
 	r := gin.Default()
 	r.LoadHTMLGlob("web/templates/*.html")
 	r.Static("/static", "./static")
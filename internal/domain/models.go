@@ -4,32 +4,48 @@ import "time"
 
 // Warehouse represents a warehouse in the database.
 type Warehouse struct {
-	WarehouseNo    int    `json:"warehouse_no"`
-	ManagerSurname string `json:"manager_surname"`
+	WarehouseNo    int        `json:"warehouse_no"`
+	ManagerSurname string     `json:"manager_surname"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Part is an entry of the parts catalog.
+type Part struct {
+	PartCode       string   `json:"part_code"`
+	Name           string   `json:"name"`
+	Description    *string  `json:"description"`
+	DefaultUnit    string   `json:"default_unit"`
+	WeightPerPiece *float64 `json:"weight_per_piece"`
+	Category       *string  `json:"category"`
 }
 
 // Contract represents a contract in the database.
 type Contract struct {
-	ContractNo    int       `json:"contract_no"`
-	PartCode      string    `json:"part_code"`
-	Unit          string    `json:"unit"`
-	StartDate     time.Time `json:"start_date"`
-	EndDate       time.Time `json:"end_date"`
-	PlanQty       float64   `json:"plan_qty"`
-	ContractPrice float64   `json:"contract_price"`
+	ContractNo    int        `json:"contract_no"`
+	PartCode      string     `json:"part_code"`
+	Unit          string     `json:"unit"`
+	StartDate     time.Time  `json:"start_date"`
+	EndDate       time.Time  `json:"end_date"`
+	PlanQty       float64    `json:"plan_qty"`
+	ContractPrice float64    `json:"contract_price"`
+	Currency      string     `json:"currency"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+
+	Forecast *Forecast `gorm:"-" json:"forecast,omitempty"`
 }
 
 // Delivery represents a delivery in the database.
 type Delivery struct {
-	WarehouseNo  int       `json:"warehouse_no"`
-	ReceiptDocNo int       `json:"receipt_doc_no"`
-	ContractNo   int       `json:"contract_no"`
-	PartCode     string    `json:"part_code"`
-	Unit         string    `json:"unit"`
-	Qty          float64   `json:"qty"`
-	ReceivedDate time.Time `json:"received_date"`
+	WarehouseNo  int        `json:"warehouse_no"`
+	ReceiptDocNo int        `json:"receipt_doc_no"`
+	ContractNo   int        `json:"contract_no"`
+	PartCode     string     `json:"part_code"`
+	Unit         string     `json:"unit"`
+	Qty          float64    `json:"qty"`
+	ReceivedDate time.Time  `json:"received_date"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 
-	Contract     Contract `gorm:"foreignKey:ContractNo;references:ContractNo"`
+	Contract Contract `gorm:"foreignKey:ContractNo;references:ContractNo" json:"-"`
 }
 
 type View struct {
@@ -41,6 +57,7 @@ type View struct {
 	ReceiptDocNo int       `json:"receipt_doc_no"`
 	ReceivedDate time.Time `json:"received_date"`
 	Qty          float64   `json:"qty"`
+	AcceptedQty  float64   `json:"accepted_qty"`
 	DeliveryUnit string    `json:"delivery_unit"`
 
 	ContractNo int    `json:"contract_no"`
@@ -52,6 +69,17 @@ type View struct {
 	EndDate       time.Time `json:"end_date"`
 	PlanQty       float64   `json:"plan_qty"`
 	ContractPrice float64   `json:"contract_price"`
+
+	// From contract_headers and suppliers
+	SupplierName string `json:"supplier_name"`
+
+	// Value of AcceptedQty at the contract price in effect on received_date, in
+	// the contract currency and in the base currency at the rate of received_date;
+	// ExchangeRate and BaseValue are nil while the rate is not loaded
+	Currency     string   `json:"currency"`
+	ExchangeRate *float64 `json:"exchange_rate"`
+	Value        float64  `json:"value"`
+	BaseValue    *float64 `json:"base_value"`
 }
 
 type Task1 struct {
@@ -62,15 +90,9 @@ type Task1 struct {
 	Qty           float64   `json:"qty"`
 	ContractNo    int       `json:"contract_no"`
 	ContractPrice float64   `json:"contract_price"`
-}
-
-type Task2 struct {
-	ContractNo int       `json:"contract_no"`
-	PartCode   string    `json:"part_code"`
-	PlanQty    float64   `json:"plan_qty"`
-	EndDate    time.Time `json:"end_date"`
-	SumQty     float64   `json:"sum_qty"`
-	Priotity   int       `json:"priority"`
+	Currency      string    `json:"currency"`
+	ExchangeRate  *float64  `json:"exchange_rate"`
+	BasePrice     *float64  `json:"base_price"`
 }
 
 // ContractSummary represents the result of p_contract_summary procedure
@@ -80,3 +102,768 @@ type ContractSummary struct {
 	TotalDelivered *float64 `json:"total_delivered"` // pointer to handle NULL
 	ContractPrice  *float64 `json:"contract_price"`  // pointer to handle NULL
 }
+
+// KeyChange is an entry of the key_changes audit log.
+type KeyChange struct {
+	ID        int       `json:"id"`
+	Entity    string    `json:"entity"`
+	OldKey    string    `json:"old_key"`
+	NewKey    string    `json:"new_key"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// DeletePreview describes what deleting a warehouse or a contract line would do.
+// Action is "cascade" when the listed deliveries are removed with the row and
+// "block" when they prevent the delete.
+type DeletePreview struct {
+	Entity     string     `json:"entity"`
+	Key        string     `json:"key"`
+	Action     string     `json:"action"`
+	Deliveries []Delivery `json:"deliveries"`
+	Token      string     `json:"confirm_token"`
+}
+
+// Trash holds soft-deleted rows waiting to be restored or purged.
+type Trash struct {
+	Warehouses []Warehouse `json:"warehouses"`
+	Contracts  []Contract  `json:"contracts"`
+	Deliveries []Delivery  `json:"deliveries"`
+}
+
+// ContractVersion is the terms of a contract line valid in [ValidFrom, ValidTo).
+// ValidTo is nil for the current version.
+type ContractVersion struct {
+	ContractNo    int        `json:"contract_no"`
+	PartCode      string     `json:"part_code"`
+	ValidFrom     time.Time  `json:"valid_from"`
+	ValidTo       *time.Time `json:"valid_to"`
+	Unit          string     `json:"unit"`
+	StartDate     time.Time  `json:"start_date"`
+	EndDate       time.Time  `json:"end_date"`
+	PlanQty       float64    `json:"plan_qty"`
+	ContractPrice float64    `json:"contract_price"`
+}
+
+// Amendment statuses.
+const (
+	AmendmentDraft     = "draft"
+	AmendmentSubmitted = "submitted"
+	AmendmentApproved  = "approved"
+	AmendmentRejected  = "rejected"
+)
+
+// ContractAmendment is a requested change of a contract line's commercial terms.
+// Nil fields are left unchanged when the amendment is applied.
+type ContractAmendment struct {
+	ID              int        `json:"id"`
+	ContractNo      int        `json:"contract_no"`
+	PartCode        string     `json:"part_code"`
+	Status          string     `json:"status"`
+	StartDate       *time.Time `json:"start_date"`
+	EndDate         *time.Time `json:"end_date"`
+	PlanQty         *float64   `json:"plan_qty"`
+	ContractPrice   *float64   `json:"contract_price"`
+	Reason          string     `json:"reason"`
+	RequestedBy     string     `json:"requested_by"`
+	DecidedBy       *string    `json:"decided_by"`
+	DecisionComment *string    `json:"decision_comment"`
+	CreatedAt       time.Time  `json:"created_at"`
+	SubmittedAt     *time.Time `json:"submitted_at"`
+	DecidedAt       *time.Time `json:"decided_at"`
+}
+
+// Issue is an outbound movement of parts from a warehouse.
+type Issue struct {
+	WarehouseNo int       `json:"warehouse_no"`
+	IssueDocNo  int       `json:"issue_doc_no"`
+	PartCode    string    `json:"part_code"`
+	Unit        string    `json:"unit"`
+	Qty         float64   `json:"qty"`
+	IssuedDate  time.Time `json:"issued_date"`
+	Recipient   string    `json:"recipient"`
+	// Lot the parts were issued from, if any.
+	OriginWarehouseNo  *int    `json:"origin_warehouse_no"`
+	OriginReceiptDocNo *int    `json:"origin_receipt_doc_no"`
+	LotNo              *string `json:"lot_no"`
+}
+
+// StockBalance is a row of the stock_balance view.
+type StockBalance struct {
+	WarehouseNo    int     `json:"warehouse_no"`
+	ManagerSurname string  `json:"manager_surname"`
+	PartCode       string  `json:"part_code"`
+	Unit           string  `json:"unit"`
+	ReceivedQty    float64 `json:"received_qty"`
+	IssuedQty      float64 `json:"issued_qty"`
+	BalanceQty     float64 `json:"balance_qty"`
+}
+
+// Transfer statuses.
+const (
+	TransferShipped  = "shipped"
+	TransferReceived = "received"
+)
+
+// Transfer moves parts from one warehouse to another. The source balance drops when
+// the transfer is shipped, the target balance grows when it is received.
+type Transfer struct {
+	TransferNo      int            `json:"transfer_no"`
+	FromWarehouseNo int            `json:"from_warehouse_no"`
+	ToWarehouseNo   int            `json:"to_warehouse_no"`
+	Status          string         `json:"status"`
+	ShippedDate     time.Time      `json:"shipped_date"`
+	ReceivedDate    *time.Time     `json:"received_date"`
+	Lines           []TransferLine `json:"lines"`
+}
+
+// TransferLine is a line of a transfer. Origin* point to the contract delivery the parts came from.
+type TransferLine struct {
+	LineNo             int     `json:"line_no"`
+	PartCode           string  `json:"part_code"`
+	Unit               string  `json:"unit"`
+	Qty                float64 `json:"qty"`
+	OriginWarehouseNo  *int    `json:"origin_warehouse_no"`
+	OriginReceiptDocNo *int    `json:"origin_receipt_doc_no"`
+	LotNo              *string `json:"lot_no"`
+}
+
+// StockMovement is a row of the stock_movements view. Qty is positive for inbound movements.
+type StockMovement struct {
+	WarehouseNo        int       `json:"warehouse_no"`
+	MovementDate       time.Time `json:"movement_date"`
+	MovementType       string    `json:"movement_type"`
+	DocNo              int       `json:"doc_no"`
+	PartCode           string    `json:"part_code"`
+	Unit               string    `json:"unit"`
+	Qty                float64   `json:"qty"`
+	ContractNo         *int      `json:"contract_no"`
+	OriginWarehouseNo  *int      `json:"origin_warehouse_no"`
+	OriginReceiptDocNo *int      `json:"origin_receipt_doc_no"`
+}
+
+// LedgerEntry is a row of the append-only stock_ledger. Qty is signed, a reversing
+// entry carries the id of the entry it cancels in ReversesID.
+type LedgerEntry struct {
+	ID           int       `json:"id"`
+	PostedAt     time.Time `json:"posted_at"`
+	MovementDate time.Time `json:"movement_date"`
+	WarehouseNo  int       `json:"warehouse_no"`
+	PartCode     string    `json:"part_code"`
+	Unit         string    `json:"unit"`
+	Qty          float64   `json:"qty"`
+	SourceType   string    `json:"source_type"`
+	SourceRef    string    `json:"source_ref"`
+	ReversesID   *int      `json:"reverses_id"`
+	Comment      *string   `json:"comment"`
+}
+
+// AccountingPeriod is a month of the ledger. ClosedAt is nil while the period is open.
+type AccountingPeriod struct {
+	Period   time.Time  `json:"period"`
+	Entries  int        `json:"entries"`
+	ClosedAt *time.Time `json:"closed_at"`
+	ClosedBy *string    `json:"closed_by"`
+}
+
+// Stock count statuses.
+const (
+	StockCountDraft    = "draft"
+	StockCountApproved = "approved"
+)
+
+// StockCount is a physical inventory count sheet of a warehouse. ManagerSurname is
+// the materially responsible person at the time of the count.
+type StockCount struct {
+	CountNo        int              `json:"count_no"`
+	WarehouseNo    int              `json:"warehouse_no"`
+	ManagerSurname string           `json:"manager_surname"`
+	CountDate      time.Time        `json:"count_date"`
+	Status         string           `json:"status"`
+	CreatedAt      time.Time        `json:"created_at"`
+	ApprovedBy     *string          `json:"approved_by"`
+	ApprovedAt     *time.Time       `json:"approved_at"`
+	Lines          []StockCountLine `json:"lines,omitempty"`
+}
+
+// StockCountLine is a line of a count sheet. CountedQty and VarianceQty stay nil
+// until the part has been counted.
+type StockCountLine struct {
+	PartCode    string   `json:"part_code"`
+	Unit        string   `json:"unit"`
+	ExpectedQty float64  `json:"expected_qty"`
+	CountedQty  *float64 `json:"counted_qty"`
+	VarianceQty *float64 `json:"variance_qty"`
+}
+
+// StockCountVariance is a row of the stock_count_variances view. VarianceQty is
+// negative for a shortage and positive for a surplus.
+type StockCountVariance struct {
+	CountNo        int       `json:"count_no"`
+	WarehouseNo    int       `json:"warehouse_no"`
+	ManagerSurname string    `json:"manager_surname"`
+	CountDate      time.Time `json:"count_date"`
+	ApprovedBy     string    `json:"approved_by"`
+	PartCode       string    `json:"part_code"`
+	Unit           string    `json:"unit"`
+	ExpectedQty    float64   `json:"expected_qty"`
+	CountedQty     float64   `json:"counted_qty"`
+	VarianceQty    float64   `json:"variance_qty"`
+}
+
+// ManagerVariance sums up the approved counts of one materially responsible person.
+type ManagerVariance struct {
+	ManagerSurname string  `json:"manager_surname"`
+	Counts         int     `json:"counts"`
+	VarianceLines  int     `json:"variance_lines"`
+	ShortageQty    float64 `json:"shortage_qty"`
+	SurplusQty     float64 `json:"surplus_qty"`
+}
+
+// Supplier is a counterparty of contracts.
+type Supplier struct {
+	SupplierID    int     `json:"supplier_id"`
+	Name          string  `json:"name"`
+	INN           string  `json:"inn"`
+	Address       *string `json:"address"`
+	ContactPerson *string `json:"contact_person"`
+	Phone         *string `json:"phone"`
+	Email         *string `json:"email"`
+}
+
+// Contract header statuses.
+const (
+	ContractDraft      = "draft"
+	ContractActive     = "active"
+	ContractClosed     = "closed"
+	ContractTerminated = "terminated"
+)
+
+// ContractHeader is the part of a contract shared by all its lines in contracts.
+type ContractHeader struct {
+	ContractNo   int       `json:"contract_no"`
+	SupplierID   int       `json:"supplier_id"`
+	SupplierName string    `json:"supplier_name"`
+	SignedDate   time.Time `json:"signed_date"`
+	Status       string    `json:"status"`
+	Lines        int       `json:"lines"`
+}
+
+// SupplierDeliveries sums up the deliveries of one part from one supplier. Value
+// uses the contract price in effect on each received_date.
+type SupplierDeliveries struct {
+	SupplierID   int      `json:"supplier_id"`
+	SupplierName string   `json:"supplier_name"`
+	INN          string   `json:"inn"`
+	PartCode     string   `json:"part_code"`
+	Unit         string   `json:"unit"`
+	Deliveries   int      `json:"deliveries"`
+	Qty          float64  `json:"qty"`
+	Currency     string   `json:"currency"`
+	Value        float64  `json:"value"`
+	BaseValue    *float64 `json:"base_value"` // nil when a rate of some delivery date is not loaded
+}
+
+// SupplierScore is a row of fn_supplier_scorecard. OnTimeRate is the share of
+// due tranches covered by their due date, nil while none is due.
+type SupplierScore struct {
+	SupplierID        int      `json:"supplier_id"`
+	SupplierName      string   `json:"supplier_name"`
+	Lines             int      `json:"lines"`
+	Deliveries        int      `json:"deliveries"`
+	OnTimeRate        *float64 `json:"on_time_rate"`
+	FillRate          float64  `json:"fill_rate"`
+	PartialDeliveries int      `json:"partial_deliveries"`
+	RejectRate        float64  `json:"reject_rate"`
+	Score             float64  `json:"score"`
+	Rank              int      `json:"rank"`
+}
+
+// SupplierLinePerformance is a row of fn_supplier_line_performance. A line
+// without a schedule is one tranche of PlanQty due on EndDate; DueTranches
+// counts the tranches covered or past due, OnTimeTranches those covered in
+// time, and PartialDeliveries the receipts that fall short of the tranche
+// they start to cover.
+type SupplierLinePerformance struct {
+	ContractNo        int        `json:"contract_no"`
+	PartCode          string     `json:"part_code"`
+	Unit              string     `json:"unit"`
+	EndDate           time.Time  `json:"end_date"`
+	PlanQty           float64    `json:"plan_qty"`
+	DeliveredQty      float64    `json:"delivered_qty"`
+	RejectedQty       float64    `json:"rejected_qty"`
+	ReturnedQty       float64    `json:"returned_qty"`
+	Deliveries        int        `json:"deliveries"`
+	DueTranches       int        `json:"due_tranches"`
+	OnTimeTranches    int        `json:"on_time_tranches"`
+	PartialDeliveries int        `json:"partial_deliveries"`
+	LastReceivedDate  *time.Time `json:"last_received_date"`
+}
+
+// DeliveryInspection is the incoming quality check of a delivery. Only
+// AcceptedQty is put on stock and counts towards the contract.
+type DeliveryInspection struct {
+	WarehouseNo  int       `json:"warehouse_no"`
+	ReceiptDocNo int       `json:"receipt_doc_no"`
+	ContractNo   int       `json:"contract_no"`
+	PartCode     string    `json:"part_code"`
+	Unit         string    `json:"unit"`
+	ReceivedDate time.Time `json:"received_date"`
+	AcceptedQty  float64   `json:"accepted_qty"`
+	RejectedQty  float64   `json:"rejected_qty"`
+	ReturnedQty  float64   `json:"returned_qty"`
+	Reason       *string   `json:"reason"`
+	Inspector    string    `json:"inspector"`
+	InspectedAt  time.Time `json:"inspected_at"`
+}
+
+// SupplierReturn sends rejected parts of an inspected delivery back to the supplier.
+type SupplierReturn struct {
+	ReturnNo     int       `json:"return_no"`
+	WarehouseNo  int       `json:"warehouse_no"`
+	ReceiptDocNo int       `json:"receipt_doc_no"`
+	PartCode     string    `json:"part_code"`
+	Unit         string    `json:"unit"`
+	Qty          float64   `json:"qty"`
+	ReturnedDate time.Time `json:"returned_date"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DeliveryLot is a manufacturer lot received with a delivery.
+type DeliveryLot struct {
+	WarehouseNo      int       `json:"warehouse_no"`
+	ReceiptDocNo     int       `json:"receipt_doc_no"`
+	LotNo            string    `json:"lot_no"`
+	Qty              float64   `json:"qty"`
+	ManufacturedDate time.Time `json:"manufactured_date"`
+	ExpiryDate       time.Time `json:"expiry_date"`
+}
+
+// LotBalance is a row of the lot_balance view: what is left of a lot in one warehouse.
+type LotBalance struct {
+	WarehouseNo        int       `json:"warehouse_no"`
+	ManagerSurname     string    `json:"manager_surname"`
+	LotNo              string    `json:"lot_no"`
+	OriginWarehouseNo  int       `json:"origin_warehouse_no"`
+	OriginReceiptDocNo int       `json:"origin_receipt_doc_no"`
+	ContractNo         int       `json:"contract_no"`
+	PartCode           string    `json:"part_code"`
+	Unit               string    `json:"unit"`
+	ManufacturedDate   time.Time `json:"manufactured_date"`
+	ExpiryDate         time.Time `json:"expiry_date"`
+	Qty                float64   `json:"qty"`
+}
+
+// FEFOPick is a row of fn_fefo_pick.
+type FEFOPick struct {
+	LotNo              string    `json:"lot_no"`
+	OriginWarehouseNo  int       `json:"origin_warehouse_no"`
+	OriginReceiptDocNo int       `json:"origin_receipt_doc_no"`
+	ContractNo         int       `json:"contract_no"`
+	ManufacturedDate   time.Time `json:"manufactured_date"`
+	ExpiryDate         time.Time `json:"expiry_date"`
+	AvailableQty       float64   `json:"available_qty"`
+	TakeQty            float64   `json:"take_qty"`
+}
+
+// ScheduleTranche is a planned partial delivery of a contract line.
+type ScheduleTranche struct {
+	ContractNo int       `json:"contract_no"`
+	PartCode   string    `json:"part_code"`
+	TrancheNo  int       `json:"tranche_no"`
+	DueDate    time.Time `json:"due_date"`
+	PlannedQty float64   `json:"planned_qty"`
+}
+
+// Tranche statuses of fn_tranche_status.
+const (
+	TrancheOnTime = "on_time"
+	TrancheLate   = "late"
+	TrancheShort  = "short"
+	TrancheOpen   = "open"
+)
+
+// TrancheStatus is a row of fn_tranche_status: a tranche matched against the
+// accepted deliveries of its line.
+type TrancheStatus struct {
+	ContractNo  int        `json:"contract_no"`
+	PartCode    string     `json:"part_code"`
+	Unit        string     `json:"unit"`
+	TrancheNo   int        `json:"tranche_no"`
+	DueDate     time.Time  `json:"due_date"`
+	PlannedQty  float64    `json:"planned_qty"`
+	MatchedQty  float64    `json:"matched_qty"`
+	CoveredDate *time.Time `json:"covered_date"`
+	Status      string     `json:"status"`
+	DaysLate    int        `json:"days_late"`
+}
+
+// SchedulePriority is a row of fn_schedule_priority.
+type SchedulePriority struct {
+	ContractNo     int        `json:"contract_no"`
+	PartCode       string     `json:"part_code"`
+	Unit           string     `json:"unit"`
+	PlanQty        float64    `json:"plan_qty"`
+	EndDate        time.Time  `json:"end_date"`
+	NextDueDate    *time.Time `json:"next_due_date"`
+	OverdueQty     float64    `json:"overdue_qty"`
+	OutstandingQty float64    `json:"outstanding_qty"`
+	Priority       int        `json:"priority"`
+}
+
+// PenaltyTerms are the late-delivery penalty terms of a contract line.
+type PenaltyTerms struct {
+	ContractNo   int     `json:"contract_no"`
+	PartCode     string  `json:"part_code"`
+	DailyRatePct float64 `json:"daily_rate_pct"`
+	CapPct       float64 `json:"cap_pct"`
+}
+
+// PenaltyEvent is a row of fn_penalty_events. The delivery fields are nil for
+// quantity still not delivered.
+type PenaltyEvent struct {
+	ContractNo   int        `json:"contract_no"`
+	PartCode     string     `json:"part_code"`
+	Unit         string     `json:"unit"`
+	TrancheNo    *int       `json:"tranche_no"`
+	DueDate      time.Time  `json:"due_date"`
+	WarehouseNo  *int       `json:"warehouse_no"`
+	ReceiptDocNo *int       `json:"receipt_doc_no"`
+	ReceivedDate *time.Time `json:"received_date"`
+	LateQty      float64    `json:"late_qty"`
+	DaysLate     int        `json:"days_late"`
+	Price        float64    `json:"price"`
+	Amount       float64    `json:"amount"`
+}
+
+// PenaltyClaim is a row of fn_penalty_claims: the capped penalty of one contract line.
+type PenaltyClaim struct {
+	SupplierID   int     `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	ContractNo   int     `json:"contract_no"`
+	PartCode     string  `json:"part_code"`
+	Unit         string  `json:"unit"`
+	DailyRatePct float64 `json:"daily_rate_pct"`
+	CapPct       float64 `json:"cap_pct"`
+	LateQty      float64 `json:"late_qty"`
+	MaxDaysLate  int     `json:"max_days_late"`
+	Accrued      float64 `json:"accrued"`
+	CapAmount    float64 `json:"cap_amount"`
+	Penalty      float64 `json:"penalty"`
+}
+
+// Invoice is a supplier invoice. Amount is the sum of its lines, PaidAmount of its payments.
+type Invoice struct {
+	InvoiceID    int           `json:"invoice_id"`
+	SupplierID   int           `json:"supplier_id"`
+	SupplierName string        `json:"supplier_name"`
+	InvoiceNo    string        `json:"invoice_no"`
+	InvoiceDate  time.Time     `json:"invoice_date"`
+	DueDate      time.Time     `json:"due_date"`
+	Amount       float64       `json:"amount"`
+	PaidAmount   float64       `json:"paid_amount"`
+	Mismatches   int           `json:"mismatches"`
+	Lines        []InvoiceLine `json:"lines,omitempty"`
+	Payments     []Payment     `json:"payments,omitempty"`
+}
+
+// InvoiceLine bills one delivery. The delivery key is nil once the delivery is purged.
+type InvoiceLine struct {
+	LineNo       int     `json:"line_no"`
+	WarehouseNo  *int    `json:"warehouse_no"`
+	ReceiptDocNo *int    `json:"receipt_doc_no"`
+	Qty          float64 `json:"qty"`
+	UnitPrice    float64 `json:"unit_price"`
+	Amount       float64 `json:"amount"`
+}
+
+// Payment is a payment against a supplier invoice.
+type Payment struct {
+	PaymentID int       `json:"payment_id"`
+	InvoiceID int       `json:"invoice_id"`
+	PaidDate  time.Time `json:"paid_date"`
+	Amount    float64   `json:"amount"`
+	Reference *string   `json:"reference"`
+}
+
+// InvoiceMatch is a row of the invoice_matching view.
+type InvoiceMatch struct {
+	InvoiceID       int        `json:"invoice_id"`
+	SupplierID      int        `json:"supplier_id"`
+	InvoiceNo       string     `json:"invoice_no"`
+	LineNo          int        `json:"line_no"`
+	WarehouseNo     *int       `json:"warehouse_no"`
+	ReceiptDocNo    *int       `json:"receipt_doc_no"`
+	ContractNo      *int       `json:"contract_no"`
+	PartCode        *string    `json:"part_code"`
+	Unit            *string    `json:"unit"`
+	ReceivedDate    *time.Time `json:"received_date"`
+	ReceivedQty     *float64   `json:"received_qty"`
+	ContractPrice   *float64   `json:"contract_price"`
+	InvoicedQty     float64    `json:"invoiced_qty"`
+	UnitPrice       float64    `json:"unit_price"`
+	InvoicedAmount  float64    `json:"invoiced_amount"`
+	ExpectedAmount  *float64   `json:"expected_amount"`
+	DeliveryMissing bool       `json:"delivery_missing"`
+	QtyMismatch     bool       `json:"qty_mismatch"`
+	PriceMismatch   bool       `json:"price_mismatch"`
+	AmountMismatch  bool       `json:"amount_mismatch"`
+}
+
+// APAging is a row of fn_ap_aging.
+type APAging struct {
+	InvoiceID    int       `json:"invoice_id"`
+	SupplierID   int       `json:"supplier_id"`
+	SupplierName string    `json:"supplier_name"`
+	InvoiceNo    string    `json:"invoice_no"`
+	InvoiceDate  time.Time `json:"invoice_date"`
+	DueDate      time.Time `json:"due_date"`
+	Amount       float64   `json:"amount"`
+	Paid         float64   `json:"paid"`
+	OpenAmount   float64   `json:"open_amount"`
+	DaysOverdue  int       `json:"days_overdue"`
+	Bucket       string    `json:"bucket"`
+}
+
+// APAgingSummary totals the open invoice amounts of one supplier by aging bucket.
+type APAgingSummary struct {
+	SupplierID   int     `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	Current      float64 `json:"current"`
+	Days1To30    float64 `json:"days_1_30"`
+	Days31To60   float64 `json:"days_31_60"`
+	Days61To90   float64 `json:"days_61_90"`
+	Over90       float64 `json:"over_90"`
+	Total        float64 `json:"total"`
+}
+
+// BaseCurrency is the currency reports convert contract values into.
+const BaseCurrency = "RUB"
+
+// ExchangeRate is the number of base currency units per unit of Currency,
+// in effect from RateDate until the next loaded date.
+type ExchangeRate struct {
+	Currency string    `json:"currency"`
+	RateDate time.Time `json:"rate_date"`
+	Rate     float64   `json:"rate"`
+}
+
+// Budget enforcement modes.
+const (
+	BudgetWarn  = "warn"
+	BudgetBlock = "block"
+)
+
+// Budget is a monthly limit on contract commitments in the base currency, for one
+// part category or for all of them when Category is nil. Committed, Spent and
+// Remaining come from the budget_usage view.
+type Budget struct {
+	BudgetID    int       `json:"budget_id"`
+	PeriodMonth time.Time `json:"period_month"`
+	Category    *string   `json:"category"`
+	LimitAmount float64   `json:"limit_amount"`
+	Enforcement string    `json:"enforcement"`
+	Committed   float64   `json:"committed"`
+	Spent       float64   `json:"spent"`
+	Remaining   float64   `json:"remaining"`
+	RateMissing bool      `json:"rate_missing"`
+}
+
+// Commitment totals commitment_by_month over one reporting group: a contract line,
+// supplier, part, category or month. Amounts are in the base currency.
+type Commitment struct {
+	Group       string  `json:"group"`
+	Committed   float64 `json:"committed"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	RateMissing bool    `json:"rate_missing"`
+}
+
+// Dashboard holds the aggregates of the dashboard page for the week window
+// ending on On. Month KPIs cover the calendar month of On.
+type Dashboard struct {
+	On               string            `json:"on"`
+	From             string            `json:"from"`
+	MonthDeliveries  int               `json:"month_deliveries"`
+	MonthValue       float64           `json:"month_value"`
+	MonthRateMissing bool              `json:"month_rate_missing"`
+	OpenLines        int               `json:"open_lines"`
+	AtRisk           []AtRiskContract  `json:"at_risk"`
+	TopParts         []PartVolume      `json:"top_parts"`
+	Warehouses       []WarehouseVolume `json:"warehouses"`
+	WeeklyReceipts   []WeeklyReceipt   `json:"weekly_receipts"`
+}
+
+// AtRiskContract is a live contract line not yet fully delivered that is overdue
+// or due within the next 30 days. Reason is "overdue" or "due_soon".
+type AtRiskContract struct {
+	ContractNo   int        `json:"contract_no"`
+	PartCode     string     `json:"part_code"`
+	SupplierName string     `json:"supplier_name"`
+	Unit         string     `json:"unit"`
+	PlanQty      float64    `json:"plan_qty"`
+	AcceptedQty  float64    `json:"accepted_qty"`
+	DueDate      time.Time  `json:"due_date"`
+	Reason       string     `json:"reason"`
+	LastReceived *time.Time `json:"last_received"`
+}
+
+// PartVolume is the accepted quantity and base currency value received of a part.
+type PartVolume struct {
+	PartCode   string  `json:"part_code"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	Deliveries int     `json:"deliveries"`
+	Qty        float64 `json:"qty"`
+	Value      float64 `json:"value"`
+}
+
+// WarehouseVolume is the number and base currency value of deliveries received by a warehouse.
+type WarehouseVolume struct {
+	WarehouseNo    int     `json:"warehouse_no"`
+	ManagerSurname string  `json:"manager_surname"`
+	Deliveries     int     `json:"deliveries"`
+	Value          float64 `json:"value"`
+}
+
+// WeeklyReceipt is the quantity received in one unit during the week starting on Week.
+type WeeklyReceipt struct {
+	Week time.Time `json:"week"`
+	Unit string    `json:"unit"`
+	Qty  float64   `json:"qty"`
+}
+
+// Pivot is a cross-tab of deliveries with warehouses, optionally split by Group,
+// as rows and months as columns. Measure is "qty" or "value"; quantities are
+// kept apart per unit and values are in the base currency.
+type Pivot struct {
+	Measure string     `json:"measure"`
+	GroupBy string     `json:"group_by"`
+	Months  []string   `json:"months"`
+	Rows    []PivotRow `json:"rows"`
+	Totals  []PivotRow `json:"totals"`
+}
+
+// PivotRow holds the cells of one pivot row in the order of Pivot.Months.
+// Subtotal rows sum the groups of a warehouse and leave Group empty.
+type PivotRow struct {
+	WarehouseNo    int       `json:"warehouse_no"`
+	ManagerSurname string    `json:"manager_surname"`
+	Group          string    `json:"group"`
+	Unit           string    `json:"unit"`
+	Cells          []float64 `json:"cells"`
+	Total          float64   `json:"total"`
+	Subtotal       bool      `json:"subtotal"`
+	RateMissing    bool      `json:"rate_missing"`
+}
+
+// ABCXYZThresholds configures the ABC/XYZ classification: A and B are the
+// cumulative value shares in percent closing the A and B classes, X and Y the
+// coefficients of variation in percent closing the X and Y classes.
+type ABCXYZThresholds struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// DefaultABCXYZThresholds are the usual 80/95 and 10/25 splits.
+var DefaultABCXYZThresholds = ABCXYZThresholds{A: 80, B: 95, X: 10, Y: 25}
+
+// PartClass is the ABC/XYZ class of a part. Qty, MeanQty and CV count deliveries
+// in the part's default unit only; CV is nil when none were delivered.
+type PartClass struct {
+	PartCode        string   `json:"part_code"`
+	Name            string   `json:"name"`
+	Unit            string   `json:"unit"`
+	Deliveries      int      `json:"deliveries"`
+	Qty             float64  `json:"qty"`
+	Value           float64  `json:"value"`
+	Share           float64  `json:"share"`
+	CumulativeShare float64  `json:"cumulative_share"`
+	Months          int      `json:"months"`
+	MeanQty         float64  `json:"mean_qty"`
+	CV              *float64 `json:"cv"`
+	ABC             string   `json:"abc"`
+	XYZ             string   `json:"xyz"`
+	RateMissing     bool     `json:"rate_missing"`
+}
+
+// ABCXYZCell sums up the parts of one ABC and XYZ class pair.
+type ABCXYZCell struct {
+	ABC   string  `json:"abc"`
+	XYZ   string  `json:"xyz"`
+	Parts int     `json:"parts"`
+	Value float64 `json:"value"`
+	Share float64 `json:"share"`
+}
+
+// Forecast statuses of a contract line.
+const (
+	ForecastComplete   = "complete"
+	ForecastOnTrack    = "on_track"
+	ForecastAtRisk     = "at_risk"
+	ForecastShort      = "short"
+	ForecastNotStarted = "not_started"
+	ForecastUnknown    = "unknown"
+)
+
+// Forecast projects the accepted quantity of a contract line at its end date
+// from the weekly receipts up to On. LowQty and HighQty bound the projection
+// with 95% confidence; ExpectedDate is when plan_qty was or should be reached,
+// nil when nothing has been received yet. Status is at_risk when the projection
+// is below plan and short when even HighQty is.
+type Forecast struct {
+	ContractNo   int        `json:"contract_no"`
+	PartCode     string     `json:"part_code"`
+	SupplierName string     `json:"supplier_name"`
+	Unit         string     `json:"unit"`
+	StartDate    time.Time  `json:"start_date"`
+	EndDate      time.Time  `json:"end_date"`
+	PlanQty      float64    `json:"plan_qty"`
+	AcceptedQty  float64    `json:"accepted_qty"`
+	On           time.Time  `json:"on"`
+	WeeksElapsed int        `json:"weeks_elapsed"`
+	WeeklyRate   float64    `json:"weekly_rate"`
+	ProjectedQty float64    `json:"projected_qty"`
+	LowQty       float64    `json:"low_qty"`
+	HighQty      float64    `json:"high_qty"`
+	ShortfallQty float64    `json:"shortfall_qty"`
+	ExpectedDate *time.Time `json:"expected_date"`
+	Status       string     `json:"status"`
+}
+
+// Assignment statuses of a contract line in the expediting queue.
+const (
+	AssignmentAssigned   = "assigned"
+	AssignmentInProgress = "in_progress"
+	AssignmentEscalated  = "escalated"
+	AssignmentResolved   = "resolved"
+	// AssignmentUnassigned is recorded in the history when the buyer is removed.
+	AssignmentUnassigned = "unassigned"
+)
+
+// AssignmentStatuses lists the assignment statuses in workflow order.
+var AssignmentStatuses = []string{AssignmentAssigned, AssignmentInProgress, AssignmentEscalated, AssignmentResolved}
+
+// ContractAssignment assigns a contract line to the buyer expediting it.
+type ContractAssignment struct {
+	ContractNo int       `json:"contract_no"`
+	PartCode   string    `json:"part_code"`
+	Buyer      string    `json:"buyer"`
+	Status     string    `json:"status"`
+	Note       *string   `json:"note"`
+	AssignedAt time.Time `json:"assigned_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Events []AssignmentEvent `json:"events,omitempty"`
+}
+
+// AssignmentEvent records the buyer, status and note of an assignment after a change.
+type AssignmentEvent struct {
+	EventID   int       `json:"event_id"`
+	Buyer     string    `json:"buyer"`
+	Status    string    `json:"status"`
+	Note      *string   `json:"note"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// abcXYZQuery reads the optional from/to dates and the a, b, x and y thresholds
+// of the ABC/XYZ report, falling back to domain.DefaultABCXYZThresholds.
+func abcXYZQuery(c *gin.Context) (from, to string, t domain.ABCXYZThresholds, ok bool) {
+	from, to, ok = periodQuery(c)
+	if !ok {
+		return "", "", t, false
+	}
+	t = domain.DefaultABCXYZThresholds
+	for name, v := range map[string]*float64{"a": &t.A, "b": &t.B, "x": &t.X, "y": &t.Y} {
+		s := c.Query(name)
+		if s == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 {
+			c.String(http.StatusBadRequest, "Invalid %s threshold", name)
+			return "", "", t, false
+		}
+		*v = f
+	}
+	if t.A > t.B || t.B > 100 || t.X > t.Y {
+		c.String(http.StatusBadRequest, "Thresholds must satisfy a <= b <= 100 and x <= y")
+		return "", "", t, false
+	}
+	return from, to, t, true
+}
+
+func (h *Handler) ABCXYZReport(c *gin.Context) {
+	from, to, thresholds, ok := abcXYZQuery(c)
+	if !ok {
+		return
+	}
+	abc, xyz := c.Query("abc"), c.Query("xyz")
+
+	classes, err := h.repo.GetPartClasses(c.Request.Context(), from, to, thresholds)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error classifying parts: %v", err)
+		return
+	}
+
+	var total float64
+	for _, pc := range classes {
+		total += pc.Value
+	}
+	matrix := make([][]domain.ABCXYZCell, 3)
+	for i, a := range []string{"A", "B", "C"} {
+		for _, x := range []string{"X", "Y", "Z"} {
+			cell := domain.ABCXYZCell{ABC: a, XYZ: x}
+			for _, pc := range classes {
+				if pc.ABC == a && pc.XYZ == x {
+					cell.Parts++
+					cell.Value += pc.Value
+				}
+			}
+			if total > 0 {
+				cell.Share = cell.Value / total * 100
+			}
+			matrix[i] = append(matrix[i], cell)
+		}
+	}
+
+	parts := classes
+	if abc != "" || xyz != "" {
+		parts = nil
+		for _, pc := range classes {
+			if (abc == "" || pc.ABC == abc) && (xyz == "" || pc.XYZ == xyz) {
+				parts = append(parts, pc)
+			}
+		}
+	}
+
+	c.HTML(http.StatusOK, "abc_xyz.html", gin.H{
+		"Title":        "ABC/XYZ Analysis",
+		"From":         from,
+		"To":           to,
+		"Thresholds":   thresholds,
+		"ABC":          abc,
+		"XYZ":          xyz,
+		"Matrix":       matrix,
+		"Parts":        parts,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+func (h *Handler) ABCXYZDeliveries(c *gin.Context) {
+	partCode := c.Query("part_code")
+	if partCode == "" {
+		c.String(http.StatusBadRequest, "part_code is required")
+		return
+	}
+	from, to, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.repo.GetPartDeliveries(c.Request.Context(), partCode, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching deliveries: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "abc_xyz_deliveries.html", gin.H{
+		"Title":        fmt.Sprintf("Deliveries of %s", partCode),
+		"PartCode":     partCode,
+		"From":         from,
+		"To":           to,
+		"Deliveries":   deliveries,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
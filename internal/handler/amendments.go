@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) CreateAmendment(c *gin.Context) {
+	var req struct {
+		ContractNo    int      `json:"contract_no" binding:"required"`
+		PartCode      string   `json:"part_code" binding:"required"`
+		StartDate     string   `json:"start_date"`
+		EndDate       string   `json:"end_date"`
+		PlanQty       *float64 `json:"plan_qty"`
+		ContractPrice *float64 `json:"contract_price"`
+		Reason        string   `json:"reason" binding:"required"`
+		RequestedBy   string   `json:"requested_by" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var startDate, endDate *time.Time
+	if req.StartDate != "" {
+		t, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
+			return
+		}
+		startDate = &t
+	}
+	if req.EndDate != "" {
+		t, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+			return
+		}
+		endDate = &t
+	}
+
+	id, err := h.repo.CreateAmendment(c.Request.Context(), req.ContractNo, req.PartCode, startDate, endDate, req.PlanQty, req.ContractPrice, req.Reason, req.RequestedBy)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create amendment: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Amendment created successfully", "id": id})
+}
+
+func (h *Handler) SubmitAmendment(c *gin.Context) {
+	var req struct {
+		ID int `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.repo.SubmitAmendment(c.Request.Context(), req.ID); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to submit amendment: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Amendment submitted successfully"})
+}
+
+type amendmentDecisionRequest struct {
+	ID        int    `json:"id" binding:"required"`
+	DecidedBy string `json:"decided_by" binding:"required"`
+	Comment   string `json:"comment"`
+}
+
+func (h *Handler) ApproveAmendment(c *gin.Context) {
+	var req amendmentDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exceeded, err := h.repo.ApproveAmendment(c.Request.Context(), req.ID, req.DecidedBy, req.Comment)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to approve amendment: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Amendment approved and applied", "budget_warnings": exceeded})
+}
+
+func (h *Handler) RejectAmendment(c *gin.Context) {
+	var req amendmentDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.repo.RejectAmendment(c.Request.Context(), req.ID, req.DecidedBy, req.Comment); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to reject amendment: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Amendment rejected"})
+}
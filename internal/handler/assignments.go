@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+	"github.com/railgorail/kpfu-db-app/internal/repository"
+)
+
+func (h *Handler) ContractAssignment(c *gin.Context) {
+	contractNo, err := strconv.Atoi(c.Query("contract_no"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid contract_no")
+		return
+	}
+	partCode := c.Query("part_code")
+
+	// An unassigned line gets an empty form to assign a buyer, below its history.
+	assignment, err := h.repo.GetAssignment(c.Request.Context(), contractNo, partCode)
+	if errors.Is(err, repository.ErrNotFound) {
+		assignment = &domain.ContractAssignment{ContractNo: contractNo, PartCode: partCode, Status: domain.AssignmentAssigned}
+		assignment.Events, err = h.repo.GetAssignmentEvents(c.Request.Context(), contractNo, partCode)
+	}
+	if err != nil {
+		c.String(errorStatus(err), "Error fetching assignment: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "assignment.html", gin.H{
+		"Title":      fmt.Sprintf("Assignment of contract %d/%s", contractNo, partCode),
+		"Assignment": assignment,
+		"Statuses":   domain.AssignmentStatuses,
+	})
+}
+
+func (h *Handler) SetAssignment(c *gin.Context) {
+	var req struct {
+		ContractNo int     `json:"contract_no" binding:"required"`
+		PartCode   string  `json:"part_code" binding:"required"`
+		Buyer      string  `json:"buyer" binding:"required"`
+		Status     string  `json:"status" binding:"required,oneof=assigned in_progress escalated resolved"`
+		Note       *string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Note != nil && *req.Note == "" {
+		req.Note = nil
+	}
+
+	assignment := domain.ContractAssignment{
+		ContractNo: req.ContractNo,
+		PartCode:   req.PartCode,
+		Buyer:      req.Buyer,
+		Status:     req.Status,
+		Note:       req.Note,
+	}
+	if err := h.repo.SetAssignment(c.Request.Context(), assignment); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to save assignment: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment saved successfully"})
+}
+
+func (h *Handler) DeleteAssignment(c *gin.Context) {
+	var req struct {
+		ContractNo int    `json:"contract_no" binding:"required"`
+		PartCode   string `json:"part_code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteAssignment(c.Request.Context(), req.ContractNo, req.PartCode); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete assignment: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted successfully"})
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+	"github.com/railgorail/kpfu-db-app/internal/repository"
+)
+
+func (h *Handler) Budgets(c *gin.Context) {
+	budgets, err := h.repo.GetBudgets(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching budgets: %v", err)
+		return
+	}
+	categories, err := h.repo.GetPartCategories(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching part categories: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "budgets.html", gin.H{
+		"Title":        "Budgets",
+		"Budgets":      budgets,
+		"Categories":   categories,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+// commitmentQuery reads group_by (contract by default) and the optional from/to
+// months of the commitment report.
+func commitmentQuery(c *gin.Context) (groupBy, from, to string, ok bool) {
+	groupBy = c.DefaultQuery("group_by", "contract")
+	if !repository.IsCommitmentGroup(groupBy) {
+		c.String(http.StatusBadRequest, "Invalid group_by. Use contract, supplier, part, category or month")
+		return "", "", "", false
+	}
+	from, to, ok = periodQuery(c)
+	return groupBy, from, to, ok
+}
+
+func (h *Handler) CommitmentReport(c *gin.Context) {
+	groupBy, from, to, ok := commitmentQuery(c)
+	if !ok {
+		return
+	}
+
+	commitments, err := h.repo.GetCommitments(c.Request.Context(), groupBy, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching commitments: %v", err)
+		return
+	}
+	var total domain.Commitment
+	for _, cm := range commitments {
+		total.Committed += cm.Committed
+		total.Spent += cm.Spent
+		total.Remaining += cm.Remaining
+		total.RateMissing = total.RateMissing || cm.RateMissing
+	}
+
+	c.HTML(http.StatusOK, "commitments.html", gin.H{
+		"Title":        "Commitments",
+		"GroupBy":      groupBy,
+		"Groups":       []string{"contract", "supplier", "part", "category", "month"},
+		"From":         from,
+		"To":           to,
+		"Commitments":  commitments,
+		"Total":        total,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+func (h *Handler) ExportCommitments(c *gin.Context) {
+	groupBy, from, to, ok := commitmentQuery(c)
+	if !ok {
+		return
+	}
+
+	commitments, err := h.repo.GetCommitments(c.Request.Context(), groupBy, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching commitments: %v", err)
+		return
+	}
+
+	records := [][]string{{groupBy, "committed", "spent", "remaining", "rate_missing"}}
+	for _, cm := range commitments {
+		records = append(records, []string{
+			cm.Group,
+			formatQty(&cm.Committed),
+			formatQty(&cm.Spent),
+			formatQty(&cm.Remaining),
+			fmt.Sprint(cm.RateMissing),
+		})
+	}
+	writeCSV(c, fmt.Sprintf("commitments_by_%s.csv", groupBy), records)
+}
+
+func (h *Handler) SetBudget(c *gin.Context) {
+	var req struct {
+		PeriodMonth string  `json:"period_month" binding:"required"`
+		Category    *string `json:"category"`
+		LimitAmount float64 `json:"limit_amount" binding:"gte=0"`
+		Enforcement string  `json:"enforcement" binding:"required,oneof=warn block"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	month, err := time.Parse("2006-01", req.PeriodMonth)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period_month format. Use YYYY-MM"})
+		return
+	}
+	if req.Category != nil && *req.Category == "" {
+		req.Category = nil
+	}
+
+	budget := domain.Budget{
+		PeriodMonth: month,
+		Category:    req.Category,
+		LimitAmount: req.LimitAmount,
+		Enforcement: req.Enforcement,
+	}
+	if err := h.repo.SetBudget(c.Request.Context(), budget); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to save budget: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget saved successfully"})
+}
+
+func (h *Handler) DeleteBudget(c *gin.Context) {
+	var req struct {
+		BudgetID int `json:"budget_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteBudget(c.Request.Context(), req.BudgetID); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete budget: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted successfully"})
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// dashboardWeeks is the default number of weeks shown in the receipt charts.
+const dashboardWeeks = 12
+
+// dashboardQuery reads the on date and weeks query parameters of the dashboard.
+func dashboardQuery(c *gin.Context) (time.Time, int, error) {
+	on, err := time.Parse("2006-01-02", c.DefaultQuery("on", time.Now().Format("2006-01-02")))
+	if err != nil {
+		return time.Time{}, 0, errors.New("Invalid on format. Use YYYY-MM-DD")
+	}
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", strconv.Itoa(dashboardWeeks)))
+	if err != nil || weeks < 1 || weeks > 104 {
+		return time.Time{}, 0, errors.New("Invalid weeks. Use 1 to 104")
+	}
+	return on, weeks, nil
+}
+
+func (h *Handler) Dashboard(c *gin.Context) {
+	on, weeks, err := dashboardQuery(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dash, err := h.repo.GetDashboard(c.Request.Context(), on, weeks)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching dashboard: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		"Title":        "Dashboard",
+		"Dashboard":    dash,
+		"Weeks":        weeks,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+func (h *Handler) DashboardData(c *gin.Context) {
+	on, weeks, err := dashboardQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dash, err := h.repo.GetDashboard(c.Request.Context(), on, weeks)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to fetch dashboard: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, dash)
+}
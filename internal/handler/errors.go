@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/railgorail/kpfu-db-app/internal/repository"
+)
+
+// errorStatus picks the HTTP status for an error returned by the repository.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrKeyConflict), errors.Is(err, repository.ErrInUse),
+		errors.Is(err, repository.ErrAmendmentRequired), errors.Is(err, repository.ErrInvalidState),
+		errors.Is(err, repository.ErrInsufficientStock), errors.Is(err, repository.ErrPeriodClosed),
+		errors.Is(err, repository.ErrBudgetExceeded):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrStaleConfirmation):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, repository.ErrReferenceMissing):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) ExchangeRates(c *gin.Context) {
+	rates, err := h.repo.GetExchangeRates(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching exchange rates: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "exchange_rates.html", gin.H{
+		"Title":        "Exchange Rates",
+		"Rates":        rates,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+// ImportExchangeRates loads the CSV file of the "file" form field. Each record is
+// currency,rate_date,rate with the rate in base currency units per unit; an
+// optional header line and ';' as the separator are accepted.
+func (h *Handler) ImportExchangeRates(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	rates, err := parseExchangeRates(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid exchange rate file: %v", err)})
+		return
+	}
+	if len(rates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exchange rate file has no rates"})
+		return
+	}
+
+	if err := h.repo.ImportExchangeRates(c.Request.Context(), rates); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to load exchange rates: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rates loaded successfully", "loaded": len(rates)})
+}
+
+func (h *Handler) DeleteExchangeRate(c *gin.Context) {
+	var req struct {
+		Currency string `json:"currency" binding:"required"`
+		RateDate string `json:"rate_date" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rateDate, err := time.Parse("2006-01-02", req.RateDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	if err := h.repo.DeleteExchangeRate(c.Request.Context(), req.Currency, rateDate); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete exchange rate: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+}
+
+func parseExchangeRates(r io.Reader) ([]domain.ExchangeRate, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(strings.NewReader(string(data)))
+	if first, _, _ := strings.Cut(string(data), "\n"); strings.Contains(first, ";") {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+
+	var rates []domain.ExchangeRate
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "currency") {
+			continue
+		}
+
+		currency := strings.ToUpper(strings.TrimSpace(rec[0]))
+		if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" || currency == domain.BaseCurrency {
+			return nil, fmt.Errorf("line %d: invalid currency %q", line, rec[0])
+		}
+		rateDate, err := time.Parse("2006-01-02", strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate_date %q, use YYYY-MM-DD", line, rec[1])
+		}
+		rate, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(rec[2]), ",", "."), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, rec[2])
+		}
+		rates = append(rates, domain.ExchangeRate{Currency: currency, RateDate: rateDate, Rate: rate})
+	}
+	return rates, nil
+}
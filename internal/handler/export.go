@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeCSV sends records as a CSV attachment named filename. The first record is the header.
+func writeCSV(c *gin.Context, filename string, records [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.WriteAll(records)
+}
+
+func formatQty(qty *float64) string {
+	if qty == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *qty)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) ForecastReport(c *gin.Context) {
+	on, ok := onQuery(c)
+	if !ok {
+		return
+	}
+	onDate, _ := time.Parse("2006-01-02", on)
+	status := c.Query("status")
+
+	forecasts, err := h.repo.GetForecasts(c.Request.Context(), onDate)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error forecasting contracts: %v", err)
+		return
+	}
+	counts := map[string]int{}
+	var shown []domain.Forecast
+	for _, f := range forecasts {
+		counts[f.Status]++
+		if status == "" || f.Status == status {
+			shown = append(shown, f)
+		}
+	}
+
+	c.HTML(http.StatusOK, "forecast.html", gin.H{
+		"Title":     "Fulfilment Forecast",
+		"On":        on,
+		"Status":    status,
+		"Statuses":  []string{domain.ForecastShort, domain.ForecastAtRisk, domain.ForecastOnTrack, domain.ForecastComplete, domain.ForecastUnknown, domain.ForecastNotStarted},
+		"Counts":    counts,
+		"Forecasts": shown,
+	})
+}
+
+// ListContracts returns the live contract lines with their fulfilment forecast
+// as of the on query parameter, today by default.
+func (h *Handler) ListContracts(c *gin.Context) {
+	on, err := time.Parse("2006-01-02", c.DefaultQuery("on", time.Now().Format("2006-01-02")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid on format. Use YYYY-MM-DD"})
+		return
+	}
+
+	contracts, err := h.repo.GetContracts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch contracts: %v", err)})
+		return
+	}
+	forecasts, err := h.repo.GetForecasts(c.Request.Context(), on)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to forecast contracts: %v", err)})
+		return
+	}
+	byLine := make(map[string]*domain.Forecast, len(forecasts))
+	for i := range forecasts {
+		byLine[fmt.Sprintf("%d/%s", forecasts[i].ContractNo, forecasts[i].PartCode)] = &forecasts[i]
+	}
+	for i := range contracts {
+		contracts[i].Forecast = byLine[fmt.Sprintf("%d/%s", contracts[i].ContractNo, contracts[i].PartCode)]
+	}
+
+	c.JSON(http.StatusOK, contracts)
+}
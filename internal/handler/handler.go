@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,11 +22,8 @@ func New(repo *repository.Repository) *Handler {
 
 func (h *Handler) RegisterRoutes(r *gin.Engine) {
 	r.GET("/", h.Home)
+	r.GET("/dashboard", h.Dashboard)
 	r.GET("/view", h.View)
-	tasks := r.Group("/task")
-	tasks.GET("/1", h.Task1)
-	tasks.GET("/2", h.Task2)
-	tasks.GET("/3", h.Task3)
 
 	api := r.Group("/api")
 	api.PUT("/warehouses", h.UpdateWarehouse)
@@ -37,9 +35,98 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 	api.DELETE("/warehouses", h.DeleteWarehouse)
 	api.DELETE("/contracts", h.DeleteContract)
 	api.DELETE("/deliveries", h.DeleteDelivery)
+	api.POST("/deliveries/move", h.MoveDelivery)
+	api.POST("/contracts/renumber", h.RenumberContract)
+	api.GET("/key-changes", h.KeyChanges)
+	api.GET("/warehouses/delete-preview", h.PreviewWarehouseDelete)
+	api.GET("/contracts/delete-preview", h.PreviewContractDelete)
+	api.POST("/trash/restore", h.RestoreFromTrash)
+	api.DELETE("/trash", h.PurgeFromTrash)
+	api.POST("/amendments", h.CreateAmendment)
+	api.POST("/amendments/submit", h.SubmitAmendment)
+	api.POST("/amendments/approve", h.ApproveAmendment)
+	api.POST("/amendments/reject", h.RejectAmendment)
+	api.GET("/stock", h.StockBalance)
+	api.GET("/dashboard", h.DashboardData)
+	api.POST("/issues", h.CreateIssue)
+	api.POST("/transfers", h.CreateTransfer)
+	api.POST("/transfers/receive", h.ReceiveTransfer)
+	api.POST("/ledger/adjustments", h.PostAdjustment)
+	api.POST("/periods/close", h.ClosePeriod)
+	api.POST("/stock-counts", h.CreateStockCount)
+	api.PUT("/stock-counts/lines", h.SetCountedQty)
+	api.POST("/stock-counts/approve", h.ApproveStockCount)
+	api.GET("/parts", h.ListParts)
+	api.POST("/parts", h.CreatePart)
+	api.PUT("/parts", h.UpdatePart)
+	api.DELETE("/parts", h.DeletePart)
+	api.GET("/suppliers", h.ListSuppliers)
+	api.POST("/suppliers", h.CreateSupplier)
+	api.PUT("/suppliers", h.UpdateSupplier)
+	api.DELETE("/suppliers", h.DeleteSupplier)
+	api.GET("/contracts", h.ListContracts)
+	api.GET("/contract-headers", h.ListContractHeaders)
+	api.POST("/contract-headers", h.CreateContractHeader)
+	api.PUT("/contract-headers", h.UpdateContractHeader)
+	api.DELETE("/contract-headers", h.DeleteContractHeader)
+	api.POST("/inspections", h.RecordInspection)
+	api.POST("/returns", h.CreateReturn)
+	api.POST("/deliveries/lots", h.AddDeliveryLots)
+	api.DELETE("/deliveries/lots", h.DeleteDeliveryLot)
+	api.GET("/lots", h.FindLot)
+	api.GET("/stock/fefo", h.SuggestFEFO)
+	api.GET("/contracts/schedule", h.GetSchedule)
+	api.PUT("/contracts/schedule", h.SetSchedule)
+	api.PUT("/contracts/penalty-terms", h.SetPenaltyTerms)
+	api.DELETE("/contracts/penalty-terms", h.DeletePenaltyTerms)
+	api.PUT("/contracts/assignment", h.SetAssignment)
+	api.DELETE("/contracts/assignment", h.DeleteAssignment)
+	api.POST("/invoices", h.CreateInvoice)
+	api.DELETE("/invoices", h.DeleteInvoice)
+	api.POST("/payments", h.CreatePayment)
+	api.POST("/exchange-rates/import", h.ImportExchangeRates)
+	api.DELETE("/exchange-rates", h.DeleteExchangeRate)
+	api.PUT("/budgets", h.SetBudget)
+	api.DELETE("/budgets", h.DeleteBudget)
+	h.registerReports(r, api)
 
 	r.GET("/procedure", h.Procedure)
 	r.GET("/orm/task/1", h.ORMTask1)
+	r.GET("/trash", h.Trash)
+	r.GET("/contracts/history", h.ContractHistory)
+	r.GET("/stock", h.Stock)
+	r.GET("/stock/movements", h.StockMovements)
+	r.GET("/transfers", h.Transfers)
+	r.GET("/ledger", h.Ledger)
+	r.GET("/periods", h.Periods)
+	r.GET("/stock-counts", h.StockCounts)
+	r.GET("/stock-counts/sheet", h.StockCountSheet)
+	r.GET("/stock-counts/export", h.ExportStockCount)
+	r.GET("/inspections", h.Inspections)
+	r.GET("/lots", h.Lots)
+	r.GET("/contracts/schedule", h.ContractSchedule)
+	r.GET("/contracts/assignment", h.ContractAssignment)
+	r.GET("/reports/tranches", h.TrancheReport)
+	r.GET("/reports/penalties", h.PenaltyReport)
+	r.GET("/reports/penalties/letter", h.PenaltyClaimLetter)
+	r.GET("/invoices", h.Invoices)
+	r.GET("/invoices/detail", h.InvoiceDetail)
+	r.GET("/reports/ap-aging", h.APAging)
+	r.GET("/exchange-rates", h.ExchangeRates)
+	r.GET("/budgets", h.Budgets)
+	r.GET("/reports/commitments", h.CommitmentReport)
+	r.GET("/reports/commitments/export", h.ExportCommitments)
+	r.GET("/reports/pivot", h.PivotReport)
+	r.GET("/reports/pivot/export", h.ExportPivot)
+	r.GET("/reports/abc-xyz", h.ABCXYZReport)
+	r.GET("/reports/abc-xyz/deliveries", h.ABCXYZDeliveries)
+	r.GET("/reports/forecast", h.ForecastReport)
+	r.GET("/parts", h.Parts)
+	r.GET("/suppliers", h.Suppliers)
+	r.GET("/reports/deliveries-by-supplier", h.DeliveriesBySupplier)
+	r.GET("/reports/deliveries-by-supplier/export", h.ExportDeliveriesBySupplier)
+	r.GET("/reports/supplier-scorecard", h.SupplierScorecard)
+	r.GET("/reports/supplier-scorecard/detail", h.SupplierScorecardDetail)
 }
 
 func (h *Handler) Home(c *gin.Context) {
@@ -58,43 +145,38 @@ func (h *Handler) Home(c *gin.Context) {
 		c.String(http.StatusInternalServerError, "Error fetching deliveries: %v", err)
 		return
 	}
+	parts, err := h.repo.GetParts(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching parts: %v", err)
+		return
+	}
 
 	c.HTML(http.StatusOK, "home.html", gin.H{
 		"Title":      "Home",
 		"Warehouses": warehouses,
 		"Contracts":  contracts,
 		"Deliveries": deliveries,
+		"Parts":      parts,
 	})
 }
 
 func (h *Handler) View(c *gin.Context) {
-	view, err := h.repo.GetView(c.Request.Context())
+	asOf, err := parseAsOf(c)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Error fetching view data: %v", err)
+		c.String(http.StatusBadRequest, "Invalid as_of format. Use YYYY-MM-DD")
 		return
 	}
-	c.HTML(http.StatusOK, "view.html", gin.H{
-		"Title": "View",
-		"View":  view,
-	})
-}
 
-func (h *Handler) Task1(c *gin.Context) {
-	priceStr := c.DefaultQuery("price", "100")
-	price, err := strconv.ParseFloat(priceStr, 64)
+	view, err := h.repo.GetView(c.Request.Context(), asOf)
 	if err != nil {
-		price = 100
-	}
-
-	t, err := h.repo.GetTask1(c.Request.Context(), price)
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Error fetching task1 data: %v", err)
+		c.String(http.StatusInternalServerError, "Error fetching view data: %v", err)
 		return
 	}
-	c.HTML(http.StatusOK, "task1.html", gin.H{
-		"Title": "Task 1",
-		"Task1": t,
-		"Price": price,
+	c.HTML(http.StatusOK, "view.html", gin.H{
+		"Title":        "View",
+		"View":         view,
+		"AsOf":         c.Query("as_of"),
+		"BaseCurrency": domain.BaseCurrency,
 	})
 }
 
@@ -105,53 +187,23 @@ func (h *Handler) ORMTask1(c *gin.Context) {
 		price = 100
 	}
 
-	t, err := h.repo.ORMGetTask1(c.Request.Context(), price)
+	asOf, err := parseAsOf(c)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Error fetching ORM task1 data: %v", err)
+		c.String(http.StatusBadRequest, "Invalid as_of format. Use YYYY-MM-DD")
 		return
 	}
-	c.HTML(http.StatusOK, "ORMtask1.html", gin.H{
-		"Title": "ORM Task 1",
-		"Task1": t,
-		"Price": price,
-	})
-}
 
-func (h *Handler) Task2(c *gin.Context) {
-	t, err := h.repo.GetTask2(c.Request.Context())
+	t, err := h.repo.ORMGetTask1(c.Request.Context(), price, asOf)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Error fetching task2 data: %v", err)
-		return
-	}
-	c.HTML(http.StatusOK, "task2.html", gin.H{
-		"Title": "Task 2",
-		"Task2": t,
-	})
-}
-
-func (h *Handler) Task3(c *gin.Context) {
-	planQtyStr := c.DefaultQuery("plan_qty", "1000")
-	planQty, err := strconv.Atoi(planQtyStr)
-	if err != nil {
-		planQty = 1000
-	}
-
-	deliveryQtyStr := c.DefaultQuery("delivery_qty", "50")
-	deliveryQty, err := strconv.Atoi(deliveryQtyStr)
-	if err != nil {
-		deliveryQty = 50
-	}
-
-	t, err := h.repo.GetTask3(c.Request.Context(), planQty, deliveryQty)
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Error fetching task3 data: %v", err)
+		c.String(http.StatusInternalServerError, "Error fetching ORM task1 data: %v", err)
 		return
 	}
-	c.HTML(http.StatusOK, "task3.html", gin.H{
-		"Title":       "Task 3",
-		"Task3":       t,
-		"PlanQty":     planQty,
-		"DeliveryQty": deliveryQty,
+	c.HTML(http.StatusOK, "ORMtask1.html", gin.H{
+		"Title":        "ORM Task 1",
+		"Task1":        t,
+		"Price":        price,
+		"AsOf":         c.Query("as_of"),
+		"BaseCurrency": domain.BaseCurrency,
 	})
 }
 
@@ -175,14 +227,16 @@ func (h *Handler) UpdateWarehouse(c *gin.Context) {
 }
 
 func (h *Handler) UpdateContract(c *gin.Context) {
+	// Commercial terms are optional here and must match the current ones,
+	// they are changed through /api/amendments.
 	var req struct {
-		ContractNo    int     `json:"contract_no" binding:"required"`
-		PartCode      string  `json:"part_code" binding:"required"`
-		Unit          string  `json:"unit" binding:"required"`
-		StartDate     string  `json:"start_date" binding:"required"`
-		EndDate       string  `json:"end_date" binding:"required"`
-		PlanQty       float64 `json:"plan_qty" binding:"required"`
-		ContractPrice float64 `json:"contract_price" binding:"required"`
+		ContractNo    int      `json:"contract_no" binding:"required"`
+		PartCode      string   `json:"part_code" binding:"required"`
+		Unit          string   `json:"unit" binding:"required"`
+		StartDate     *string  `json:"start_date"`
+		EndDate       *string  `json:"end_date"`
+		PlanQty       *float64 `json:"plan_qty"`
+		ContractPrice *float64 `json:"contract_price"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -190,17 +244,21 @@ func (h *Handler) UpdateContract(c *gin.Context) {
 		return
 	}
 
-	if _, err := time.Parse("2006-01-02", req.StartDate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
-		return
+	if req.StartDate != nil {
+		if _, err := time.Parse("2006-01-02", *req.StartDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
+			return
+		}
 	}
-	if _, err := time.Parse("2006-01-02", req.EndDate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
-		return
+	if req.EndDate != nil {
+		if _, err := time.Parse("2006-01-02", *req.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+			return
+		}
 	}
 
 	if err := h.repo.UpdateContract(c.Request.Context(), req.ContractNo, req.PartCode, req.Unit, req.StartDate, req.EndDate, req.PlanQty, req.ContractPrice); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update contract: %v", err)})
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to update contract: %v", err)})
 		return
 	}
 
@@ -229,7 +287,7 @@ func (h *Handler) UpdateDelivery(c *gin.Context) {
 	}
 
 	if err := h.repo.UpdateDelivery(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, req.ContractNo, req.PartCode, req.Unit, req.Qty, req.ReceivedDate); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update delivery: %v", err)})
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to update delivery: %v", err)})
 		return
 	}
 
@@ -264,6 +322,7 @@ func (h *Handler) CreateContract(c *gin.Context) {
 		EndDate       string  `json:"end_date" binding:"required"`
 		PlanQty       float64 `json:"plan_qty" binding:"required"`
 		ContractPrice float64 `json:"contract_price" binding:"required"`
+		Currency      string  `json:"currency" binding:"omitempty,len=3,alpha"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -271,6 +330,11 @@ func (h *Handler) CreateContract(c *gin.Context) {
 		return
 	}
 
+	if req.Currency == "" {
+		req.Currency = domain.BaseCurrency
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+
 	if _, err := time.Parse("2006-01-02", req.StartDate); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
 		return
@@ -280,12 +344,13 @@ func (h *Handler) CreateContract(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.CreateContract(c.Request.Context(), req.ContractNo, req.PartCode, req.Unit, req.StartDate, req.EndDate, req.PlanQty, req.ContractPrice); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create contract: %v", err)})
+	exceeded, err := h.repo.CreateContract(c.Request.Context(), req.ContractNo, req.PartCode, req.Unit, req.StartDate, req.EndDate, req.PlanQty, req.ContractPrice, req.Currency)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create contract: %v", err)})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Contract created successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Contract created successfully", "budget_warnings": exceeded})
 }
 
 func (h *Handler) CreateDelivery(c *gin.Context) {
@@ -310,7 +375,7 @@ func (h *Handler) CreateDelivery(c *gin.Context) {
 	}
 
 	if err := h.repo.CreateDelivery(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, req.ContractNo, req.PartCode, req.Unit, req.Qty, req.ReceivedDate); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create delivery: %v", err)})
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create delivery: %v", err)})
 		return
 	}
 
@@ -319,14 +384,16 @@ func (h *Handler) CreateDelivery(c *gin.Context) {
 
 func (h *Handler) DeleteWarehouse(c *gin.Context) {
 	var req struct {
-		ID int `json:"id" binding:"required"`
+		ID           int    `json:"id" binding:"required"`
+		ConfirmToken string `json:"confirm_token" binding:"required"`
+		ReassignTo   int    `json:"reassign_to"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.repo.DeleteWarehouse(c.Request.Context(), req.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.repo.DeleteWarehouse(c.Request.Context(), req.ID, req.ConfirmToken, req.ReassignTo); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Warehouse deleted successfully"})
@@ -342,12 +409,45 @@ func (h *Handler) DeleteContract(c *gin.Context) {
 		return
 	}
 	if err := h.repo.DeleteContract(c.Request.Context(), req.ContractNo, req.PartCode); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Contract deleted successfully"})
 }
 
+func (h *Handler) PreviewWarehouseDelete(c *gin.Context) {
+	warehouseNo, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+	preview, err := h.repo.PreviewWarehouseDelete(c.Request.Context(), warehouseNo)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+func (h *Handler) PreviewContractDelete(c *gin.Context) {
+	contractNo, err := strconv.Atoi(c.Query("contract_no"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid contract_no"})
+		return
+	}
+	partCode := c.Query("part_code")
+	if partCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "part_code is required"})
+		return
+	}
+	preview, err := h.repo.PreviewContractDelete(c.Request.Context(), contractNo, partCode)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
 func (h *Handler) DeleteDelivery(c *gin.Context) {
 	var req struct {
 		WarehouseNo  int `json:"warehouse_no" binding:"required"`
@@ -358,7 +458,7 @@ func (h *Handler) DeleteDelivery(c *gin.Context) {
 		return
 	}
 	if err := h.repo.DeleteDelivery(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Delivery deleted successfully"})
@@ -367,6 +467,7 @@ func (h *Handler) DeleteDelivery(c *gin.Context) {
 func (h *Handler) Procedure(c *gin.Context) {
 	contractNoStr := c.DefaultQuery("contract_no", "")
 	partCode := c.DefaultQuery("part_code", "")
+	asOfStr := c.Query("as_of")
 
 	var result *domain.ContractSummary
 
@@ -374,7 +475,8 @@ func (h *Handler) Procedure(c *gin.Context) {
 
 1. Вычисляет сумму всех поставок (qty) из таблицы deliveries для указанного номера договора и кода детали
 2. Получает договорную цену (contract_price) из таблицы contracts для указанного номера договора и кода детали
-3. Если договор не найден, возвращает total_delivered = 0 и contract_price = NULL`
+3. Если договор не найден, возвращает total_delivered = 0 и contract_price = NULL
+4. Если задана дата as_of, учитывает только поставки по эту дату и цену, действовавшую на неё`
 
 	if contractNoStr != "" && partCode != "" {
 		contractNo, parseErr := strconv.Atoi(contractNoStr)
@@ -387,8 +489,19 @@ func (h *Handler) Procedure(c *gin.Context) {
 			return
 		}
 
-		var err error
-		result, err = h.repo.CallContractSummary(c.Request.Context(), contractNo, partCode)
+		asOf, err := parseAsOf(c)
+		if err != nil {
+			c.HTML(http.StatusOK, "procedure.html", gin.H{
+				"Title":                "Procedure p_contract_summary",
+				"ProcedureDescription": procedureDescription,
+				"Error":                "Invalid as_of format. Use YYYY-MM-DD",
+				"ContractNo":           contractNo,
+				"PartCode":             partCode,
+			})
+			return
+		}
+
+		result, err = h.repo.CallContractSummary(c.Request.Context(), contractNo, partCode, asOf)
 		if err != nil {
 			c.HTML(http.StatusOK, "procedure.html", gin.H{
 				"Title":                "Procedure p_contract_summary",
@@ -407,5 +520,6 @@ func (h *Handler) Procedure(c *gin.Context) {
 		"Result":               result,
 		"ContractNo":           contractNoStr,
 		"PartCode":             partCode,
+		"AsOf":                 asOfStr,
 	})
 }
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) Inspections(c *gin.Context) {
+	inspections, err := h.repo.GetInspections(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching inspections: %v", err)
+		return
+	}
+	returns, err := h.repo.GetReturns(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching supplier returns: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "inspections.html", gin.H{
+		"Title":       "Inspections",
+		"Inspections": inspections,
+		"Returns":     returns,
+	})
+}
+
+func (h *Handler) RecordInspection(c *gin.Context) {
+	var req struct {
+		WarehouseNo  int      `json:"warehouse_no" binding:"required"`
+		ReceiptDocNo int      `json:"receipt_doc_no" binding:"required"`
+		RejectedQty  *float64 `json:"rejected_qty" binding:"required,gte=0"`
+		Reason       *string  `json:"reason"`
+		Inspector    string   `json:"inspector" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Reason != nil && *req.Reason == "" {
+		req.Reason = nil
+	}
+	if *req.RejectedQty > 0 && req.Reason == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required when parts are rejected"})
+		return
+	}
+
+	err := h.repo.RecordInspection(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, *req.RejectedQty, req.Reason, req.Inspector)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to record inspection: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Inspection recorded successfully"})
+}
+
+func (h *Handler) CreateReturn(c *gin.Context) {
+	var req struct {
+		WarehouseNo  int     `json:"warehouse_no" binding:"required"`
+		ReceiptDocNo int     `json:"receipt_doc_no" binding:"required"`
+		Qty          float64 `json:"qty" binding:"required,gt=0"`
+		ReturnedDate string  `json:"returned_date" binding:"required"`
+		Reason       string  `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.ReturnedDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid returned_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	returnNo, err := h.repo.CreateReturn(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, req.Qty, req.ReturnedDate, req.Reason)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create supplier return: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier return created successfully", "return_no": returnNo})
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) Invoices(c *gin.Context) {
+	invoices, err := h.repo.GetInvoices(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching invoices: %v", err)
+		return
+	}
+	mismatches, err := h.repo.GetInvoiceMatches(c.Request.Context(), 0, true)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error matching invoices: %v", err)
+		return
+	}
+	suppliers, err := h.repo.GetSuppliers(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching suppliers: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "invoices.html", gin.H{
+		"Title":      "Invoices",
+		"Invoices":   invoices,
+		"Mismatches": mismatches,
+		"Suppliers":  suppliers,
+	})
+}
+
+func (h *Handler) InvoiceDetail(c *gin.Context) {
+	invoiceID, err := strconv.Atoi(c.Query("invoice_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid invoice_id")
+		return
+	}
+
+	invoice, err := h.repo.GetInvoice(c.Request.Context(), invoiceID)
+	if err != nil {
+		c.String(errorStatus(err), "Error fetching invoice: %v", err)
+		return
+	}
+	matches, err := h.repo.GetInvoiceMatches(c.Request.Context(), invoiceID, false)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error matching invoice: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "invoice_detail.html", gin.H{
+		"Title":   fmt.Sprintf("Invoice %s", invoice.InvoiceNo),
+		"Invoice": invoice,
+		"Matches": matches,
+		"Open":    invoice.Amount - invoice.PaidAmount,
+	})
+}
+
+func (h *Handler) APAging(c *gin.Context) {
+	on, ok := onQuery(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.repo.GetAPAgingSummary(c.Request.Context(), on)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching payables aging: %v", err)
+		return
+	}
+	aging, err := h.repo.GetAPAging(c.Request.Context(), on)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching open invoices: %v", err)
+		return
+	}
+	var total domain.APAgingSummary
+	for _, s := range summary {
+		total.Current += s.Current
+		total.Days1To30 += s.Days1To30
+		total.Days31To60 += s.Days31To60
+		total.Days61To90 += s.Days61To90
+		total.Over90 += s.Over90
+		total.Total += s.Total
+	}
+
+	c.HTML(http.StatusOK, "ap_aging.html", gin.H{
+		"Title":   "Accounts Payable Aging",
+		"On":      on,
+		"Summary": summary,
+		"Total":   total,
+		"Aging":   aging,
+	})
+}
+
+func (h *Handler) CreateInvoice(c *gin.Context) {
+	var req struct {
+		SupplierID  int    `json:"supplier_id" binding:"required"`
+		InvoiceNo   string `json:"invoice_no" binding:"required"`
+		InvoiceDate string `json:"invoice_date" binding:"required"`
+		DueDate     string `json:"due_date" binding:"required"`
+		Lines       []struct {
+			WarehouseNo  int     `json:"warehouse_no" binding:"required"`
+			ReceiptDocNo int     `json:"receipt_doc_no" binding:"required"`
+			Qty          float64 `json:"qty" binding:"required,gt=0"`
+			UnitPrice    float64 `json:"unit_price" binding:"required,gte=0"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invoiceDate, err := time.Parse("2006-01-02", req.InvoiceDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice_date format. Use YYYY-MM-DD"})
+		return
+	}
+	dueDate, err := time.Parse("2006-01-02", req.DueDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format. Use YYYY-MM-DD"})
+		return
+	}
+	if dueDate.Before(invoiceDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "due_date must not be before invoice_date"})
+		return
+	}
+
+	invoice := domain.Invoice{
+		SupplierID:  req.SupplierID,
+		InvoiceNo:   req.InvoiceNo,
+		InvoiceDate: invoiceDate,
+		DueDate:     dueDate,
+	}
+	for _, l := range req.Lines {
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine{
+			WarehouseNo:  &l.WarehouseNo,
+			ReceiptDocNo: &l.ReceiptDocNo,
+			Qty:          l.Qty,
+			UnitPrice:    l.UnitPrice,
+		})
+	}
+
+	invoiceID, err := h.repo.CreateInvoice(c.Request.Context(), invoice)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create invoice: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invoice created successfully", "invoice_id": invoiceID})
+}
+
+func (h *Handler) DeleteInvoice(c *gin.Context) {
+	var req struct {
+		InvoiceID int `json:"invoice_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteInvoice(c.Request.Context(), req.InvoiceID); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete invoice: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invoice deleted successfully"})
+}
+
+func (h *Handler) CreatePayment(c *gin.Context) {
+	var req struct {
+		InvoiceID int     `json:"invoice_id" binding:"required"`
+		PaidDate  string  `json:"paid_date" binding:"required"`
+		Amount    float64 `json:"amount" binding:"required,gt=0"`
+		Reference *string `json:"reference"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	paidDate, err := time.Parse("2006-01-02", req.PaidDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid paid_date format. Use YYYY-MM-DD"})
+		return
+	}
+	if req.Reference != nil && *req.Reference == "" {
+		req.Reference = nil
+	}
+
+	payment := domain.Payment{
+		InvoiceID: req.InvoiceID,
+		PaidDate:  paidDate,
+		Amount:    req.Amount,
+		Reference: req.Reference,
+	}
+	paymentID, err := h.repo.CreatePayment(c.Request.Context(), payment)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to record payment: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment recorded successfully", "payment_id": paymentID})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) MoveDelivery(c *gin.Context) {
+	var req struct {
+		WarehouseNo     int `json:"warehouse_no" binding:"required"`
+		ReceiptDocNo    int `json:"receipt_doc_no" binding:"required"`
+		NewWarehouseNo  int `json:"new_warehouse_no" binding:"required"`
+		NewReceiptDocNo int `json:"new_receipt_doc_no" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.MoveDelivery(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, req.NewWarehouseNo, req.NewReceiptDocNo); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to move delivery: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Delivery moved successfully"})
+}
+
+func (h *Handler) RenumberContract(c *gin.Context) {
+	var req struct {
+		ContractNo    int    `json:"contract_no" binding:"required"`
+		PartCode      string `json:"part_code" binding:"required"`
+		NewContractNo int    `json:"new_contract_no" binding:"required"`
+		NewPartCode   string `json:"new_part_code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.RenumberContract(c.Request.Context(), req.ContractNo, req.PartCode, req.NewContractNo, req.NewPartCode); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to renumber contract: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contract renumbered successfully"})
+}
+
+func (h *Handler) KeyChanges(c *gin.Context) {
+	changes, err := h.repo.GetKeyChanges(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch key changes: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
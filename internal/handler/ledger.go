@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) Ledger(c *gin.Context) {
+	warehouseNo, err := strconv.Atoi(c.DefaultQuery("warehouse_no", "0"))
+	if err != nil {
+		warehouseNo = 0
+	}
+
+	entries, err := h.repo.GetLedger(c.Request.Context(), warehouseNo)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching ledger: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "ledger.html", gin.H{
+		"Title":       "Stock Ledger",
+		"WarehouseNo": warehouseNo,
+		"Entries":     entries,
+	})
+}
+
+func (h *Handler) Periods(c *gin.Context) {
+	periods, err := h.repo.GetPeriods(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching periods: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "periods.html", gin.H{
+		"Title":   "Accounting Periods",
+		"Periods": periods,
+	})
+}
+
+func (h *Handler) PostAdjustment(c *gin.Context) {
+	var req struct {
+		WarehouseNo  int     `json:"warehouse_no" binding:"required"`
+		PartCode     string  `json:"part_code" binding:"required"`
+		Unit         string  `json:"unit" binding:"required"`
+		Qty          float64 `json:"qty" binding:"required"`
+		MovementDate string  `json:"movement_date" binding:"required"`
+		Comment      string  `json:"comment" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.MovementDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	id, err := h.repo.PostAdjustment(c.Request.Context(), req.WarehouseNo, req.PartCode, req.Unit, req.Qty, req.MovementDate, req.Comment)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to post adjustment: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Adjustment posted successfully", "id": id})
+}
+
+func (h *Handler) ClosePeriod(c *gin.Context) {
+	var req struct {
+		Period   string `json:"period" binding:"required"`
+		ClosedBy string `json:"closed_by" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := time.Parse("2006-01", req.Period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period format. Use YYYY-MM"})
+		return
+	}
+
+	if err := h.repo.ClosePeriod(c.Request.Context(), period, req.ClosedBy); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to close period: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Period closed successfully"})
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) Lots(c *gin.Context) {
+	lotNo := c.Query("lot_no")
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 0 {
+		c.String(http.StatusBadRequest, "Invalid days")
+		return
+	}
+
+	var holdings []domain.LotBalance
+	if lotNo != "" {
+		holdings, err = h.repo.FindLot(c.Request.Context(), lotNo)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Error fetching lot: %v", err)
+			return
+		}
+	}
+	expiring, err := h.repo.GetExpiringLots(c.Request.Context(), days)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching expiring lots: %v", err)
+		return
+	}
+	lots, err := h.repo.GetDeliveryLots(c.Request.Context(), 0, 0)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching delivery lots: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "lots.html", gin.H{
+		"Title":    "Lots",
+		"LotNo":    lotNo,
+		"Holdings": holdings,
+		"Days":     days,
+		"Expiring": expiring,
+		"Lots":     lots,
+		"Today":    time.Now().Format("2006-01-02"),
+	})
+}
+
+func (h *Handler) FindLot(c *gin.Context) {
+	lotNo := c.Query("lot_no")
+	if lotNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lot_no is required"})
+		return
+	}
+
+	holdings, err := h.repo.FindLot(c.Request.Context(), lotNo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch lot: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, holdings)
+}
+
+func (h *Handler) AddDeliveryLots(c *gin.Context) {
+	var req struct {
+		WarehouseNo  int `json:"warehouse_no" binding:"required"`
+		ReceiptDocNo int `json:"receipt_doc_no" binding:"required"`
+		Lots         []struct {
+			LotNo            string  `json:"lot_no" binding:"required"`
+			Qty              float64 `json:"qty" binding:"required,gt=0"`
+			ManufacturedDate string  `json:"manufactured_date" binding:"required"`
+			ExpiryDate       string  `json:"expiry_date" binding:"required"`
+		} `json:"lots" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lots := make([]domain.DeliveryLot, 0, len(req.Lots))
+	for _, l := range req.Lots {
+		manufactured, err := time.Parse("2006-01-02", l.ManufacturedDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid manufactured_date format. Use YYYY-MM-DD"})
+			return
+		}
+		expiry, err := time.Parse("2006-01-02", l.ExpiryDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry_date format. Use YYYY-MM-DD"})
+			return
+		}
+		if expiry.Before(manufactured) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiry_date cannot be before manufactured_date"})
+			return
+		}
+		lots = append(lots, domain.DeliveryLot{
+			LotNo:            l.LotNo,
+			Qty:              l.Qty,
+			ManufacturedDate: manufactured,
+			ExpiryDate:       expiry,
+		})
+	}
+
+	if err := h.repo.AddDeliveryLots(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, lots); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to add lots: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lots added successfully"})
+}
+
+func (h *Handler) DeleteDeliveryLot(c *gin.Context) {
+	var req struct {
+		WarehouseNo  int    `json:"warehouse_no" binding:"required"`
+		ReceiptDocNo int    `json:"receipt_doc_no" binding:"required"`
+		LotNo        string `json:"lot_no" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteDeliveryLot(c.Request.Context(), req.WarehouseNo, req.ReceiptDocNo, req.LotNo); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete lot: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lot deleted successfully"})
+}
+
+// SuggestFEFO answers which lots to issue from: warehouse_no, part_code, unit and
+// qty are required, issued_date defaults to today.
+func (h *Handler) SuggestFEFO(c *gin.Context) {
+	warehouseNo, err := strconv.Atoi(c.Query("warehouse_no"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse_no"})
+		return
+	}
+	qty, err := strconv.ParseFloat(c.Query("qty"), 64)
+	if err != nil || qty <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid qty"})
+		return
+	}
+	partCode, unit := c.Query("part_code"), c.Query("unit")
+	if partCode == "" || unit == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "part_code and unit are required"})
+		return
+	}
+	on := c.DefaultQuery("issued_date", time.Now().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", on); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issued_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	picks, err := h.repo.SuggestFEFO(c.Request.Context(), warehouseNo, partCode, unit, qty, on)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to suggest lots: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, picks)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+type partRequest struct {
+	PartCode       string   `json:"part_code" binding:"required"`
+	Name           string   `json:"name" binding:"required"`
+	Description    *string  `json:"description"`
+	DefaultUnit    string   `json:"default_unit" binding:"required,oneof=pcs kg m set"`
+	WeightPerPiece *float64 `json:"weight_per_piece" binding:"omitempty,gt=0"`
+	Category       *string  `json:"category"`
+}
+
+func (req partRequest) part() domain.Part {
+	return domain.Part{
+		PartCode:       req.PartCode,
+		Name:           req.Name,
+		Description:    req.Description,
+		DefaultUnit:    req.DefaultUnit,
+		WeightPerPiece: req.WeightPerPiece,
+		Category:       req.Category,
+	}
+}
+
+func (h *Handler) Parts(c *gin.Context) {
+	parts, err := h.repo.GetParts(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching parts: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "parts.html", gin.H{
+		"Title": "Parts",
+		"Parts": parts,
+	})
+}
+
+func (h *Handler) ListParts(c *gin.Context) {
+	parts, err := h.repo.GetParts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch parts: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, parts)
+}
+
+func (h *Handler) CreatePart(c *gin.Context) {
+	var req partRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.CreatePart(c.Request.Context(), req.part()); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create part: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Part created successfully"})
+}
+
+func (h *Handler) UpdatePart(c *gin.Context) {
+	var req partRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.UpdatePart(c.Request.Context(), req.part()); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to update part: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Part updated successfully"})
+}
+
+func (h *Handler) DeletePart(c *gin.Context) {
+	var req struct {
+		PartCode string `json:"part_code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeletePart(c.Request.Context(), req.PartCode); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete part: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Part deleted successfully"})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) PenaltyReport(c *gin.Context) {
+	on, ok := onQuery(c)
+	if !ok {
+		return
+	}
+
+	claims, err := h.repo.GetPenaltyClaims(c.Request.Context(), on, 0)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching penalty claims: %v", err)
+		return
+	}
+	terms, err := h.repo.GetPenaltyTerms(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching penalty terms: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "penalties.html", gin.H{
+		"Title":  "Penalty Claims",
+		"On":     on,
+		"Claims": claims,
+		"Terms":  terms,
+	})
+}
+
+func (h *Handler) PenaltyClaimLetter(c *gin.Context) {
+	supplierID, err := strconv.Atoi(c.Query("supplier_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid supplier_id")
+		return
+	}
+	on, ok := onQuery(c)
+	if !ok {
+		return
+	}
+
+	supplier, err := h.repo.GetSupplier(c.Request.Context(), supplierID)
+	if err != nil {
+		c.String(errorStatus(err), "Error fetching supplier: %v", err)
+		return
+	}
+	claims, err := h.repo.GetPenaltyClaims(c.Request.Context(), on, supplierID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching penalty claims: %v", err)
+		return
+	}
+	events, err := h.repo.GetPenaltyEvents(c.Request.Context(), on, supplierID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching penalty accruals: %v", err)
+		return
+	}
+	var total float64
+	for _, cl := range claims {
+		total += cl.Penalty
+	}
+
+	c.HTML(http.StatusOK, "penalty_letter.html", gin.H{
+		"Title":    fmt.Sprintf("Penalty Claim: %s", supplier.Name),
+		"On":       on,
+		"Supplier": supplier,
+		"Claims":   claims,
+		"Events":   events,
+		"Total":    total,
+	})
+}
+
+func (h *Handler) SetPenaltyTerms(c *gin.Context) {
+	var req struct {
+		ContractNo   int     `json:"contract_no" binding:"required"`
+		PartCode     string  `json:"part_code" binding:"required"`
+		DailyRatePct float64 `json:"daily_rate_pct" binding:"required,gt=0"`
+		CapPct       float64 `json:"cap_pct" binding:"required,gt=0,lte=100"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	terms := domain.PenaltyTerms{
+		ContractNo:   req.ContractNo,
+		PartCode:     req.PartCode,
+		DailyRatePct: req.DailyRatePct,
+		CapPct:       req.CapPct,
+	}
+	if err := h.repo.SetPenaltyTerms(c.Request.Context(), terms); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to save penalty terms: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Penalty terms saved successfully"})
+}
+
+func (h *Handler) DeletePenaltyTerms(c *gin.Context) {
+	var req struct {
+		ContractNo int    `json:"contract_no" binding:"required"`
+		PartCode   string `json:"part_code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeletePenaltyTerms(c.Request.Context(), req.ContractNo, req.PartCode); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete penalty terms: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Penalty terms deleted successfully"})
+}
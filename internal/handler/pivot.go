@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+	"github.com/railgorail/kpfu-db-app/internal/repository"
+)
+
+// pivotQuery reads measure (value by default), the optional group_by and the
+// optional from/to dates of the pivot report.
+func pivotQuery(c *gin.Context) (measure, groupBy, from, to string, ok bool) {
+	measure = c.DefaultQuery("measure", "value")
+	if !repository.IsPivotMeasure(measure) {
+		c.String(http.StatusBadRequest, "Invalid measure. Use qty or value")
+		return "", "", "", "", false
+	}
+	groupBy = c.Query("group_by")
+	if !repository.IsPivotGroup(groupBy) {
+		c.String(http.StatusBadRequest, "Invalid group_by. Use part or contract")
+		return "", "", "", "", false
+	}
+	from, to, ok = periodQuery(c)
+	return measure, groupBy, from, to, ok
+}
+
+func (h *Handler) PivotReport(c *gin.Context) {
+	measure, groupBy, from, to, ok := pivotQuery(c)
+	if !ok {
+		return
+	}
+
+	pivot, err := h.repo.GetPivot(c.Request.Context(), measure, groupBy, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error building pivot: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "pivot.html", gin.H{
+		"Title":        "Pivot Report",
+		"Pivot":        pivot,
+		"From":         from,
+		"To":           to,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+func (h *Handler) ExportPivot(c *gin.Context) {
+	measure, groupBy, from, to, ok := pivotQuery(c)
+	if !ok {
+		return
+	}
+
+	pivot, err := h.repo.GetPivot(c.Request.Context(), measure, groupBy, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error building pivot: %v", err)
+		return
+	}
+
+	header := []string{"warehouse_no", "manager_surname"}
+	if groupBy != "" {
+		header = append(header, groupBy)
+	}
+	if measure == "qty" {
+		header = append(header, "unit")
+	}
+	header = append(header, pivot.Months...)
+	header = append(header, "total")
+	if measure == "value" {
+		header = append(header, "rate_missing")
+	}
+
+	record := func(warehouse, manager, group string, row domain.PivotRow) []string {
+		rec := []string{warehouse, manager}
+		if groupBy != "" {
+			rec = append(rec, group)
+		}
+		if measure == "qty" {
+			rec = append(rec, row.Unit)
+		}
+		for i := range row.Cells {
+			rec = append(rec, formatQty(&row.Cells[i]))
+		}
+		rec = append(rec, formatQty(&row.Total))
+		if measure == "value" {
+			rec = append(rec, fmt.Sprint(row.RateMissing))
+		}
+		return rec
+	}
+
+	records := [][]string{header}
+	for _, row := range pivot.Rows {
+		group := row.Group
+		if row.Subtotal {
+			group = "subtotal"
+		}
+		records = append(records, record(strconv.Itoa(row.WarehouseNo), row.ManagerSurname, group, row))
+	}
+	for _, row := range pivot.Totals {
+		records = append(records, record("total", "", "", row))
+	}
+	name := "pivot_" + measure
+	if groupBy != "" {
+		name += "_by_" + groupBy
+	}
+	writeCSV(c, name+".csv", records)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/reports"
+)
+
+// reportCell is a formatted result value with the optional link of its column.
+type reportCell struct {
+	Text string
+	Link string
+}
+
+// registerReports adds the page, CSV export and JSON endpoint of every report
+// in the registry.
+func (h *Handler) registerReports(r *gin.Engine, api *gin.RouterGroup) {
+	for _, rep := range reports.All() {
+		r.GET(rep.Path, h.reportPage(rep))
+		r.GET(rep.Path+"/export", h.exportReport(rep))
+		api.GET("/reports/"+rep.Name, h.reportData(rep))
+	}
+}
+
+// runReport parses the query parameters of rep and runs it. On failure it
+// writes the error response and returns ok == false.
+func (h *Handler) runReport(c *gin.Context, rep *reports.Report) (rows [][]any, values map[string]string, ok bool) {
+	args, values, err := rep.Args(c.Query, time.Now())
+	if err != nil {
+		c.String(http.StatusBadRequest, "%v", err)
+		return nil, nil, false
+	}
+	rows, err = h.repo.RunReport(c.Request.Context(), rep, args)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error running %s: %v", rep.Title, err)
+		return nil, nil, false
+	}
+	return rows, values, true
+}
+
+func (h *Handler) reportPage(rep *reports.Report) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, values, ok := h.runReport(c, rep)
+		if !ok {
+			return
+		}
+
+		cells := make([][]reportCell, len(rows))
+		for i, row := range rows {
+			cells[i] = make([]reportCell, len(row))
+			for j, v := range row {
+				cells[i][j] = reportCell{
+					Text: reports.Format(rep.Columns[j].Type, v),
+					Link: rep.LinkFor(j, row),
+				}
+			}
+		}
+
+		query := "?" + rep.Query(values)
+		c.HTML(http.StatusOK, "report.html", gin.H{
+			"Title":     rep.Title,
+			"Report":    rep,
+			"Values":    values,
+			"ExportURL": rep.Path + "/export" + query,
+			"DataURL":   "/api/reports/" + rep.Name + query,
+			"Rows":      cells,
+		})
+	}
+}
+
+func (h *Handler) exportReport(rep *reports.Report) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, _, ok := h.runReport(c, rep)
+		if !ok {
+			return
+		}
+
+		header := make([]string, len(rep.Columns))
+		for i, col := range rep.Columns {
+			header[i] = col.Name
+		}
+		records := [][]string{header}
+		for _, row := range rows {
+			rec := make([]string, len(row))
+			for j, v := range row {
+				rec[j] = reports.Format(rep.Columns[j].Type, v)
+			}
+			records = append(records, rec)
+		}
+		writeCSV(c, rep.Name+".csv", records)
+	}
+}
+
+func (h *Handler) reportData(rep *reports.Report) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, values, ok := h.runReport(c, rep)
+		if !ok {
+			return
+		}
+
+		objects := make([]map[string]any, len(rows))
+		for i, row := range rows {
+			objects[i] = make(map[string]any, len(row))
+			for j, v := range row {
+				objects[i][rep.Columns[j].Name] = rep.Columns[j].JSONValue(v)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"report": rep,
+			"params": values,
+			"rows":   objects,
+		})
+	}
+}
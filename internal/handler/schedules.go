@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// onQuery reads the optional as-of date of the schedule reports, today by default.
+func onQuery(c *gin.Context) (string, bool) {
+	on := c.DefaultQuery("on", time.Now().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", on); err != nil {
+		c.String(http.StatusBadRequest, "Invalid on format. Use YYYY-MM-DD")
+		return "", false
+	}
+	return on, true
+}
+
+func (h *Handler) ContractSchedule(c *gin.Context) {
+	contractNo, err := strconv.Atoi(c.Query("contract_no"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid contract_no")
+		return
+	}
+	partCode := c.Query("part_code")
+	on, ok := onQuery(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.repo.GetSchedule(c.Request.Context(), contractNo, partCode)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching schedule: %v", err)
+		return
+	}
+	all, err := h.repo.GetTrancheStatus(c.Request.Context(), on, false)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error matching deliveries to tranches: %v", err)
+		return
+	}
+	var tranches []domain.TrancheStatus
+	for _, t := range all {
+		if t.ContractNo == contractNo && t.PartCode == partCode {
+			tranches = append(tranches, t)
+		}
+	}
+
+	c.HTML(http.StatusOK, "contract_schedule.html", gin.H{
+		"Title":      "Delivery Schedule",
+		"ContractNo": contractNo,
+		"PartCode":   partCode,
+		"On":         on,
+		"Schedule":   schedule,
+		"Tranches":   tranches,
+	})
+}
+
+func (h *Handler) TrancheReport(c *gin.Context) {
+	on, ok := onQuery(c)
+	if !ok {
+		return
+	}
+
+	priorities, err := h.repo.GetSchedulePriorities(c.Request.Context(), on)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching schedule priorities: %v", err)
+		return
+	}
+	tranches, err := h.repo.GetTrancheStatus(c.Request.Context(), on, true)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching late and short tranches: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "tranches.html", gin.H{
+		"Title":      "Late and Short Tranches",
+		"On":         on,
+		"Priorities": priorities,
+		"Tranches":   tranches,
+	})
+}
+
+func (h *Handler) GetSchedule(c *gin.Context) {
+	contractNo, err := strconv.Atoi(c.Query("contract_no"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid contract_no"})
+		return
+	}
+
+	schedule, err := h.repo.GetSchedule(c.Request.Context(), contractNo, c.Query("part_code"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch schedule: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *Handler) SetSchedule(c *gin.Context) {
+	var req struct {
+		ContractNo int    `json:"contract_no" binding:"required"`
+		PartCode   string `json:"part_code" binding:"required"`
+		Tranches   []struct {
+			DueDate    string  `json:"due_date" binding:"required"`
+			PlannedQty float64 `json:"planned_qty" binding:"required,gt=0"`
+		} `json:"tranches" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tranches := make([]domain.ScheduleTranche, 0, len(req.Tranches))
+	for _, t := range req.Tranches {
+		dueDate, err := time.Parse("2006-01-02", t.DueDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format. Use YYYY-MM-DD"})
+			return
+		}
+		tranches = append(tranches, domain.ScheduleTranche{DueDate: dueDate, PlannedQty: t.PlannedQty})
+	}
+
+	exceeded, err := h.repo.SetSchedule(c.Request.Context(), req.ContractNo, req.PartCode, tranches)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to save schedule: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule saved successfully", "budget_warnings": exceeded})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SupplierScorecard(c *gin.Context) {
+	from, to, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	scorecard, err := h.repo.GetSupplierScorecard(c.Request.Context(), from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching supplier scorecard: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "scorecard.html", gin.H{
+		"Title":     "Supplier Scorecard",
+		"Scorecard": scorecard,
+		"From":      from,
+		"To":        to,
+	})
+}
+
+func (h *Handler) SupplierScorecardDetail(c *gin.Context) {
+	from, to, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+	supplierID, err := strconv.Atoi(c.Query("supplier_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid supplier_id")
+		return
+	}
+
+	supplier, err := h.repo.GetSupplier(c.Request.Context(), supplierID)
+	if err != nil {
+		c.String(errorStatus(err), "Error fetching supplier: %v", err)
+		return
+	}
+	lines, err := h.repo.GetSupplierLinePerformance(c.Request.Context(), supplierID, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching supplier lines: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "scorecard_detail.html", gin.H{
+		"Title":    fmt.Sprintf("%s Scorecard", supplier.Name),
+		"Supplier": supplier,
+		"Lines":    lines,
+		"From":     from,
+		"To":       to,
+	})
+}
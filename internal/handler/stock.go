@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) Stock(c *gin.Context) {
+	warehouseNo, err := strconv.Atoi(c.DefaultQuery("warehouse_no", "0"))
+	if err != nil {
+		warehouseNo = 0
+	}
+
+	balance, err := h.repo.GetStockBalance(c.Request.Context(), warehouseNo)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching stock balance: %v", err)
+		return
+	}
+	issues, err := h.repo.GetIssues(c.Request.Context(), warehouseNo)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching issues: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "stock.html", gin.H{
+		"Title":       "Stock",
+		"WarehouseNo": warehouseNo,
+		"Balance":     balance,
+		"Issues":      issues,
+	})
+}
+
+func (h *Handler) StockBalance(c *gin.Context) {
+	warehouseNo, err := strconv.Atoi(c.DefaultQuery("warehouse_no", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse_no"})
+		return
+	}
+
+	balance, err := h.repo.GetStockBalance(c.Request.Context(), warehouseNo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch stock balance: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, balance)
+}
+
+func (h *Handler) CreateIssue(c *gin.Context) {
+	var req struct {
+		WarehouseNo int     `json:"warehouse_no" binding:"required"`
+		IssueDocNo  int     `json:"issue_doc_no" binding:"required"`
+		PartCode    string  `json:"part_code" binding:"required"`
+		Unit        string  `json:"unit" binding:"required"`
+		Qty         float64 `json:"qty" binding:"required"`
+		IssuedDate  string  `json:"issued_date" binding:"required"`
+		Recipient   string  `json:"recipient" binding:"required"`
+		// lot to issue from, all three or none
+		OriginWarehouseNo  *int    `json:"origin_warehouse_no"`
+		OriginReceiptDocNo *int    `json:"origin_receipt_doc_no"`
+		LotNo              *string `json:"lot_no"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	issuedDate, err := time.Parse("2006-01-02", req.IssuedDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issued_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	if (req.OriginWarehouseNo == nil) != (req.LotNo == nil) || (req.OriginReceiptDocNo == nil) != (req.LotNo == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "origin_warehouse_no, origin_receipt_doc_no and lot_no go together"})
+		return
+	}
+
+	issue := domain.Issue{
+		WarehouseNo:        req.WarehouseNo,
+		IssueDocNo:         req.IssueDocNo,
+		PartCode:           req.PartCode,
+		Unit:               req.Unit,
+		Qty:                req.Qty,
+		IssuedDate:         issuedDate,
+		Recipient:          req.Recipient,
+		OriginWarehouseNo:  req.OriginWarehouseNo,
+		OriginReceiptDocNo: req.OriginReceiptDocNo,
+		LotNo:              req.LotNo,
+	}
+	if err := h.repo.CreateIssue(c.Request.Context(), issue); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create issue: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Issue created successfully"})
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) StockCounts(c *gin.Context) {
+	managerSurname := c.Query("manager_surname")
+
+	counts, err := h.repo.GetStockCounts(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching stock counts: %v", err)
+		return
+	}
+	managers, err := h.repo.GetManagerVariances(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching manager variances: %v", err)
+		return
+	}
+	variances, err := h.repo.GetStockCountVariances(c.Request.Context(), managerSurname)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching variance history: %v", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "stock_counts.html", gin.H{
+		"Title":          "Stock Counts",
+		"Counts":         counts,
+		"Managers":       managers,
+		"Variances":      variances,
+		"ManagerSurname": managerSurname,
+	})
+}
+
+func (h *Handler) StockCountSheet(c *gin.Context) {
+	countNo, err := strconv.Atoi(c.Query("count_no"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid count_no")
+		return
+	}
+
+	sheet, err := h.repo.GetStockCount(c.Request.Context(), countNo)
+	if err != nil {
+		c.String(errorStatus(err), "Error fetching stock count: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "stock_count_sheet.html", gin.H{
+		"Title": fmt.Sprintf("Stock Count %d", countNo),
+		"Sheet": sheet,
+	})
+}
+
+func (h *Handler) ExportStockCount(c *gin.Context) {
+	countNo, err := strconv.Atoi(c.Query("count_no"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid count_no")
+		return
+	}
+
+	sheet, err := h.repo.GetStockCount(c.Request.Context(), countNo)
+	if err != nil {
+		c.String(errorStatus(err), "Error fetching stock count: %v", err)
+		return
+	}
+
+	records := [][]string{{"count_no", "warehouse_no", "manager_surname", "count_date", "part_code", "unit", "expected_qty", "counted_qty", "variance_qty"}}
+	for _, l := range sheet.Lines {
+		records = append(records, []string{
+			strconv.Itoa(sheet.CountNo),
+			strconv.Itoa(sheet.WarehouseNo),
+			sheet.ManagerSurname,
+			sheet.CountDate.Format("2006-01-02"),
+			l.PartCode,
+			l.Unit,
+			formatQty(&l.ExpectedQty),
+			formatQty(l.CountedQty),
+			formatQty(l.VarianceQty),
+		})
+	}
+	writeCSV(c, fmt.Sprintf("stock_count_%d.csv", countNo), records)
+}
+
+func (h *Handler) CreateStockCount(c *gin.Context) {
+	var req struct {
+		WarehouseNo int    `json:"warehouse_no" binding:"required"`
+		CountDate   string `json:"count_date" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.CountDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	countNo, err := h.repo.CreateStockCount(c.Request.Context(), req.WarehouseNo, req.CountDate)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create stock count: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock count created successfully", "count_no": countNo})
+}
+
+func (h *Handler) SetCountedQty(c *gin.Context) {
+	var req struct {
+		CountNo    int      `json:"count_no" binding:"required"`
+		PartCode   string   `json:"part_code" binding:"required"`
+		Unit       string   `json:"unit" binding:"required"`
+		CountedQty *float64 `json:"counted_qty" binding:"required,gte=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.SetCountedQty(c.Request.Context(), req.CountNo, req.PartCode, req.Unit, *req.CountedQty); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to record counted qty: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Counted qty recorded successfully"})
+}
+
+func (h *Handler) ApproveStockCount(c *gin.Context) {
+	var req struct {
+		CountNo    int    `json:"count_no" binding:"required"`
+		ApprovedBy string `json:"approved_by" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.ApproveStockCount(c.Request.Context(), req.CountNo, req.ApprovedBy); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to approve stock count: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock count approved successfully"})
+}
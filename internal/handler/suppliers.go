@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+type supplierRequest struct {
+	Name          string  `json:"name" binding:"required"`
+	INN           string  `json:"inn" binding:"required,numeric"`
+	Address       *string `json:"address"`
+	ContactPerson *string `json:"contact_person"`
+	Phone         *string `json:"phone"`
+	Email         *string `json:"email" binding:"omitempty,email"`
+}
+
+func (req supplierRequest) supplier(supplierID int) domain.Supplier {
+	return domain.Supplier{
+		SupplierID:    supplierID,
+		Name:          req.Name,
+		INN:           req.INN,
+		Address:       req.Address,
+		ContactPerson: req.ContactPerson,
+		Phone:         req.Phone,
+		Email:         req.Email,
+	}
+}
+
+type contractHeaderRequest struct {
+	ContractNo int    `json:"contract_no" binding:"required"`
+	SupplierID int    `json:"supplier_id" binding:"required"`
+	SignedDate string `json:"signed_date" binding:"required"`
+	Status     string `json:"status" binding:"required,oneof=draft active closed terminated"`
+}
+
+func (h *Handler) Suppliers(c *gin.Context) {
+	suppliers, err := h.repo.GetSuppliers(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching suppliers: %v", err)
+		return
+	}
+	headers, err := h.repo.GetContractHeaders(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching contract headers: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "suppliers.html", gin.H{
+		"Title":     "Suppliers",
+		"Suppliers": suppliers,
+		"Headers":   headers,
+	})
+}
+
+func (h *Handler) DeliveriesBySupplier(c *gin.Context) {
+	from, to, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.repo.GetDeliveriesBySupplier(c.Request.Context(), from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching deliveries by supplier: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "deliveries_by_supplier.html", gin.H{
+		"Title":        "Deliveries by Supplier",
+		"Report":       report,
+		"From":         from,
+		"To":           to,
+		"BaseCurrency": domain.BaseCurrency,
+	})
+}
+
+func (h *Handler) ExportDeliveriesBySupplier(c *gin.Context) {
+	from, to, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.repo.GetDeliveriesBySupplier(c.Request.Context(), from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching deliveries by supplier: %v", err)
+		return
+	}
+
+	records := [][]string{{"supplier_id", "supplier_name", "inn", "part_code", "unit", "deliveries", "qty", "currency", "value", "base_value"}}
+	for _, r := range report {
+		records = append(records, []string{
+			strconv.Itoa(r.SupplierID),
+			r.SupplierName,
+			r.INN,
+			r.PartCode,
+			r.Unit,
+			strconv.Itoa(r.Deliveries),
+			formatQty(&r.Qty),
+			r.Currency,
+			formatQty(&r.Value),
+			formatQty(r.BaseValue),
+		})
+	}
+	writeCSV(c, "deliveries_by_supplier.csv", records)
+}
+
+// periodQuery reads the optional from/to query dates. On a malformed date it
+// responds with 400 and returns ok == false.
+func periodQuery(c *gin.Context) (from, to string, ok bool) {
+	from, to = c.Query("from"), c.Query("to")
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.String(http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+			return "", "", false
+		}
+	}
+	return from, to, true
+}
+
+func (h *Handler) ListSuppliers(c *gin.Context) {
+	suppliers, err := h.repo.GetSuppliers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch suppliers: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, suppliers)
+}
+
+func (h *Handler) CreateSupplier(c *gin.Context) {
+	var req supplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplierID, err := h.repo.CreateSupplier(c.Request.Context(), req.supplier(0))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create supplier: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier created successfully", "supplier_id": supplierID})
+}
+
+func (h *Handler) UpdateSupplier(c *gin.Context) {
+	var req struct {
+		SupplierID int `json:"supplier_id" binding:"required"`
+		supplierRequest
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.UpdateSupplier(c.Request.Context(), req.supplier(req.SupplierID)); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to update supplier: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier updated successfully"})
+}
+
+func (h *Handler) DeleteSupplier(c *gin.Context) {
+	var req struct {
+		SupplierID int `json:"supplier_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteSupplier(c.Request.Context(), req.SupplierID); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete supplier: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier deleted successfully"})
+}
+
+func (h *Handler) ListContractHeaders(c *gin.Context) {
+	headers, err := h.repo.GetContractHeaders(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch contract headers: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, headers)
+}
+
+func (h *Handler) CreateContractHeader(c *gin.Context) {
+	var req contractHeaderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.SignedDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signed_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	if err := h.repo.CreateContractHeader(c.Request.Context(), req.ContractNo, req.SupplierID, req.SignedDate, req.Status); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create contract header: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contract header created successfully"})
+}
+
+func (h *Handler) UpdateContractHeader(c *gin.Context) {
+	var req contractHeaderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.SignedDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signed_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	if err := h.repo.UpdateContractHeader(c.Request.Context(), req.ContractNo, req.SupplierID, req.SignedDate, req.Status); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to update contract header: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contract header updated successfully"})
+}
+
+func (h *Handler) DeleteContractHeader(c *gin.Context) {
+	var req struct {
+		ContractNo int `json:"contract_no" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteContractHeader(c.Request.Context(), req.ContractNo); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to delete contract header: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contract header deleted successfully"})
+}
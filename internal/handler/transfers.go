@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (h *Handler) Transfers(c *gin.Context) {
+	transfers, err := h.repo.GetTransfers(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching transfers: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "transfers.html", gin.H{
+		"Title":     "Transfers",
+		"Transfers": transfers,
+	})
+}
+
+func (h *Handler) StockMovements(c *gin.Context) {
+	warehouseNo, err := strconv.Atoi(c.DefaultQuery("warehouse_no", "0"))
+	if err != nil {
+		warehouseNo = 0
+	}
+
+	movements, err := h.repo.GetStockMovements(c.Request.Context(), warehouseNo)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching stock movements: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "movements.html", gin.H{
+		"Title":       "Stock Movements",
+		"WarehouseNo": warehouseNo,
+		"Movements":   movements,
+	})
+}
+
+func (h *Handler) CreateTransfer(c *gin.Context) {
+	var req struct {
+		FromWarehouseNo int    `json:"from_warehouse_no" binding:"required"`
+		ToWarehouseNo   int    `json:"to_warehouse_no" binding:"required"`
+		ShippedDate     string `json:"shipped_date" binding:"required"`
+		Lines           []struct {
+			PartCode           string  `json:"part_code" binding:"required"`
+			Unit               string  `json:"unit" binding:"required"`
+			Qty                float64 `json:"qty" binding:"required"`
+			OriginWarehouseNo  *int    `json:"origin_warehouse_no"`
+			OriginReceiptDocNo *int    `json:"origin_receipt_doc_no"`
+			LotNo              *string `json:"lot_no"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.ShippedDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipped_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	lines := make([]domain.TransferLine, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		if (l.OriginWarehouseNo == nil) != (l.OriginReceiptDocNo == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "origin_warehouse_no and origin_receipt_doc_no go together"})
+			return
+		}
+		if l.LotNo != nil && l.OriginWarehouseNo == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lot_no needs origin_warehouse_no and origin_receipt_doc_no"})
+			return
+		}
+		lines = append(lines, domain.TransferLine{
+			PartCode:           l.PartCode,
+			Unit:               l.Unit,
+			Qty:                l.Qty,
+			OriginWarehouseNo:  l.OriginWarehouseNo,
+			OriginReceiptDocNo: l.OriginReceiptDocNo,
+			LotNo:              l.LotNo,
+		})
+	}
+
+	transferNo, err := h.repo.CreateTransfer(c.Request.Context(), req.FromWarehouseNo, req.ToWarehouseNo, req.ShippedDate, lines)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to create transfer: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer shipped successfully", "transfer_no": transferNo})
+}
+
+func (h *Handler) ReceiveTransfer(c *gin.Context) {
+	var req struct {
+		TransferNo   int    `json:"transfer_no" binding:"required"`
+		ReceivedDate string `json:"received_date" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.ReceivedDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid received_date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	if err := h.repo.ReceiveTransfer(c.Request.Context(), req.TransferNo, req.ReceivedDate); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to receive transfer: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer received successfully"})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// trashRequest addresses one trashed row; which key fields are used depends on Entity.
+type trashRequest struct {
+	Entity       string `json:"entity" binding:"required,oneof=warehouse contract delivery"`
+	WarehouseNo  int    `json:"warehouse_no"`
+	ReceiptDocNo int    `json:"receipt_doc_no"`
+	ContractNo   int    `json:"contract_no"`
+	PartCode     string `json:"part_code"`
+}
+
+func (h *Handler) Trash(c *gin.Context) {
+	trash, err := h.repo.GetTrash(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching trash: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "trash.html", gin.H{
+		"Title": "Trash",
+		"Trash": trash,
+	})
+}
+
+func (h *Handler) RestoreFromTrash(c *gin.Context) {
+	var req trashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var exceeded []domain.Budget
+	var err error
+	switch req.Entity {
+	case "warehouse":
+		err = h.repo.RestoreWarehouse(ctx, req.WarehouseNo)
+	case "contract":
+		exceeded, err = h.repo.RestoreContract(ctx, req.ContractNo, req.PartCode)
+	case "delivery":
+		err = h.repo.RestoreDelivery(ctx, req.WarehouseNo, req.ReceiptDocNo)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to restore %s: %v", req.Entity, err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Restored successfully", "budget_warnings": exceeded})
+}
+
+func (h *Handler) PurgeFromTrash(c *gin.Context) {
+	var req trashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var err error
+	switch req.Entity {
+	case "warehouse":
+		err = h.repo.PurgeWarehouse(ctx, req.WarehouseNo)
+	case "contract":
+		err = h.repo.PurgeContract(ctx, req.ContractNo, req.PartCode)
+	case "delivery":
+		err = h.repo.PurgeDelivery(ctx, req.WarehouseNo, req.ReceiptDocNo)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": fmt.Sprintf("Failed to purge %s: %v", req.Entity, err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purged successfully"})
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseAsOf reads the optional ?as_of=YYYY-MM-DD query parameter.
+func parseAsOf(c *gin.Context) (*time.Time, error) {
+	asOfStr := c.Query("as_of")
+	if asOfStr == "" {
+		return nil, nil
+	}
+	asOf, err := time.Parse("2006-01-02", asOfStr)
+	if err != nil {
+		return nil, err
+	}
+	return &asOf, nil
+}
+
+func (h *Handler) ContractHistory(c *gin.Context) {
+	contractNo, err := strconv.Atoi(c.Query("contract_no"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid contract_no")
+		return
+	}
+	partCode := c.Query("part_code")
+
+	history, err := h.repo.GetContractHistory(c.Request.Context(), contractNo, partCode)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching contract history: %v", err)
+		return
+	}
+	amendments, err := h.repo.GetAmendments(c.Request.Context(), contractNo, partCode)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error fetching amendments: %v", err)
+		return
+	}
+	c.HTML(http.StatusOK, "contract_history.html", gin.H{
+		"Title":      "Contract History",
+		"ContractNo": contractNo,
+		"PartCode":   partCode,
+		"History":    history,
+		"Amendments": amendments,
+	})
+}
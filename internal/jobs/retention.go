@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/railgorail/kpfu-db-app/internal/repository"
+)
+
+// RunTrashRetention purges trash older than days every interval until ctx is done.
+func RunTrashRetention(ctx context.Context, repo *repository.Repository, days int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := repo.PurgeExpiredTrash(ctx, days)
+		if err != nil {
+			log.Printf("trash retention: %v", err)
+		} else if purged > 0 {
+			log.Printf("trash retention: purged %d rows older than %d days", purged, days)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
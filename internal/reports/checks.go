@@ -0,0 +1,22 @@
+package reports
+
+import "errors"
+
+// checks holds the cross-parameter rules of the reports, keyed by report name.
+var checks = map[string]func(args map[string]any) error{
+	"task2": anyPositive("At least one weight must be positive",
+		"w_days", "w_remaining", "w_value", "w_supplier"),
+}
+
+// anyPositive requires at least one of the float parameters names to be
+// greater than zero; msg is the error otherwise.
+func anyPositive(msg string, names ...string) func(args map[string]any) error {
+	return func(args map[string]any) error {
+		for _, name := range names {
+			if f, ok := args[name].(float64); ok && f > 0 {
+				return nil
+			}
+		}
+		return errors.New(msg)
+	}
+}
@@ -0,0 +1,447 @@
+// Package reports holds the registry of read-only reports declared as SQL files.
+//
+// Each file in sql/ is one report: a header of "-- key: value" comment lines
+// followed by the query. The header keys are
+//
+//	-- title: Task 1
+//	-- description: free text, may repeat
+//	-- path: /task/1                    (default /reports/<file name>)
+//	-- param: <name> <type> [default=<v>] [min=<n>] [max=<n>] [optional] [label="..."]
+//	-- column: <name> <type> [label="..."] [link="/path?x={column}"]
+//
+// Parameter types are text, int, float and date; a date default may be
+// "today" and an optional parameter left empty is passed as NULL. Column
+// types are text, int, float, money, date and bool. The query refers to
+// parameters as @name and must return the declared columns in order.
+package reports
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Type is the type of a report parameter or column.
+type Type string
+
+const (
+	Text  Type = "text"
+	Int   Type = "int"
+	Float Type = "float"
+	Money Type = "money"
+	Date  Type = "date"
+	Bool  Type = "bool"
+)
+
+// Param is a typed query parameter of a report.
+type Param struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label"`
+	Type     Type     `json:"type"`
+	Default  string   `json:"default,omitempty"`
+	Optional bool     `json:"optional"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// Column describes a column of the report result.
+type Column struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  Type   `json:"type"`
+	Link  string `json:"link,omitempty"`
+}
+
+// Report is a report loaded from sql/<Name>.sql.
+type Report struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Path        string   `json:"path"`
+	Params      []Param  `json:"params"`
+	Columns     []Column `json:"columns"`
+	SQL         string   `json:"-"`
+
+	// Check validates the converted arguments as a whole, for rules that
+	// span several parameters. It is nil for most reports.
+	Check func(args map[string]any) error `json:"-"`
+}
+
+var registry = mustLoad()
+
+// All returns the registered reports ordered by name.
+func All() []*Report {
+	return registry
+}
+
+// Get returns the report named name.
+func Get(name string) (*Report, bool) {
+	for _, r := range registry {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+func mustLoad() []*Report {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		panic(err)
+	}
+	var reports []*Report
+	for _, e := range entries {
+		data, err := files.ReadFile(path.Join("sql", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		r, err := Parse(strings.TrimSuffix(e.Name(), ".sql"), string(data))
+		if err != nil {
+			panic(fmt.Sprintf("reports: %s: %v", e.Name(), err))
+		}
+		r.Check = checks[r.Name]
+		reports = append(reports, r)
+	}
+	for name := range checks {
+		if !slices.ContainsFunc(reports, func(r *Report) bool { return r.Name == name }) {
+			panic(fmt.Sprintf("reports: check for unknown report %q", name))
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
+
+var (
+	headerLine  = regexp.MustCompile(`^--\s*([a-z_]+):\s*(.*)$`)
+	identifier  = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	placeholder = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+)
+
+// Parse reads a report from the header and query of a SQL file.
+func Parse(name, source string) (*Report, error) {
+	r := &Report{Name: name, Path: "/reports/" + name}
+	lines := strings.Split(source, "\n")
+	body := len(lines)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "--") {
+			body = i
+			break
+		}
+		m := headerLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, value := m[1], strings.TrimSpace(m[2])
+		switch key {
+		case "title":
+			r.Title = value
+		case "description":
+			r.Description = strings.TrimSpace(r.Description + " " + value)
+		case "path":
+			r.Path = value
+		case "param":
+			p, err := parseParam(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			r.Params = append(r.Params, p)
+		case "column":
+			c, err := parseColumn(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			r.Columns = append(r.Columns, c)
+		default:
+			return nil, fmt.Errorf("line %d: unknown header key %q", i+1, key)
+		}
+	}
+	r.SQL = strings.TrimSpace(strings.Join(lines[body:], "\n"))
+
+	if r.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if r.SQL == "" {
+		return nil, errors.New("query is empty")
+	}
+	if len(r.Columns) == 0 {
+		return nil, errors.New("no columns declared")
+	}
+	seen := map[string]bool{}
+	for _, p := range r.Params {
+		if seen[p.Name] {
+			return nil, fmt.Errorf("parameter %q declared twice", p.Name)
+		}
+		seen[p.Name] = true
+		if !strings.Contains(r.SQL, "@"+p.Name) {
+			return nil, fmt.Errorf("parameter %q is not used by the query", p.Name)
+		}
+		if p.Default != "" {
+			if _, err := p.Value(p.Default, time.Now()); err != nil {
+				return nil, fmt.Errorf("default of %q: %w", p.Name, err)
+			}
+		}
+	}
+	columns := map[string]bool{}
+	for _, c := range r.Columns {
+		if columns[c.Name] {
+			return nil, fmt.Errorf("column %q declared twice", c.Name)
+		}
+		columns[c.Name] = true
+	}
+	for _, c := range r.Columns {
+		for _, m := range placeholder.FindAllStringSubmatch(c.Link, -1) {
+			if !columns[m[1]] {
+				return nil, fmt.Errorf("link of column %q refers to unknown column %q", c.Name, m[1])
+			}
+		}
+	}
+	return r, nil
+}
+
+func parseParam(spec string) (Param, error) {
+	fields, err := splitFields(spec)
+	if err != nil {
+		return Param{}, err
+	}
+	if len(fields) < 2 || !identifier.MatchString(fields[0]) {
+		return Param{}, fmt.Errorf("param %q: want <name> <type> [attributes]", spec)
+	}
+	p := Param{Name: fields[0], Label: fields[0], Type: Type(fields[1])}
+	switch p.Type {
+	case Text, Int, Float, Date:
+	default:
+		return Param{}, fmt.Errorf("param %s: unknown type %q", p.Name, p.Type)
+	}
+	for _, f := range fields[2:] {
+		key, value, _ := strings.Cut(f, "=")
+		switch key {
+		case "default":
+			p.Default = value
+		case "label":
+			p.Label = value
+		case "optional":
+			p.Optional = true
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return Param{}, fmt.Errorf("param %s: invalid %s %q", p.Name, key, value)
+			}
+			if key == "min" {
+				p.Min = &n
+			} else {
+				p.Max = &n
+			}
+		default:
+			return Param{}, fmt.Errorf("param %s: unknown attribute %q", p.Name, key)
+		}
+	}
+	return p, nil
+}
+
+func parseColumn(spec string) (Column, error) {
+	fields, err := splitFields(spec)
+	if err != nil {
+		return Column{}, err
+	}
+	if len(fields) < 2 || !identifier.MatchString(fields[0]) {
+		return Column{}, fmt.Errorf("column %q: want <name> <type> [attributes]", spec)
+	}
+	c := Column{Name: fields[0], Label: fields[0], Type: Type(fields[1])}
+	switch c.Type {
+	case Text, Int, Float, Money, Date, Bool:
+	default:
+		return Column{}, fmt.Errorf("column %s: unknown type %q", c.Name, c.Type)
+	}
+	for _, f := range fields[2:] {
+		key, value, _ := strings.Cut(f, "=")
+		switch key {
+		case "label":
+			c.Label = value
+		case "link":
+			c.Link = value
+		default:
+			return Column{}, fmt.Errorf("column %s: unknown attribute %q", c.Name, key)
+		}
+	}
+	return c, nil
+}
+
+// splitFields splits s at spaces outside double quotes and unquotes the
+// values of key="quoted value" fields.
+func splitFields(s string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, ch := range s + " " {
+		switch {
+		case ch == '"':
+			quoted = !quoted
+		case ch == ' ' && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(ch)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	return fields, nil
+}
+
+// Value converts the raw form value of p, or its default when raw is empty, to
+// the argument passed to the query. An empty optional parameter is nil.
+func (p Param) Value(raw string, now time.Time) (any, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		raw = p.Default
+	}
+	if raw == "" {
+		if p.Optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s is required", p.Name)
+	}
+
+	switch p.Type {
+	case Int:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: want an integer", p.Name)
+		}
+		return n, p.checkRange(float64(n))
+	case Float:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(raw, ",", "."), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: want a number", p.Name)
+		}
+		return f, p.checkRange(f)
+	case Date:
+		if raw == "today" {
+			return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+		d, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s format. Use YYYY-MM-DD", p.Name)
+		}
+		return d, nil
+	}
+	return raw, nil
+}
+
+func (p Param) checkRange(v float64) error {
+	if p.Min != nil && v < *p.Min {
+		return fmt.Errorf("%s must be at least %v", p.Name, *p.Min)
+	}
+	if p.Max != nil && v > *p.Max {
+		return fmt.Errorf("%s must be at most %v", p.Name, *p.Max)
+	}
+	return nil
+}
+
+// InputType is the type of the HTML input of p.
+func (p Param) InputType() string {
+	switch p.Type {
+	case Int, Float:
+		return "number"
+	case Date:
+		return "date"
+	}
+	return "text"
+}
+
+// Args converts the raw values of all parameters, looked up with get, to the
+// query arguments and runs the Check of r on them. It also returns the
+// effective values for redisplay.
+func (r *Report) Args(get func(name string) string, now time.Time) (args map[string]any, values map[string]string, err error) {
+	args = make(map[string]any, len(r.Params))
+	values = make(map[string]string, len(r.Params))
+	for _, p := range r.Params {
+		v, err := p.Value(get(p.Name), now)
+		if err != nil {
+			return nil, nil, err
+		}
+		args[p.Name] = v
+		values[p.Name] = Format(p.Type, v)
+	}
+	if r.Check != nil {
+		if err := r.Check(args); err != nil {
+			return nil, nil, err
+		}
+	}
+	return args, values, nil
+}
+
+// Query encodes values as the query string of the report URLs.
+func (r *Report) Query(values map[string]string) string {
+	q := url.Values{}
+	for _, p := range r.Params {
+		if v := values[p.Name]; v != "" {
+			q.Set(p.Name, v)
+		}
+	}
+	return q.Encode()
+}
+
+// Format renders a result value of type t as text; nil is empty.
+func Format(t Type, v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02")
+	case float64:
+		if t == Money {
+			return fmt.Sprintf("%.2f", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// JSONValue renders a result value of column c for JSON output: dates as
+// YYYY-MM-DD, everything else as is.
+func (c Column) JSONValue(v any) any {
+	if d, ok := v.(time.Time); ok && c.Type == Date {
+		return d.Format("2006-01-02")
+	}
+	return v
+}
+
+// LinkFor fills the link template of column i with the values of row, or
+// returns "" when the column has no link.
+func (r *Report) LinkFor(i int, row []any) string {
+	link := r.Columns[i].Link
+	if link == "" {
+		return ""
+	}
+	return placeholder.ReplaceAllStringFunc(link, func(m string) string {
+		name := m[1 : len(m)-1]
+		for j, c := range r.Columns {
+			if c.Name == name {
+				return url.QueryEscape(Format(c.Type, row[j]))
+			}
+		}
+		return ""
+	})
+}
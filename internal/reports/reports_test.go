@@ -0,0 +1,175 @@
+package reports
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	const query = "SELECT n FROM t WHERE n > @min"
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			header: `-- title: Numbers
+-- param: min int default=0 min=0 label="Min value"
+-- column: n int label="N" link="/n?n={n}"`,
+		},
+		{
+			name: "unknown param type",
+			header: `-- title: Numbers
+-- param: min decimal
+-- column: n int`,
+			wantErr: `unknown type "decimal"`,
+		},
+		{
+			name: "unknown column type",
+			header: `-- title: Numbers
+-- param: min int
+-- column: n uuid`,
+			wantErr: `unknown type "uuid"`,
+		},
+		{
+			name: "unterminated quote",
+			header: `-- title: Numbers
+-- param: min int label="Min value
+-- column: n int`,
+			wantErr: "unterminated quote",
+		},
+		{
+			name: "unknown link column",
+			header: `-- title: Numbers
+-- param: min int
+-- column: n int link="/n?n={m}"`,
+			wantErr: `unknown column "m"`,
+		},
+		{
+			name: "invalid min",
+			header: `-- title: Numbers
+-- param: min int min=zero
+-- column: n int`,
+			wantErr: `invalid min "zero"`,
+		},
+		{
+			name: "default out of range",
+			header: `-- title: Numbers
+-- param: min int default=-1 min=0
+-- column: n int`,
+			wantErr: "must be at least 0",
+		},
+		{
+			name: "unknown header key",
+			header: `-- title: Numbers
+-- owner: sales
+-- param: min int
+-- column: n int`,
+			wantErr: `unknown header key "owner"`,
+		},
+		{
+			name: "unused param",
+			header: `-- title: Numbers
+-- param: min int
+-- param: max int
+-- column: n int`,
+			wantErr: `"max" is not used`,
+		},
+		{
+			name: "no title",
+			header: `-- param: min int
+-- column: n int`,
+			wantErr: "title is required",
+		},
+		{
+			name: "no columns",
+			header: `-- title: Numbers
+-- param: min int`,
+			wantErr: "no columns declared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse("numbers", tt.header+"\n"+query)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if r.SQL != query || r.Path != "/reports/numbers" || len(r.Params) != 1 || len(r.Columns) != 1 {
+					t.Errorf("Parse = %+v", r)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParamValue(t *testing.T) {
+	now := time.Date(2024, 3, 15, 18, 30, 0, 0, time.Local)
+	zero, hundred := 0.0, 100.0
+
+	tests := []struct {
+		name    string
+		param   Param
+		raw     string
+		want    any
+		wantErr string
+	}{
+		{name: "int", param: Param{Name: "n", Type: Int}, raw: " 42 ", want: int64(42)},
+		{name: "bad int", param: Param{Name: "n", Type: Int}, raw: "4.2", wantErr: "want an integer"},
+		{name: "float with comma", param: Param{Name: "x", Type: Float}, raw: "0,25", want: 0.25},
+		{name: "bad float", param: Param{Name: "x", Type: Float}, raw: "abc", wantErr: "want a number"},
+		{name: "below min", param: Param{Name: "x", Type: Float, Min: &zero}, raw: "-1", wantErr: "at least 0"},
+		{name: "above max", param: Param{Name: "n", Type: Int, Max: &hundred}, raw: "101", wantErr: "at most 100"},
+		{name: "on the bounds", param: Param{Name: "n", Type: Int, Min: &zero, Max: &hundred}, raw: "100", want: int64(100)},
+		{name: "date", param: Param{Name: "on", Type: Date}, raw: "2024-02-29", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "bad date", param: Param{Name: "on", Type: Date}, raw: "29.02.2024", wantErr: "Use YYYY-MM-DD"},
+		{name: "today default", param: Param{Name: "on", Type: Date, Default: "today"}, want: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "text", param: Param{Name: "q", Type: Text}, raw: "bolt", want: "bolt"},
+		{name: "empty optional", param: Param{Name: "q", Type: Text, Optional: true}, want: nil},
+		{name: "empty required", param: Param{Name: "q", Type: Text}, wantErr: "q is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.param.Value(tt.raw, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Value error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Value: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgsCheck(t *testing.T) {
+	r, ok := Get("task2")
+	if !ok {
+		t.Fatal("task2 is not registered")
+	}
+	now := time.Now()
+
+	zero := map[string]string{"w_days": "0", "w_remaining": "0", "w_value": "0", "w_supplier": "0"}
+	if _, _, err := r.Args(func(name string) string { return zero[name] }, now); err == nil {
+		t.Error("Args accepted all-zero weights")
+	}
+
+	one := map[string]string{"w_days": "0", "w_remaining": "0", "w_value": "1", "w_supplier": "0"}
+	if _, _, err := r.Args(func(name string) string { return one[name] }, now); err != nil {
+		t.Errorf("Args: %v", err)
+	}
+}
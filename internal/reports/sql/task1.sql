@@ -0,0 +1,27 @@
+-- title: Task 1
+-- description: Deliveries whose contract price in effect on the received date,
+-- description: converted at that day's exchange rate, exceeds the given price.
+-- path: /task/1
+-- param: price float default=100 label="Contract price greater than"
+-- param: as_of date optional label="As of"
+-- column: warehouse_no int label="Warehouse No"
+-- column: part_code text label="Part Code"
+-- column: receipt_doc_no int label="Receipt Doc No"
+-- column: received_date date label="Received Date"
+-- column: qty float label="Qty"
+-- column: contract_no int label="Contract No" link="/contracts/history?contract_no={contract_no}&part_code={part_code}"
+-- column: contract_price money label="Contract Price"
+-- column: currency text label="Currency"
+-- column: exchange_rate float label="Rate"
+-- column: base_price money label="Base Price"
+SELECT d.warehouse_no, d.part_code, d.receipt_doc_no, d.received_date, d.qty, d.contract_no,
+    v.contract_price, c.currency, x.rate AS exchange_rate, round(v.contract_price * x.rate, 2) AS base_price
+FROM deliveries d
+JOIN contracts c
+ON d.contract_no = c.contract_no AND d.part_code = c.part_code
+CROSS JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) v
+CROSS JOIN LATERAL (SELECT fn_exchange_rate(c.currency, d.received_date) AS rate) x
+WHERE v.contract_price * x.rate > @price
+AND d.deleted_at IS NULL AND c.deleted_at IS NULL
+AND (@as_of::date IS NULL OR d.received_date <= @as_of::date)
+ORDER BY d.received_date
@@ -0,0 +1,32 @@
+-- title: Task 2
+-- description: Expediting queue of open contract lines, best score first. The score
+-- description: weighs days left, remaining quantity, contract value and supplier score.
+-- description: Lines without an exchange rate are scored without the value.
+-- path: /task/2
+-- param: on date default=today label="As of"
+-- param: min_price float default=0 min=0 label="Min price"
+-- param: w_days float default=0.4 min=0 label="Days weight"
+-- param: w_remaining float default=0.3 min=0 label="Remaining weight"
+-- param: w_value float default=0.2 min=0 label="Value weight"
+-- param: w_supplier float default=0.1 min=0 label="Supplier weight"
+-- param: buyer text optional label="Buyer"
+-- column: priority int label="Priority"
+-- column: score float label="Score"
+-- column: contract_no int label="Contract No" link="/contracts/schedule?contract_no={contract_no}&part_code={part_code}"
+-- column: part_code text label="Part Code"
+-- column: supplier_name text label="Supplier"
+-- column: unit text label="Unit"
+-- column: plan_qty float label="Plan Qty"
+-- column: remaining_qty float label="Remaining Qty"
+-- column: end_date date label="End Date"
+-- column: days_left int label="Days Left"
+-- column: contract_value money label="Value"
+-- column: supplier_score float label="Supplier Score"
+-- column: buyer text label="Buyer"
+-- column: assignment_status text label="Status" link="/contracts/assignment?contract_no={contract_no}&part_code={part_code}"
+-- column: rate_missing bool label="Rate Missing"
+SELECT priority, score, contract_no, part_code, supplier_name, unit, plan_qty, remaining_qty,
+    end_date, days_left, contract_value, supplier_score, buyer,
+    COALESCE(assignment_status, 'unassigned') AS assignment_status, rate_missing
+FROM fn_contract_queue(@on::date, @min_price, @w_days, @w_remaining, @w_value, @w_supplier)
+WHERE @buyer::text IS NULL OR buyer = @buyer::text
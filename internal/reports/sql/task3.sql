@@ -0,0 +1,34 @@
+-- title: Task 3
+-- description: Contract lines with plan_qty above the given quantity for which at least
+-- description: one warehouse received only deliveries larger than the given delivery quantity.
+-- path: /task/3
+-- param: plan_qty int default=1000 label="Plan qty greater than"
+-- param: delivery_qty int default=50 label="Every delivery qty greater than"
+-- column: contract_no int label="Contract No" link="/contracts/history?contract_no={contract_no}&part_code={part_code}"
+-- column: part_code text label="Part Code"
+-- column: unit text label="Unit"
+-- column: start_date date label="Start Date"
+-- column: end_date date label="End Date"
+-- column: plan_qty float label="Plan Qty"
+-- column: contract_price money label="Contract Price"
+-- column: currency text label="Currency"
+SELECT c.contract_no, c.part_code, c.unit, c.start_date, c.end_date, c.plan_qty, c.contract_price, c.currency
+FROM contracts c
+WHERE c.plan_qty > @plan_qty
+AND c.deleted_at IS NULL
+AND EXISTS (
+    SELECT 1
+    FROM deliveries d
+    WHERE d.contract_no = c.contract_no
+    AND d.part_code = c.part_code
+    AND d.deleted_at IS NULL
+    AND @delivery_qty < ALL (
+        SELECT d2.qty
+        FROM deliveries d2
+        WHERE d2.contract_no = c.contract_no
+        AND d2.part_code = c.part_code
+        AND d2.warehouse_no = d.warehouse_no
+        AND d2.deleted_at IS NULL
+    )
+)
+ORDER BY c.contract_no, c.part_code
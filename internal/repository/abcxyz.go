@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetPartClasses classifies the parts delivered between from and to (both
+// optional, YYYY-MM-DD) with fn_abc_xyz, most valuable first.
+func (r *Repository) GetPartClasses(ctx context.Context, from, to string, t domain.ABCXYZThresholds) ([]domain.PartClass, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT part_code, name, unit, deliveries, qty, value, share, cumulative_share,
+			months, mean_qty, cv, abc, xyz, rate_missing
+		FROM fn_abc_xyz(NULLIF($1, '')::date, NULLIF($2, '')::date, $3, $4, $5, $6)
+	`, from, to, t.A, t.B, t.X, t.Y)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var classes []domain.PartClass
+	for rows.Next() {
+		var pc domain.PartClass
+		err := rows.Scan(
+			&pc.PartCode,
+			&pc.Name,
+			&pc.Unit,
+			&pc.Deliveries,
+			&pc.Qty,
+			&pc.Value,
+			&pc.Share,
+			&pc.CumulativeShare,
+			&pc.Months,
+			&pc.MeanQty,
+			&pc.CV,
+			&pc.ABC,
+			&pc.XYZ,
+			&pc.RateMissing,
+		)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, pc)
+	}
+	return classes, rows.Err()
+}
+
+// GetPartDeliveries returns the full_deliveries_view rows of a part received
+// between from and to (both optional, YYYY-MM-DD).
+func (r *Repository) GetPartDeliveries(ctx context.Context, partCode, from, to string) ([]domain.View, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT *
+		FROM full_deliveries_view
+		WHERE part_code = $1
+			AND (NULLIF($2, '')::date IS NULL OR received_date >= NULLIF($2, '')::date)
+			AND (NULLIF($3, '')::date IS NULL OR received_date <= NULLIF($3, '')::date)
+		ORDER BY received_date, warehouse_no, receipt_doc_no
+	`, partCode, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanView(rows)
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+const amendmentColumns = `id, contract_no, part_code, status, new_start_date, new_end_date, new_plan_qty, new_contract_price,
+	reason, requested_by, decided_by, decision_comment, created_at, submitted_at, decided_at`
+
+func scanAmendment(row pgx.Row) (domain.ContractAmendment, error) {
+	var a domain.ContractAmendment
+	err := row.Scan(
+		&a.ID,
+		&a.ContractNo,
+		&a.PartCode,
+		&a.Status,
+		&a.StartDate,
+		&a.EndDate,
+		&a.PlanQty,
+		&a.ContractPrice,
+		&a.Reason,
+		&a.RequestedBy,
+		&a.DecidedBy,
+		&a.DecisionComment,
+		&a.CreatedAt,
+		&a.SubmittedAt,
+		&a.DecidedAt,
+	)
+	return a, err
+}
+
+func (r *Repository) GetAmendments(ctx context.Context, contractNo int, partCode string) ([]domain.ContractAmendment, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+amendmentColumns+`
+		FROM contract_amendments
+		WHERE contract_no = $1 AND part_code = $2
+		ORDER BY id
+	`, contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var amendments []domain.ContractAmendment
+	for rows.Next() {
+		a, err := scanAmendment(rows)
+		if err != nil {
+			return nil, err
+		}
+		amendments = append(amendments, a)
+	}
+	return amendments, nil
+}
+
+// CreateAmendment records a draft amendment for a live contract line and returns its id.
+// An amendment that changes nothing or leaves the line without a valid date range
+// fails with ErrInvalidState.
+func (r *Repository) CreateAmendment(ctx context.Context, contractNo int, partCode string, startDate, endDate *time.Time, planQty, contractPrice *float64, reason, requestedBy string) (int, error) {
+	if startDate == nil && endDate == nil && planQty == nil && contractPrice == nil {
+		return 0, fmt.Errorf("amendment changes nothing: %w", ErrInvalidState)
+	}
+
+	var current domain.Contract
+	err := r.db.QueryRow(ctx, `
+		SELECT start_date, end_date FROM contracts
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+	`, contractNo, partCode).Scan(&current.StartDate, &current.EndDate)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, _, err := amendedDates(current, startDate, endDate); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO contract_amendments
+			(contract_no, part_code, new_start_date, new_end_date, new_plan_qty, new_contract_price, reason, requested_by)
+		SELECT contract_no, part_code, $3, $4, $5, $6, $7, $8
+		FROM contracts
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		RETURNING id
+	`, contractNo, partCode, startDate, endDate, planQty, contractPrice, reason, requestedBy).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	return id, translateError(err)
+}
+
+// amendedDates returns the start and end date of contract c after an amendment to
+// startDate and endDate, nil meaning unchanged. It fails with ErrInvalidState
+// when the start would not come before the end.
+func amendedDates(c domain.Contract, startDate, endDate *time.Time) (time.Time, time.Time, error) {
+	start, end := c.StartDate, c.EndDate
+	if startDate != nil {
+		start = *startDate
+	}
+	if endDate != nil {
+		end = *endDate
+	}
+	if !start.Before(end) {
+		return start, end, fmt.Errorf("start date %s must be before end date %s: %w",
+			start.Format("2006-01-02"), end.Format("2006-01-02"), ErrInvalidState)
+	}
+	return start, end, nil
+}
+
+func (r *Repository) SubmitAmendment(ctx context.Context, id int) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE contract_amendments
+		SET status = 'submitted', submitted_at = now()
+		WHERE id = $1 AND status = 'draft'
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return amendmentStateError(ctx, r.db, id, domain.AmendmentDraft)
+	}
+	return nil
+}
+
+func (r *Repository) RejectAmendment(ctx context.Context, id int, decidedBy, comment string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE contract_amendments
+		SET status = 'rejected', decided_by = $2, decision_comment = NULLIF($3, ''), decided_at = now()
+		WHERE id = $1 AND status = 'submitted'
+	`, id, decidedBy, comment)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return amendmentStateError(ctx, r.db, id, domain.AmendmentSubmitted)
+	}
+	return nil
+}
+
+// ApproveAmendment marks a submitted amendment approved and applies it to contracts
+// in the same transaction. The requester cannot approve their own amendment, and new
+// dates must keep the live deliveries of the line inside the contract period. Like
+// CreateContract it returns the budgets the changed commitment pushes over their
+// limit and fails with ErrBudgetExceeded on a blocking one.
+func (r *Repository) ApproveAmendment(ctx context.Context, id int, decidedBy, comment string) ([]domain.Budget, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := scanAmendment(tx.QueryRow(ctx, `SELECT `+amendmentColumns+` FROM contract_amendments WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("amendment %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != domain.AmendmentSubmitted {
+		return nil, fmt.Errorf("amendment %d is %s, expected %s: %w", id, a.Status, domain.AmendmentSubmitted, ErrInvalidState)
+	}
+	if a.RequestedBy == decidedBy {
+		return nil, fmt.Errorf("amendment %d cannot be approved by its requester: %w", id, ErrInvalidState)
+	}
+
+	before, err := lockBudgets(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The row lock keeps deliveries from being added to the line until the new
+	// dates are in place.
+	var current domain.Contract
+	err = tx.QueryRow(ctx, `
+		SELECT start_date, end_date FROM contracts
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, a.ContractNo, a.PartCode).Scan(&current.StartDate, &current.EndDate)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("contract %d/%s: %w", a.ContractNo, a.PartCode, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := amendedDates(current, a.StartDate, a.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	var outside int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM deliveries
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		  AND (received_date < $3 OR received_date > $4)
+	`, a.ContractNo, a.PartCode, start, end).Scan(&outside)
+	if err != nil {
+		return nil, err
+	}
+	if outside > 0 {
+		return nil, fmt.Errorf("%d deliveries of contract %d/%s fall outside %s..%s: %w", outside,
+			a.ContractNo, a.PartCode, start.Format("2006-01-02"), end.Format("2006-01-02"), ErrInvalidState)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE contracts
+		SET start_date = COALESCE($3, start_date),
+			end_date = COALESCE($4, end_date),
+			plan_qty = COALESCE($5, plan_qty),
+			contract_price = COALESCE($6, contract_price)
+		WHERE contract_no = $1 AND part_code = $2
+	`, a.ContractNo, a.PartCode, a.StartDate, a.EndDate, a.PlanQty, a.ContractPrice)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	exceeded, err := checkBudgets(ctx, tx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE contract_amendments
+		SET status = 'approved', decided_by = $2, decision_comment = NULLIF($3, ''), decided_at = now()
+		WHERE id = $1
+	`, id, decidedBy, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return exceeded, tx.Commit(ctx)
+}
+
+// amendmentStateError explains why a status transition matched no row.
+func amendmentStateError(ctx context.Context, q querier, id int, expected string) error {
+	var status string
+	err := q.QueryRow(ctx, "SELECT status FROM contract_amendments WHERE id = $1", id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("amendment %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("amendment %d is %s, expected %s: %w", id, status, expected, ErrInvalidState)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetAssignment returns the assignment of a contract line with its history, newest first.
+func (r *Repository) GetAssignment(ctx context.Context, contractNo int, partCode string) (*domain.ContractAssignment, error) {
+	var a domain.ContractAssignment
+	err := r.db.QueryRow(ctx, `
+		SELECT contract_no, part_code, buyer, status, note, assigned_at, updated_at
+		FROM contract_assignments
+		WHERE contract_no = $1 AND part_code = $2
+	`, contractNo, partCode).Scan(&a.ContractNo, &a.PartCode, &a.Buyer, &a.Status, &a.Note, &a.AssignedAt, &a.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("assignment of contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.Events, err = r.GetAssignmentEvents(ctx, contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAssignmentEvents returns the assignment history of a contract line, newest
+// first. It outlives the assignment: removing the buyer is an event of its own.
+func (r *Repository) GetAssignmentEvents(ctx context.Context, contractNo int, partCode string) ([]domain.AssignmentEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT event_id, buyer, status, note, changed_at
+		FROM contract_assignment_events
+		WHERE contract_no = $1 AND part_code = $2
+		ORDER BY changed_at DESC, event_id DESC
+	`, contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.AssignmentEvent
+	for rows.Next() {
+		var e domain.AssignmentEvent
+		if err := rows.Scan(&e.EventID, &e.Buyer, &e.Status, &e.Note, &e.ChangedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// SetAssignment assigns a live contract line to a buyer or updates its status and
+// note; every change is recorded in contract_assignment_events. A line that does
+// not exist or is in the trash gives ErrNotFound.
+func (r *Repository) SetAssignment(ctx context.Context, a domain.ContractAssignment) error {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO contract_assignments (contract_no, part_code, buyer, status, note)
+		SELECT contract_no, part_code, $3, $4, $5
+		FROM contracts
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		ON CONFLICT (contract_no, part_code)
+		DO UPDATE SET buyer = EXCLUDED.buyer, status = EXCLUDED.status, note = EXCLUDED.note, updated_at = now()
+	`, a.ContractNo, a.PartCode, a.Buyer, a.Status, a.Note)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("contract %d/%s: %w", a.ContractNo, a.PartCode, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteAssignment removes the buyer of a contract line; trg_log_assignment keeps
+// the removal in the history.
+func (r *Repository) DeleteAssignment(ctx context.Context, contractNo int, partCode string) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM contract_assignments WHERE contract_no = $1 AND part_code = $2", contractNo, partCode)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("assignment of contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	return nil
+}
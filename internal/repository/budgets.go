@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+const budgetColumns = `budget_id, period_month, category, limit_amount, enforcement,
+	committed, spent, remaining, rate_missing`
+
+func (r *Repository) GetBudgets(ctx context.Context) ([]domain.Budget, error) {
+	return getBudgets(ctx, r.db)
+}
+
+func getBudgets(ctx context.Context, q querier) ([]domain.Budget, error) {
+	rows, err := q.Query(ctx, `
+		SELECT `+budgetColumns+`
+		FROM budget_usage
+		ORDER BY period_month, category NULLS FIRST
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []domain.Budget
+	for rows.Next() {
+		var b domain.Budget
+		err := rows.Scan(
+			&b.BudgetID,
+			&b.PeriodMonth,
+			&b.Category,
+			&b.LimitAmount,
+			&b.Enforcement,
+			&b.Committed,
+			&b.Spent,
+			&b.Remaining,
+			&b.RateMissing,
+		)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// SetBudget creates or replaces the budget of a month and category.
+func (r *Repository) SetBudget(ctx context.Context, b domain.Budget) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO budgets (period_month, category, limit_amount, enforcement)
+		VALUES (date_trunc('month', $1::date)::date, $2, $3, $4)
+		ON CONFLICT (period_month, category)
+		DO UPDATE SET limit_amount = EXCLUDED.limit_amount, enforcement = EXCLUDED.enforcement
+	`, b.PeriodMonth, b.Category, b.LimitAmount, b.Enforcement)
+	return translateError(err)
+}
+
+func (r *Repository) DeleteBudget(ctx context.Context, budgetID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM budgets WHERE budget_id = $1", budgetID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("budget %d: %w", budgetID, ErrNotFound)
+	}
+	return nil
+}
+
+// commitmentGroups maps the report groupings to their commitment_by_month expression.
+var commitmentGroups = map[string]string{
+	"contract": "contract_no || '/' || part_code",
+	"supplier": "supplier_name",
+	"part":     "part_code",
+	"category": "COALESCE(category, '-')",
+	"month":    "to_char(month, 'YYYY-MM')",
+}
+
+// IsCommitmentGroup reports whether GetCommitments accepts groupBy.
+func IsCommitmentGroup(groupBy string) bool {
+	_, ok := commitmentGroups[groupBy]
+	return ok
+}
+
+// GetCommitments totals committed, spent and remaining money in the base currency
+// per groupBy, over the months between from and to (both optional, YYYY-MM-DD).
+func (r *Repository) GetCommitments(ctx context.Context, groupBy, from, to string) ([]domain.Commitment, error) {
+	expr, ok := commitmentGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown grouping %q", groupBy)
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT `+expr+` AS grp,
+			SUM(COALESCE(committed_base, 0)),
+			SUM(COALESCE(spent_base, 0)),
+			SUM(COALESCE(committed_base, 0)) - SUM(COALESCE(spent_base, 0)),
+			bool_or(rate_missing)
+		FROM commitment_by_month
+		WHERE (NULLIF($1, '')::date IS NULL OR month >= date_trunc('month', NULLIF($1, '')::date))
+			AND (NULLIF($2, '')::date IS NULL OR month <= NULLIF($2, '')::date)
+		GROUP BY grp
+		ORDER BY grp
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commitments []domain.Commitment
+	for rows.Next() {
+		var c domain.Commitment
+		if err := rows.Scan(&c.Group, &c.Committed, &c.Spent, &c.Remaining, &c.RateMissing); err != nil {
+			return nil, err
+		}
+		commitments = append(commitments, c)
+	}
+	return commitments, nil
+}
+
+// budgetLock is the advisory lock key serializing the transactions that change
+// commitments, so that each one checks budgets against the usage committed by
+// the one before it.
+const budgetLock = 4401
+
+// lockBudgets takes the budget lock for the rest of the transaction q and returns
+// the budget usage before the change, to be passed to checkBudgets. Callers take
+// it before locking contract rows so the lock order is the same everywhere.
+func lockBudgets(ctx context.Context, q querier) ([]domain.Budget, error) {
+	if _, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", budgetLock); err != nil {
+		return nil, err
+	}
+	return getBudgets(ctx, q)
+}
+
+// checkBudgets compares budget usage after a contract change made in q with the
+// usage before it. Budgets pushed further over their limit, and budgets whose
+// commitments can no longer be valued because an exchange rate is missing, are
+// returned as warnings; when one of them blocks, ErrBudgetExceeded is returned
+// instead.
+func checkBudgets(ctx context.Context, q querier, before []domain.Budget) ([]domain.Budget, error) {
+	after, err := getBudgets(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[int]domain.Budget, len(before))
+	for _, b := range before {
+		previous[b.BudgetID] = b
+	}
+
+	var exceeded []domain.Budget
+	var blocked []string
+	for _, b := range after {
+		prev := previous[b.BudgetID]
+		increased := b.Committed > prev.Committed && !sameAmount(b.Committed, prev.Committed)
+		rateLost := b.RateMissing && !prev.RateMissing
+		if (b.Remaining >= 0 || !increased) && !rateLost {
+			continue
+		}
+		exceeded = append(exceeded, b)
+		if b.Enforcement == domain.BudgetBlock {
+			category := "all categories"
+			if b.Category != nil {
+				category = *b.Category
+			}
+			if rateLost {
+				blocked = append(blocked, fmt.Sprintf("%s %s: exchange rate missing, commitment cannot be checked",
+					b.PeriodMonth.Format("2006-01"), category))
+			} else {
+				blocked = append(blocked, fmt.Sprintf("%s %s: committed %.2f of %.2f",
+					b.PeriodMonth.Format("2006-01"), category, b.Committed, b.LimitAmount))
+			}
+		}
+	}
+	if len(blocked) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBudgetExceeded, strings.Join(blocked, "; "))
+	}
+	return exceeded, nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// acceptedDeliveries lists the live deliveries received between $1 and $2 with
+// their accepted quantity and its value in the base currency.
+const acceptedDeliveries = `
+	WITH accepted AS (
+		SELECT d.warehouse_no, d.receipt_doc_no, d.contract_no, d.part_code, d.unit, d.received_date,
+			d.qty - COALESCE(i.rejected_qty, 0) AS qty,
+			(d.qty - COALESCE(i.rejected_qty, 0)) * v.contract_price * x.rate AS value
+		FROM deliveries d
+		JOIN contracts c
+			ON c.contract_no = d.contract_no AND c.part_code = d.part_code
+		LEFT JOIN delivery_inspections i
+			ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+		CROSS JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) v
+		CROSS JOIN LATERAL (SELECT fn_exchange_rate(c.currency, d.received_date) AS rate) x
+		WHERE d.deleted_at IS NULL
+			AND d.received_date BETWEEN $1::date AND $2::date
+	)`
+
+// GetDashboard computes the dashboard aggregates as of on over the given number
+// of weeks ending with the week of on.
+func (r *Repository) GetDashboard(ctx context.Context, on time.Time, weeks int) (*domain.Dashboard, error) {
+	monday := on.AddDate(0, 0, -(int(on.Weekday())+6)%7)
+	from := monday.AddDate(0, 0, -7*(weeks-1))
+	monthStart := time.Date(on.Year(), on.Month(), 1, 0, 0, 0, 0, on.Location())
+
+	dash := domain.Dashboard{
+		On:   on.Format("2006-01-02"),
+		From: from.Format("2006-01-02"),
+	}
+
+	err := r.db.QueryRow(ctx, acceptedDeliveries+`
+		SELECT COUNT(*), COALESCE(round(SUM(value), 2), 0), COALESCE(bool_or(value IS NULL), false)
+		FROM accepted
+	`, monthStart, on).Scan(&dash.MonthDeliveries, &dash.MonthValue, &dash.MonthRateMissing)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM contracts c
+		WHERE c.deleted_at IS NULL
+			AND c.plan_qty > COALESCE((
+				SELECT SUM(d.qty - COALESCE(i.rejected_qty, 0))
+				FROM deliveries d
+				LEFT JOIN delivery_inspections i
+					ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+				WHERE d.contract_no = c.contract_no AND d.part_code = c.part_code
+					AND d.deleted_at IS NULL AND d.received_date <= $1::date
+			), 0)
+	`, on).Scan(&dash.OpenLines)
+	if err != nil {
+		return nil, err
+	}
+
+	if dash.AtRisk, err = r.getAtRiskContracts(ctx, on); err != nil {
+		return nil, err
+	}
+	if dash.TopParts, err = r.getTopParts(ctx, from, on, 5); err != nil {
+		return nil, err
+	}
+	if dash.Warehouses, err = r.getWarehouseVolumes(ctx, from, on); err != nil {
+		return nil, err
+	}
+	if dash.WeeklyReceipts, err = r.getWeeklyReceipts(ctx, from, on); err != nil {
+		return nil, err
+	}
+	return &dash, nil
+}
+
+// getAtRiskContracts returns the live lines not fully delivered by on whose
+// earliest uncovered tranche, or end date without a schedule, is past or falls
+// within the next 30 days.
+func (r *Repository) getAtRiskContracts(ctx context.Context, on time.Time) ([]domain.AtRiskContract, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH accepted AS (
+			SELECT d.contract_no, d.part_code,
+				SUM(d.qty - COALESCE(i.rejected_qty, 0)) AS qty,
+				MAX(d.received_date) AS last_received
+			FROM deliveries d
+			LEFT JOIN delivery_inspections i
+				ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+			WHERE d.deleted_at IS NULL AND d.received_date <= $1::date
+			GROUP BY d.contract_no, d.part_code
+		), due AS (
+			SELECT t.contract_no, t.part_code, MIN(t.due_date) AS due_date
+			FROM fn_tranche_status($1::date) t
+			WHERE t.status IN ('short', 'open')
+			GROUP BY t.contract_no, t.part_code
+		)
+		SELECT c.contract_no, c.part_code, s.name, c.unit, c.plan_qty, COALESCE(a.qty, 0),
+			COALESCE(du.due_date, c.end_date),
+			CASE WHEN COALESCE(du.due_date, c.end_date) < $1::date THEN 'overdue' ELSE 'due_soon' END,
+			a.last_received
+		FROM contracts c
+		JOIN contract_headers h ON h.contract_no = c.contract_no
+		JOIN suppliers s ON s.supplier_id = h.supplier_id
+		LEFT JOIN accepted a ON a.contract_no = c.contract_no AND a.part_code = c.part_code
+		LEFT JOIN due du ON du.contract_no = c.contract_no AND du.part_code = c.part_code
+		WHERE c.deleted_at IS NULL
+			AND COALESCE(a.qty, 0) < c.plan_qty
+			AND COALESCE(du.due_date, c.end_date) <= $1::date + 30
+		ORDER BY 7, c.contract_no, c.part_code
+	`, on)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []domain.AtRiskContract
+	for rows.Next() {
+		var l domain.AtRiskContract
+		err := rows.Scan(
+			&l.ContractNo,
+			&l.PartCode,
+			&l.SupplierName,
+			&l.Unit,
+			&l.PlanQty,
+			&l.AcceptedQty,
+			&l.DueDate,
+			&l.Reason,
+			&l.LastReceived,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// getTopParts returns the limit parts with the highest value received between from and to.
+func (r *Repository) getTopParts(ctx context.Context, from, to time.Time, limit int) ([]domain.PartVolume, error) {
+	rows, err := r.db.Query(ctx, acceptedDeliveries+`
+		SELECT a.part_code, p.name, a.unit, COUNT(*), SUM(a.qty), COALESCE(round(SUM(a.value), 2), 0)
+		FROM accepted a
+		JOIN parts p ON p.part_code = a.part_code
+		GROUP BY a.part_code, p.name, a.unit
+		ORDER BY 6 DESC, a.part_code
+		LIMIT $3
+	`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []domain.PartVolume
+	for rows.Next() {
+		var p domain.PartVolume
+		if err := rows.Scan(&p.PartCode, &p.Name, &p.Unit, &p.Deliveries, &p.Qty, &p.Value); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// getWarehouseVolumes returns the deliveries received by each live warehouse between from and to.
+func (r *Repository) getWarehouseVolumes(ctx context.Context, from, to time.Time) ([]domain.WarehouseVolume, error) {
+	rows, err := r.db.Query(ctx, acceptedDeliveries+`
+		SELECT w.warehouse_no, w.manager_surname, COUNT(a.receipt_doc_no), COALESCE(round(SUM(a.value), 2), 0)
+		FROM warehouses w
+		LEFT JOIN accepted a ON a.warehouse_no = w.warehouse_no
+		WHERE w.deleted_at IS NULL
+		GROUP BY w.warehouse_no, w.manager_surname
+		ORDER BY w.warehouse_no
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var volumes []domain.WarehouseVolume
+	for rows.Next() {
+		var v domain.WarehouseVolume
+		if err := rows.Scan(&v.WarehouseNo, &v.ManagerSurname, &v.Deliveries, &v.Value); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+// getWeeklyReceipts returns the accepted quantity received per week and unit
+// between from and to, with zero rows for weeks without receipts.
+func (r *Repository) getWeeklyReceipts(ctx context.Context, from, to time.Time) ([]domain.WeeklyReceipt, error) {
+	rows, err := r.db.Query(ctx, acceptedDeliveries+`,
+	weeks AS (
+		SELECT generate_series(date_trunc('week', $1::date), date_trunc('week', $2::date), interval '1 week')::date AS week
+	), units AS (
+		SELECT DISTINCT unit FROM accepted
+	)
+		SELECT w.week, u.unit, COALESCE(SUM(a.qty), 0)
+		FROM weeks w
+		CROSS JOIN units u
+		LEFT JOIN accepted a
+			ON a.unit = u.unit AND date_trunc('week', a.received_date)::date = w.week
+		GROUP BY w.week, u.unit
+		ORDER BY u.unit, w.week
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []domain.WeeklyReceipt
+	for rows.Next() {
+		var wr domain.WeeklyReceipt
+		if err := rows.Scan(&wr.Week, &wr.Unit, &wr.Qty); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, wr)
+	}
+	return receipts, nil
+}
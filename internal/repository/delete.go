@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) PreviewWarehouseDelete(ctx context.Context, warehouseNo int) (*domain.DeletePreview, error) {
+	return previewWarehouseDelete(ctx, r.db, warehouseNo, false)
+}
+
+func (r *Repository) PreviewContractDelete(ctx context.Context, contractNo int, partCode string) (*domain.DeletePreview, error) {
+	return previewContractDelete(ctx, r.db, contractNo, partCode, false)
+}
+
+// previewWarehouseDelete lists the deliveries removed together with the warehouse.
+// With lock set the warehouse and its deliveries are locked until the end of the transaction.
+func previewWarehouseDelete(ctx context.Context, q querier, warehouseNo int, lock bool) (*domain.DeletePreview, error) {
+	forUpdate := ""
+	if lock {
+		forUpdate = " FOR UPDATE"
+	}
+
+	var exists int
+	err := q.QueryRow(ctx, "SELECT warehouse_no FROM warehouses WHERE warehouse_no = $1 AND deleted_at IS NULL"+forUpdate, warehouseNo).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("warehouse %d: %w", warehouseNo, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := queryDeliveries(ctx, q, `
+		SELECT warehouse_no, receipt_doc_no, contract_no, part_code, unit, qty, received_date
+		FROM deliveries
+		WHERE warehouse_no = $1 AND deleted_at IS NULL
+		ORDER BY receipt_doc_no`+forUpdate, warehouseNo)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeletePreview("warehouse", fmt.Sprintf("%d", warehouseNo), "cascade", deliveries), nil
+}
+
+// previewContractDelete lists the deliveries that block deleting the contract line.
+func previewContractDelete(ctx context.Context, q querier, contractNo int, partCode string, lock bool) (*domain.DeletePreview, error) {
+	forUpdate := ""
+	if lock {
+		forUpdate = " FOR UPDATE"
+	}
+
+	var exists int
+	err := q.QueryRow(ctx, "SELECT contract_no FROM contracts WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL"+forUpdate, contractNo, partCode).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := queryDeliveries(ctx, q, `
+		SELECT warehouse_no, receipt_doc_no, contract_no, part_code, unit, qty, received_date
+		FROM deliveries
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		ORDER BY warehouse_no, receipt_doc_no`+forUpdate, contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeletePreview("contract", fmt.Sprintf("%d/%s", contractNo, partCode), "block", deliveries), nil
+}
+
+// newDeletePreview builds a preview whose token is a fingerprint of the affected rows,
+// so a token stops matching as soon as any of them changes.
+func newDeletePreview(entity, key, action string, deliveries []domain.Delivery) *domain.DeletePreview {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%s\n", entity, key)
+	for _, d := range deliveries {
+		fmt.Fprintf(h, "%d/%d %d/%s %s %v %s\n", d.WarehouseNo, d.ReceiptDocNo, d.ContractNo, d.PartCode, d.Unit, d.Qty, d.ReceivedDate.Format("2006-01-02"))
+	}
+
+	return &domain.DeletePreview{
+		Entity:     entity,
+		Key:        key,
+		Action:     action,
+		Deliveries: deliveries,
+		Token:      hex.EncodeToString(h.Sum(nil)[:16]),
+	}
+}
+
+// reassignDeliveries moves every delivery of warehouse from to warehouse to.
+// Receipt numbers continue after the highest one in the target warehouse so they
+// cannot collide; every renumbering is logged in key_changes.
+func reassignDeliveries(ctx context.Context, q querier, from, to int) error {
+	if from == to {
+		return fmt.Errorf("cannot reassign deliveries of warehouse %d to itself", from)
+	}
+
+	var exists int
+	err := q.QueryRow(ctx, "SELECT warehouse_no FROM warehouses WHERE warehouse_no = $1 AND deleted_at IS NULL FOR UPDATE", to).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("target warehouse %d: %w", to, ErrReferenceMissing)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, `
+		WITH moved AS (
+			SELECT warehouse_no, receipt_doc_no,
+				(SELECT COALESCE(MAX(receipt_doc_no), 0) FROM deliveries WHERE warehouse_no = $2)
+					+ ROW_NUMBER() OVER (ORDER BY receipt_doc_no) AS new_receipt_doc_no
+			FROM deliveries
+			WHERE warehouse_no = $1 AND deleted_at IS NULL
+		), updated AS (
+			UPDATE deliveries d
+			SET warehouse_no = $2, receipt_doc_no = m.new_receipt_doc_no
+			FROM moved m
+			WHERE d.warehouse_no = m.warehouse_no AND d.receipt_doc_no = m.receipt_doc_no
+			RETURNING m.warehouse_no AS old_warehouse_no, m.receipt_doc_no AS old_receipt_doc_no,
+				d.warehouse_no, d.receipt_doc_no
+		)
+		INSERT INTO key_changes (entity, old_key, new_key)
+		SELECT 'delivery', old_warehouse_no || '/' || old_receipt_doc_no, warehouse_no || '/' || receipt_doc_no
+		FROM updated
+	`, from, to)
+	return translateError(err)
+}
+
+// reassignIssues moves the issue documents of warehouse from to warehouse to,
+// numbering them the same way reassignDeliveries does.
+func reassignIssues(ctx context.Context, q querier, from, to int) error {
+	_, err := q.Exec(ctx, `
+		WITH moved AS (
+			SELECT warehouse_no, issue_doc_no,
+				(SELECT COALESCE(MAX(issue_doc_no), 0) FROM issues WHERE warehouse_no = $2)
+					+ ROW_NUMBER() OVER (ORDER BY issue_doc_no) AS new_issue_doc_no
+			FROM issues
+			WHERE warehouse_no = $1
+		), updated AS (
+			UPDATE issues i
+			SET warehouse_no = $2, issue_doc_no = m.new_issue_doc_no
+			FROM moved m
+			WHERE i.warehouse_no = m.warehouse_no AND i.issue_doc_no = m.issue_doc_no
+			RETURNING m.warehouse_no AS old_warehouse_no, m.issue_doc_no AS old_issue_doc_no,
+				i.warehouse_no, i.issue_doc_no
+		)
+		INSERT INTO key_changes (entity, old_key, new_key)
+		SELECT 'issue', old_warehouse_no || '/' || old_issue_doc_no, warehouse_no || '/' || issue_doc_no
+		FROM updated
+	`, from, to)
+	return translateError(err)
+}
+
+// reassignTransfers moves the transfers between warehouse from and other
+// warehouses to warehouse to. Transfers between from and to themselves stay on
+// the trashed warehouse: merged they would leave and enter the same warehouse.
+func reassignTransfers(ctx context.Context, q querier, from, to int) error {
+	if _, err := q.Exec(ctx, "UPDATE transfers SET from_warehouse_no = $2 WHERE from_warehouse_no = $1 AND to_warehouse_no <> $2", from, to); err != nil {
+		return translateError(err)
+	}
+	_, err := q.Exec(ctx, "UPDATE transfers SET to_warehouse_no = $2 WHERE to_warehouse_no = $1 AND from_warehouse_no <> $2", from, to)
+	return translateError(err)
+}
+
+func queryDeliveries(ctx context.Context, q querier, sql string, args ...any) ([]domain.Delivery, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []domain.Delivery
+	for rows.Next() {
+		var d domain.Delivery
+		if err := rows.Scan(&d.WarehouseNo, &d.ReceiptDocNo, &d.ContractNo, &d.PartCode, &d.Unit, &d.Qty, &d.ReceivedDate); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound is returned when the row addressed by a key does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrKeyConflict is returned when the target key is already taken.
+	ErrKeyConflict = errors.New("key already exists")
+	// ErrReferenceMissing is returned when a referenced row does not exist.
+	ErrReferenceMissing = errors.New("referenced row does not exist")
+	// ErrInUse is returned when a row cannot be deleted because other rows reference it.
+	ErrInUse = errors.New("row is referenced by other rows")
+	// ErrStaleConfirmation is returned when a confirmation token no longer matches the data.
+	ErrStaleConfirmation = errors.New("confirmation token is missing or stale, request a new preview")
+	// ErrAmendmentRequired is returned when commercial terms are changed outside the amendment workflow.
+	ErrAmendmentRequired = errors.New("commercial terms can only be changed through an approved amendment")
+	// ErrInvalidState is returned when a document is not in a state that allows the operation.
+	ErrInvalidState = errors.New("operation is not allowed in the current state")
+	// ErrInsufficientStock is returned when a movement would drive a stock balance negative.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrPeriodClosed is returned when a movement falls into a closed accounting period.
+	ErrPeriodClosed = errors.New("accounting period is closed")
+	// ErrBudgetExceeded is returned when a contract change pushes a blocking budget over its limit
+	// or adds a commitment to it that cannot be valued for a missing exchange rate.
+	ErrBudgetExceeded = errors.New("budget limit exceeded")
+)
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	// raised by fn_check_issue_stock, fn_check_transfer_stock, fn_check_lot_stock
+	// and fn_ledger_post_delivery
+	pgInsufficientStock = "KP001"
+	// raised by fn_check_closed_period and fn_ledger_check_period
+	pgPeriodClosed = "KP002"
+	// raised by fn_check_inspected_delivery, fn_check_supplier_return and fn_check_lot_stock
+	pgInvalidState = "KP003"
+)
+
+// translateError maps constraint violations to the package sentinel errors.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return fmt.Errorf("%w: %s", ErrKeyConflict, pgErr.Detail)
+	case pgForeignKeyViolation:
+		return fmt.Errorf("%w: %s", ErrReferenceMissing, pgErr.Detail)
+	case pgCheckViolation:
+		return fmt.Errorf("%w: %s", ErrInvalidState, pgErr.Message)
+	case pgInsufficientStock:
+		return fmt.Errorf("%w: %s", ErrInsufficientStock, pgErr.Message)
+	case pgPeriodClosed:
+		return fmt.Errorf("%w: %s", ErrPeriodClosed, pgErr.Message)
+	case pgInvalidState:
+		return fmt.Errorf("%w: %s", ErrInvalidState, pgErr.Message)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetExchangeRates(ctx context.Context) ([]domain.ExchangeRate, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT currency, rate_date, rate
+		FROM exchange_rates
+		ORDER BY currency, rate_date DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []domain.ExchangeRate
+	for rows.Next() {
+		var x domain.ExchangeRate
+		if err := rows.Scan(&x.Currency, &x.RateDate, &x.Rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, x)
+	}
+	return rates, nil
+}
+
+// ImportExchangeRates loads rates in one transaction, replacing the rate of a
+// currency already loaded for the same date.
+func (r *Repository) ImportExchangeRates(ctx context.Context, rates []domain.ExchangeRate) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, x := range rates {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO exchange_rates (currency, rate_date, rate)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate
+		`, x.Currency, x.RateDate, x.Rate)
+		if err != nil {
+			return fmt.Errorf("rate %s on %s: %w", x.Currency, x.RateDate.Format("2006-01-02"), translateError(err))
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) DeleteExchangeRate(ctx context.Context, currency string, rateDate time.Time) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM exchange_rates WHERE currency = $1 AND rate_date = $2", currency, rateDate)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rate %s on %s: %w", currency, rateDate.Format("2006-01-02"), ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// minForecastWeeks is the number of weeks a running line must be observed
+// before forecastLine judges it; until then its status is unknown.
+const minForecastWeeks = 4
+
+// receipt is an accepted quantity received on a date.
+type receipt struct {
+	date time.Time
+	qty  float64
+}
+
+// GetForecasts projects the fulfilment of every live contract line from the
+// deliveries accepted up to on, ordered by contract and part.
+func (r *Repository) GetForecasts(ctx context.Context, on time.Time) ([]domain.Forecast, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.contract_no, c.part_code, s.name, c.unit, c.start_date, c.end_date, c.plan_qty,
+			d.received_date, d.qty - COALESCE(i.rejected_qty, 0)
+		FROM contracts c
+		JOIN contract_headers h ON h.contract_no = c.contract_no
+		JOIN suppliers s ON s.supplier_id = h.supplier_id
+		LEFT JOIN deliveries d
+			ON d.contract_no = c.contract_no AND d.part_code = c.part_code
+			AND d.deleted_at IS NULL AND d.received_date <= $1::date
+		LEFT JOIN delivery_inspections i
+			ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+		WHERE c.deleted_at IS NULL
+		ORDER BY c.contract_no, c.part_code, d.received_date
+	`, on)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var forecasts []domain.Forecast
+	var receipts []receipt
+	for rows.Next() {
+		var f domain.Forecast
+		var receivedDate *time.Time
+		var qty *float64
+		err := rows.Scan(
+			&f.ContractNo,
+			&f.PartCode,
+			&f.SupplierName,
+			&f.Unit,
+			&f.StartDate,
+			&f.EndDate,
+			&f.PlanQty,
+			&receivedDate,
+			&qty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(forecasts); n == 0 || forecasts[n-1].ContractNo != f.ContractNo || forecasts[n-1].PartCode != f.PartCode {
+			if n > 0 {
+				forecastLine(&forecasts[n-1], receipts, on)
+			}
+			forecasts = append(forecasts, f)
+			receipts = receipts[:0]
+		}
+		if receivedDate != nil {
+			receipts = append(receipts, receipt{*receivedDate, *qty})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if n := len(forecasts); n > 0 {
+		forecastLine(&forecasts[n-1], receipts, on)
+	}
+	return forecasts, nil
+}
+
+// forecastLine fills in the projection of f from its receipts up to on, which
+// lie between the start and end dates of the line. Receipts are summed into
+// weeks counted from start_date; the mean weekly quantity extrapolates the
+// remaining weeks to end_date, and the sample deviation of the weekly sums,
+// growing with the square root of the remaining weeks, gives a 95% band. A line
+// still running after fewer than minForecastWeeks weeks gets no verdict.
+func forecastLine(f *domain.Forecast, receipts []receipt, on time.Time) {
+	f.On = on
+	cutoff := on
+	if f.EndDate.Before(cutoff) {
+		cutoff = f.EndDate
+	}
+
+	for _, rc := range receipts {
+		f.AcceptedQty += rc.qty
+		if f.ExpectedDate == nil && f.AcceptedQty >= f.PlanQty-0.005 {
+			date := rc.date
+			f.ExpectedDate = &date
+		}
+	}
+	f.AcceptedQty = round2(f.AcceptedQty)
+
+	days := int(cutoff.Sub(f.StartDate).Hours()/24) + 1
+	if days <= 0 {
+		f.ProjectedQty, f.LowQty, f.HighQty = f.AcceptedQty, f.AcceptedQty, f.AcceptedQty
+		f.ShortfallQty = f.PlanQty - f.AcceptedQty
+		f.Status = domain.ForecastNotStarted
+		return
+	}
+	f.WeeksElapsed = (days + 6) / 7
+
+	weekly := make([]float64, f.WeeksElapsed)
+	for _, rc := range receipts {
+		week := int(rc.date.Sub(f.StartDate).Hours()/24) / 7
+		weekly[min(max(week, 0), f.WeeksElapsed-1)] += rc.qty
+	}
+	mean := f.AcceptedQty / float64(f.WeeksElapsed)
+	deviation := mean
+	if f.WeeksElapsed > 1 {
+		var squares float64
+		for _, q := range weekly {
+			squares += (q - mean) * (q - mean)
+		}
+		deviation = math.Sqrt(squares / float64(f.WeeksElapsed-1))
+	}
+
+	remaining := math.Max(f.EndDate.Sub(cutoff).Hours()/24/7, 0)
+	halfWidth := 1.96 * deviation * math.Sqrt(remaining)
+	f.WeeklyRate = round2(mean)
+	f.ProjectedQty = round2(f.AcceptedQty + mean*remaining)
+	f.LowQty = round2(math.Max(f.ProjectedQty-halfWidth, f.AcceptedQty))
+	f.HighQty = round2(f.ProjectedQty + halfWidth)
+	f.ShortfallQty = round2(math.Max(f.PlanQty-f.ProjectedQty, 0))
+
+	if f.ExpectedDate == nil && mean > 0 {
+		date := on.AddDate(0, 0, int(math.Ceil((f.PlanQty-f.AcceptedQty)/mean*7)))
+		f.ExpectedDate = &date
+	}
+
+	switch {
+	case f.AcceptedQty >= f.PlanQty-0.005:
+		f.Status = domain.ForecastComplete
+	case f.WeeksElapsed < minForecastWeeks && f.EndDate.After(on):
+		f.Status = domain.ForecastUnknown
+	case f.HighQty < f.PlanQty:
+		f.Status = domain.ForecastShort
+	case f.ProjectedQty < f.PlanQty:
+		f.Status = domain.ForecastAtRisk
+	default:
+		f.Status = domain.ForecastOnTrack
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestForecastLine(t *testing.T) {
+	// Weekly sums 50, 150, 50, 150: mean 100, sample deviation sqrt(10000/3).
+	unevenBand := round2(1.96 * math.Sqrt(10000.0/3) * math.Sqrt(8))
+
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		plan     float64
+		on       string
+		receipts []receipt
+		want     domain.Forecast
+	}{
+		{
+			name:  "no receipts one week in",
+			start: "2024-01-01", end: "2024-03-25", plan: 1200, on: "2024-01-08",
+			want: domain.Forecast{WeeksElapsed: 2, ShortfallQty: 1200, Status: domain.ForecastUnknown},
+		},
+		{
+			name:  "no receipts after the minimum weeks",
+			start: "2024-01-01", end: "2024-03-25", plan: 1200, on: "2024-01-28",
+			want: domain.Forecast{WeeksElapsed: 4, ShortfallQty: 1200, Status: domain.ForecastShort},
+		},
+		{
+			name:  "steady receipts",
+			start: "2024-01-01", end: "2024-03-25", plan: 1200, on: "2024-02-11",
+			receipts: []receipt{
+				{date("2024-01-01"), 100}, {date("2024-01-08"), 100}, {date("2024-01-15"), 100},
+				{date("2024-01-22"), 100}, {date("2024-01-29"), 100}, {date("2024-02-05"), 100},
+			},
+			// 43 days are left: 600 + 100 * 43/7, no spread between the weeks.
+			want: domain.Forecast{
+				AcceptedQty: 600, WeeksElapsed: 6, WeeklyRate: 100,
+				ProjectedQty: 1214.29, LowQty: 1214.29, HighQty: 1214.29,
+				Status: domain.ForecastOnTrack,
+			},
+		},
+		{
+			name:  "uneven receipts",
+			start: "2024-01-01", end: "2024-03-24", plan: 1300, on: "2024-01-28",
+			receipts: []receipt{
+				{date("2024-01-01"), 50}, {date("2024-01-08"), 150},
+				{date("2024-01-15"), 50}, {date("2024-01-22"), 150},
+			},
+			// 8 weeks are left: 400 + 100 * 8, band 1.96 * sd * sqrt(8).
+			want: domain.Forecast{
+				AcceptedQty: 400, WeeksElapsed: 4, WeeklyRate: 100,
+				ProjectedQty: 1200, LowQty: 1200 - unevenBand, HighQty: 1200 + unevenBand,
+				ShortfallQty: 100, Status: domain.ForecastAtRisk,
+			},
+		},
+		{
+			name:  "late start",
+			start: "2024-03-01", end: "2024-06-30", plan: 500, on: "2024-02-15",
+			want: domain.Forecast{ShortfallQty: 500, Status: domain.ForecastNotStarted},
+		},
+		{
+			name:  "past end date",
+			start: "2024-01-01", end: "2024-01-28", plan: 400, on: "2024-03-01",
+			receipts: []receipt{
+				{date("2024-01-01"), 100}, {date("2024-01-08"), 100}, {date("2024-01-15"), 100},
+			},
+			// Nothing is left to extrapolate: the band collapses on the accepted quantity.
+			want: domain.Forecast{
+				AcceptedQty: 300, WeeksElapsed: 4, WeeklyRate: 75,
+				ProjectedQty: 300, LowQty: 300, HighQty: 300,
+				ShortfallQty: 100, Status: domain.ForecastShort,
+			},
+		},
+		{
+			name:  "complete",
+			start: "2024-01-01", end: "2024-03-24", plan: 200, on: "2024-01-10",
+			receipts: []receipt{{date("2024-01-02"), 120}, {date("2024-01-09"), 80}},
+			// Complete lines keep their projection: 74 days are left.
+			want: domain.Forecast{
+				AcceptedQty: 200, WeeksElapsed: 2, WeeklyRate: 100,
+				ProjectedQty: 1257.14, LowQty: 1076.9, HighQty: 1437.39,
+				Status: domain.ForecastComplete,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := domain.Forecast{StartDate: date(tt.start), EndDate: date(tt.end), PlanQty: tt.plan}
+			forecastLine(&f, tt.receipts, date(tt.on))
+
+			got := []float64{f.AcceptedQty, f.WeeklyRate, f.ProjectedQty, f.LowQty, f.HighQty, f.ShortfallQty}
+			want := []float64{tt.want.AcceptedQty, tt.want.WeeklyRate, tt.want.ProjectedQty, tt.want.LowQty, tt.want.HighQty, tt.want.ShortfallQty}
+			names := []string{"accepted", "weekly rate", "projected", "low", "high", "shortfall"}
+			for i := range got {
+				if math.Abs(got[i]-want[i]) > 0.011 {
+					t.Errorf("%s = %v, want %v", names[i], got[i], want[i])
+				}
+			}
+			if f.WeeksElapsed != tt.want.WeeksElapsed {
+				t.Errorf("weeks elapsed = %d, want %d", f.WeeksElapsed, tt.want.WeeksElapsed)
+			}
+			if f.Status != tt.want.Status {
+				t.Errorf("status = %s, want %s", f.Status, tt.want.Status)
+			}
+		})
+	}
+}
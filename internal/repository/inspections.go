@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetInspections returns the inspected deliveries, newest first, with the
+// quantity already returned to the supplier.
+func (r *Repository) GetInspections(ctx context.Context) ([]domain.DeliveryInspection, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT i.warehouse_no, i.receipt_doc_no, d.contract_no, d.part_code, d.unit, d.received_date,
+			i.accepted_qty, i.rejected_qty,
+			COALESCE((SELECT SUM(sr.qty) FROM supplier_returns sr
+				WHERE sr.warehouse_no = i.warehouse_no AND sr.receipt_doc_no = i.receipt_doc_no), 0),
+			i.reason, i.inspector, i.inspected_at
+		FROM delivery_inspections i
+		JOIN deliveries d
+			ON d.warehouse_no = i.warehouse_no AND d.receipt_doc_no = i.receipt_doc_no
+		WHERE d.deleted_at IS NULL
+		ORDER BY i.inspected_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inspections []domain.DeliveryInspection
+	for rows.Next() {
+		var i domain.DeliveryInspection
+		err := rows.Scan(
+			&i.WarehouseNo,
+			&i.ReceiptDocNo,
+			&i.ContractNo,
+			&i.PartCode,
+			&i.Unit,
+			&i.ReceivedDate,
+			&i.AcceptedQty,
+			&i.RejectedQty,
+			&i.ReturnedQty,
+			&i.Reason,
+			&i.Inspector,
+			&i.InspectedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		inspections = append(inspections, i)
+	}
+	return inspections, nil
+}
+
+// RecordInspection records the quality check of a live delivery; everything not
+// rejected is accepted. A delivery is inspected once, and the rejected part is
+// taken off stock by trg_ledger_post_inspection. Lots already recorded for the
+// delivery must fit in the accepted quantity (ErrInvalidState).
+func (r *Repository) RecordInspection(ctx context.Context, warehouseNo, receiptDocNo int, rejectedQty float64, reason *string, inspector string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var qty, inLots float64
+	err = tx.QueryRow(ctx, `
+		SELECT d.qty,
+			COALESCE((SELECT SUM(l.qty) FROM delivery_lots l
+				WHERE l.warehouse_no = d.warehouse_no AND l.receipt_doc_no = d.receipt_doc_no), 0)
+		FROM deliveries d
+		WHERE d.warehouse_no = $1 AND d.receipt_doc_no = $2 AND d.deleted_at IS NULL
+		FOR UPDATE OF d
+	`, warehouseNo, receiptDocNo).Scan(&qty, &inLots)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("delivery %d/%d: %w", warehouseNo, receiptDocNo, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if rejectedQty > qty {
+		return fmt.Errorf("delivery %d/%d: rejected %g exceeds delivered %g: %w", warehouseNo, receiptDocNo, rejectedQty, qty, ErrInvalidState)
+	}
+	if inLots > qty-rejectedQty {
+		return fmt.Errorf("delivery %d/%d: lots total %g exceeds accepted %g: %w", warehouseNo, receiptDocNo, inLots, qty-rejectedQty, ErrInvalidState)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO delivery_inspections (warehouse_no, receipt_doc_no, accepted_qty, rejected_qty, reason, inspector)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, warehouseNo, receiptDocNo, qty-rejectedQty, rejectedQty, reason, inspector)
+	if err != nil {
+		return translateError(err)
+	}
+	return tx.Commit(ctx)
+}
+
+// GetReturns returns the supplier returns, newest first.
+func (r *Repository) GetReturns(ctx context.Context) ([]domain.SupplierReturn, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT sr.return_no, sr.warehouse_no, sr.receipt_doc_no, d.part_code, d.unit,
+			sr.qty, sr.returned_date, sr.reason, sr.created_at
+		FROM supplier_returns sr
+		JOIN deliveries d
+			ON d.warehouse_no = sr.warehouse_no AND d.receipt_doc_no = sr.receipt_doc_no
+		WHERE d.deleted_at IS NULL
+		ORDER BY sr.return_no DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var returns []domain.SupplierReturn
+	for rows.Next() {
+		var sr domain.SupplierReturn
+		err := rows.Scan(
+			&sr.ReturnNo,
+			&sr.WarehouseNo,
+			&sr.ReceiptDocNo,
+			&sr.PartCode,
+			&sr.Unit,
+			&sr.Qty,
+			&sr.ReturnedDate,
+			&sr.Reason,
+			&sr.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		returns = append(returns, sr)
+	}
+	return returns, nil
+}
+
+// CreateReturn sends rejected parts back to the supplier. The delivery must be
+// inspected (ErrReferenceMissing otherwise) and the returns may not exceed the
+// rejected quantity (ErrInvalidState).
+func (r *Repository) CreateReturn(ctx context.Context, warehouseNo, receiptDocNo int, qty float64, returnedDate, reason string) (int, error) {
+	var returnNo int
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO supplier_returns (warehouse_no, receipt_doc_no, qty, returned_date, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING return_no
+	`, warehouseNo, receiptDocNo, qty, returnedDate, reason).Scan(&returnNo)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return returnNo, nil
+}
@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+const invoiceColumns = `i.invoice_id, i.supplier_id, s.name, i.invoice_no, i.invoice_date, i.due_date,
+	COALESCE((SELECT SUM(l.amount) FROM invoice_lines l WHERE l.invoice_id = i.invoice_id), 0),
+	COALESCE((SELECT SUM(p.amount) FROM supplier_payments p WHERE p.invoice_id = i.invoice_id), 0),
+	(SELECT COUNT(*) FROM invoice_matching m WHERE m.invoice_id = i.invoice_id
+		AND (m.delivery_missing OR m.qty_mismatch OR m.price_mismatch OR m.amount_mismatch))`
+
+func scanInvoice(row pgx.Row) (domain.Invoice, error) {
+	var inv domain.Invoice
+	err := row.Scan(
+		&inv.InvoiceID,
+		&inv.SupplierID,
+		&inv.SupplierName,
+		&inv.InvoiceNo,
+		&inv.InvoiceDate,
+		&inv.DueDate,
+		&inv.Amount,
+		&inv.PaidAmount,
+		&inv.Mismatches,
+	)
+	return inv, err
+}
+
+// GetInvoices returns the invoices with their totals and the number of lines
+// failing the three-way match, newest first.
+func (r *Repository) GetInvoices(ctx context.Context) ([]domain.Invoice, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM supplier_invoices i
+		JOIN suppliers s ON s.supplier_id = i.supplier_id
+		ORDER BY i.invoice_date DESC, i.invoice_id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []domain.Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, nil
+}
+
+// GetInvoice returns an invoice with its lines and payments.
+func (r *Repository) GetInvoice(ctx context.Context, invoiceID int) (*domain.Invoice, error) {
+	inv, err := scanInvoice(r.db.QueryRow(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM supplier_invoices i
+		JOIN suppliers s ON s.supplier_id = i.supplier_id
+		WHERE i.invoice_id = $1
+	`, invoiceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("invoice %d: %w", invoiceID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT line_no, warehouse_no, receipt_doc_no, qty, unit_price, amount
+		FROM invoice_lines
+		WHERE invoice_id = $1
+		ORDER BY line_no
+	`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l domain.InvoiceLine
+		if err := rows.Scan(&l.LineNo, &l.WarehouseNo, &l.ReceiptDocNo, &l.Qty, &l.UnitPrice, &l.Amount); err != nil {
+			return nil, err
+		}
+		inv.Lines = append(inv.Lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	payments, err := r.db.Query(ctx, `
+		SELECT payment_id, invoice_id, paid_date, amount, reference
+		FROM supplier_payments
+		WHERE invoice_id = $1
+		ORDER BY paid_date, payment_id
+	`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer payments.Close()
+	for payments.Next() {
+		var p domain.Payment
+		if err := payments.Scan(&p.PaymentID, &p.InvoiceID, &p.PaidDate, &p.Amount, &p.Reference); err != nil {
+			return nil, err
+		}
+		inv.Payments = append(inv.Payments, p)
+	}
+	return &inv, nil
+}
+
+// CreateInvoice records a supplier invoice. Every line must bill a live delivery
+// under a contract of the same supplier (ErrInvalidState otherwise); a delivery
+// already billed gives ErrKeyConflict.
+func (r *Repository) CreateInvoice(ctx context.Context, inv domain.Invoice) (int, error) {
+	if len(inv.Lines) == 0 {
+		return 0, errors.New("invoice has no lines")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var invoiceID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO supplier_invoices (supplier_id, invoice_no, invoice_date, due_date)
+		VALUES ($1, $2, $3, $4)
+		RETURNING invoice_id
+	`, inv.SupplierID, inv.InvoiceNo, inv.InvoiceDate, inv.DueDate).Scan(&invoiceID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	for i, l := range inv.Lines {
+		var supplierID int
+		err := tx.QueryRow(ctx, `
+			SELECT h.supplier_id
+			FROM deliveries d
+			JOIN contract_headers h ON h.contract_no = d.contract_no
+			WHERE d.warehouse_no = $1 AND d.receipt_doc_no = $2 AND d.deleted_at IS NULL
+		`, l.WarehouseNo, l.ReceiptDocNo).Scan(&supplierID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("delivery %d/%d: %w", *l.WarehouseNo, *l.ReceiptDocNo, ErrReferenceMissing)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if supplierID != inv.SupplierID {
+			return 0, fmt.Errorf("delivery %d/%d is from supplier %d: %w", *l.WarehouseNo, *l.ReceiptDocNo, supplierID, ErrInvalidState)
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO invoice_lines (invoice_id, line_no, warehouse_no, receipt_doc_no, qty, unit_price)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, invoiceID, i+1, l.WarehouseNo, l.ReceiptDocNo, l.Qty, l.UnitPrice)
+		if err != nil {
+			return 0, translateError(err)
+		}
+	}
+
+	return invoiceID, tx.Commit(ctx)
+}
+
+// DeleteInvoice removes an invoice entered by mistake; paid invoices are kept (ErrInUse).
+func (r *Repository) DeleteInvoice(ctx context.Context, invoiceID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM supplier_invoices WHERE invoice_id = $1", invoiceID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return fmt.Errorf("invoice %d: %w: %s", invoiceID, ErrInUse, pgErr.Detail)
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("invoice %d: %w", invoiceID, ErrNotFound)
+	}
+	return nil
+}
+
+// CreatePayment records a payment against an invoice. Payments may not exceed the
+// invoice amount (ErrInvalidState).
+func (r *Repository) CreatePayment(ctx context.Context, p domain.Payment) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var open float64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE((SELECT SUM(l.amount) FROM invoice_lines l WHERE l.invoice_id = i.invoice_id), 0)
+			- COALESCE((SELECT SUM(p.amount) FROM supplier_payments p WHERE p.invoice_id = i.invoice_id), 0)
+		FROM supplier_invoices i
+		WHERE i.invoice_id = $1
+		FOR UPDATE
+	`, p.InvoiceID).Scan(&open)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("invoice %d: %w", p.InvoiceID, ErrNotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if p.Amount > open+0.005 {
+		return 0, fmt.Errorf("invoice %d: payment %.2f exceeds open amount %.2f: %w", p.InvoiceID, p.Amount, open, ErrInvalidState)
+	}
+
+	var paymentID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO supplier_payments (invoice_id, paid_date, amount, reference)
+		VALUES ($1, $2, $3, $4)
+		RETURNING payment_id
+	`, p.InvoiceID, p.PaidDate, p.Amount, p.Reference).Scan(&paymentID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return paymentID, tx.Commit(ctx)
+}
+
+// GetInvoiceMatches returns the three-way match of invoice lines, only of one
+// invoice when invoiceID is not 0, only failing lines with mismatchesOnly.
+func (r *Repository) GetInvoiceMatches(ctx context.Context, invoiceID int, mismatchesOnly bool) ([]domain.InvoiceMatch, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT invoice_id, supplier_id, invoice_no, line_no, warehouse_no, receipt_doc_no,
+			contract_no, part_code, unit, received_date, received_qty, contract_price,
+			invoiced_qty, unit_price, invoiced_amount, expected_amount,
+			delivery_missing, qty_mismatch, price_mismatch, amount_mismatch
+		FROM invoice_matching
+		WHERE ($1 = 0 OR invoice_id = $1)
+		  AND (NOT $2 OR delivery_missing OR qty_mismatch OR price_mismatch OR amount_mismatch)
+		ORDER BY invoice_id, line_no
+	`, invoiceID, mismatchesOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []domain.InvoiceMatch
+	for rows.Next() {
+		var m domain.InvoiceMatch
+		err := rows.Scan(
+			&m.InvoiceID,
+			&m.SupplierID,
+			&m.InvoiceNo,
+			&m.LineNo,
+			&m.WarehouseNo,
+			&m.ReceiptDocNo,
+			&m.ContractNo,
+			&m.PartCode,
+			&m.Unit,
+			&m.ReceivedDate,
+			&m.ReceivedQty,
+			&m.ContractPrice,
+			&m.InvoicedQty,
+			&m.UnitPrice,
+			&m.InvoicedAmount,
+			&m.ExpectedAmount,
+			&m.DeliveryMissing,
+			&m.QtyMismatch,
+			&m.PriceMismatch,
+			&m.AmountMismatch,
+		)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// GetAPAging returns the open invoices as of on with their aging bucket.
+func (r *Repository) GetAPAging(ctx context.Context, on string) ([]domain.APAging, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT invoice_id, supplier_id, supplier_name, invoice_no, invoice_date, due_date,
+			amount, paid, open_amount, days_overdue, bucket
+		FROM fn_ap_aging($1::date)
+	`, on)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aging []domain.APAging
+	for rows.Next() {
+		var a domain.APAging
+		err := rows.Scan(
+			&a.InvoiceID,
+			&a.SupplierID,
+			&a.SupplierName,
+			&a.InvoiceNo,
+			&a.InvoiceDate,
+			&a.DueDate,
+			&a.Amount,
+			&a.Paid,
+			&a.OpenAmount,
+			&a.DaysOverdue,
+			&a.Bucket,
+		)
+		if err != nil {
+			return nil, err
+		}
+		aging = append(aging, a)
+	}
+	return aging, nil
+}
+
+// GetAPAgingSummary totals fn_ap_aging per supplier and bucket.
+func (r *Repository) GetAPAgingSummary(ctx context.Context, on string) ([]domain.APAgingSummary, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT supplier_id, supplier_name,
+			COALESCE(SUM(open_amount) FILTER (WHERE bucket = 'current'), 0),
+			COALESCE(SUM(open_amount) FILTER (WHERE bucket = '1-30'), 0),
+			COALESCE(SUM(open_amount) FILTER (WHERE bucket = '31-60'), 0),
+			COALESCE(SUM(open_amount) FILTER (WHERE bucket = '61-90'), 0),
+			COALESCE(SUM(open_amount) FILTER (WHERE bucket = '90+'), 0),
+			SUM(open_amount)
+		FROM fn_ap_aging($1::date)
+		GROUP BY supplier_id, supplier_name
+		ORDER BY supplier_name
+	`, on)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summary []domain.APAgingSummary
+	for rows.Next() {
+		var s domain.APAgingSummary
+		err := rows.Scan(
+			&s.SupplierID,
+			&s.SupplierName,
+			&s.Current,
+			&s.Days1To30,
+			&s.Days31To60,
+			&s.Days61To90,
+			&s.Over90,
+			&s.Total,
+		)
+		if err != nil {
+			return nil, err
+		}
+		summary = append(summary, s)
+	}
+	return summary, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// MoveDelivery changes the primary key (warehouse_no, receipt_doc_no) of a delivery
+// and records the change in key_changes.
+func (r *Repository) MoveDelivery(ctx context.Context, warehouseNo, receiptDocNo, newWarehouseNo, newReceiptDocNo int) error {
+	if warehouseNo == newWarehouseNo && receiptDocNo == newReceiptDocNo {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE deliveries
+		SET warehouse_no = $1, receipt_doc_no = $2
+		WHERE warehouse_no = $3 AND receipt_doc_no = $4 AND deleted_at IS NULL
+	`, newWarehouseNo, newReceiptDocNo, warehouseNo, receiptDocNo)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delivery %d/%d: %w", warehouseNo, receiptDocNo, ErrNotFound)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO key_changes (entity, old_key, new_key) VALUES ('delivery', $1, $2)
+	`, fmt.Sprintf("%d/%d", warehouseNo, receiptDocNo), fmt.Sprintf("%d/%d", newWarehouseNo, newReceiptDocNo))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RenumberContract changes the primary key (contract_no, part_code) of a contract line.
+// Deliveries follow through ON UPDATE CASCADE of fk_delivery_contract and the ledger
+// reposts them under the new part code. Issues and transfers of the old part from
+// the warehouses of those deliveries would stay behind, so a part code change is
+// refused with ErrInUse while there are any.
+func (r *Repository) RenumberContract(ctx context.Context, contractNo int, partCode string, newContractNo int, newPartCode string) error {
+	if contractNo == newContractNo && partCode == newPartCode {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if newPartCode != partCode {
+		var used bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM deliveries d
+				WHERE d.contract_no = $1 AND d.part_code = $2 AND d.deleted_at IS NULL
+				  AND (EXISTS (SELECT 1 FROM issues i
+				               WHERE i.warehouse_no = d.warehouse_no AND i.part_code = d.part_code)
+				    OR EXISTS (SELECT 1 FROM transfers t
+				               JOIN transfer_lines tl ON tl.transfer_no = t.transfer_no
+				               WHERE t.from_warehouse_no = d.warehouse_no AND tl.part_code = d.part_code))
+			)
+		`, contractNo, partCode).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used {
+			return fmt.Errorf("contract %d/%s: parts of its deliveries are issued or transferred: %w", contractNo, partCode, ErrInUse)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE contracts
+		SET contract_no = $1, part_code = $2
+		WHERE contract_no = $3 AND part_code = $4 AND deleted_at IS NULL
+	`, newContractNo, newPartCode, contractNo, partCode)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO key_changes (entity, old_key, new_key) VALUES ('contract', $1, $2)
+	`, fmt.Sprintf("%d/%s", contractNo, partCode), fmt.Sprintf("%d/%s", newContractNo, newPartCode))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) GetKeyChanges(ctx context.Context) ([]domain.KeyChange, error) {
+	rows, err := r.db.Query(ctx, "SELECT id, entity, old_key, new_key, changed_at FROM key_changes ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []domain.KeyChange
+	for rows.Next() {
+		var k domain.KeyChange
+		if err := rows.Scan(&k.ID, &k.Entity, &k.OldKey, &k.NewKey, &k.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, k)
+	}
+	return changes, nil
+}
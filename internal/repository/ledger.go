@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetLedger returns stock_ledger entries, only for warehouseNo when it is not 0.
+func (r *Repository) GetLedger(ctx context.Context, warehouseNo int) ([]domain.LedgerEntry, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, posted_at, movement_date, warehouse_no, part_code, unit, qty,
+			source_type, source_ref, reverses_id, comment
+		FROM stock_ledger
+		WHERE $1 = 0 OR warehouse_no = $1
+		ORDER BY id DESC
+	`, warehouseNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.LedgerEntry
+	for rows.Next() {
+		var e domain.LedgerEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.PostedAt,
+			&e.MovementDate,
+			&e.WarehouseNo,
+			&e.PartCode,
+			&e.Unit,
+			&e.Qty,
+			&e.SourceType,
+			&e.SourceRef,
+			&e.ReversesID,
+			&e.Comment,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// PostAdjustment posts a manual adjustment to the ledger and returns its id.
+// A negative qty is refused with ErrInsufficientStock when the balance does not
+// cover it; the balance is checked under the same advisory lock the issue and
+// transfer triggers take.
+func (r *Repository) PostAdjustment(ctx context.Context, warehouseNo int, partCode, unit string, qty float64, movementDate, comment string) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := postAdjustment(ctx, tx, warehouseNo, partCode, unit, qty, movementDate, "manual", comment)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// postAdjustment posts an adjustment entry within q; sourceRef tells where it
+// comes from ("manual" or a stock count).
+func postAdjustment(ctx context.Context, q querier, warehouseNo int, partCode, unit string, qty float64, movementDate, sourceRef, comment string) (int, error) {
+	var exists int
+	err := q.QueryRow(ctx, "SELECT warehouse_no FROM warehouses WHERE warehouse_no = $1 AND deleted_at IS NULL FOR SHARE", warehouseNo).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("warehouse %d: %w", warehouseNo, ErrReferenceMissing)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1::int || '/' || $2 || '/' || $3))", warehouseNo, partCode, unit); err != nil {
+		return 0, err
+	}
+	if qty < 0 {
+		var balance float64
+		if err := q.QueryRow(ctx, "SELECT fn_stock_balance($1, $2, $3)", warehouseNo, partCode, unit).Scan(&balance); err != nil {
+			return 0, err
+		}
+		if balance+qty < 0 {
+			return 0, fmt.Errorf("%w: %s %s in warehouse %d: balance %.2f, adjustment %.2f", ErrInsufficientStock, partCode, unit, warehouseNo, balance, qty)
+		}
+	}
+
+	var id int
+	err = q.QueryRow(ctx, `
+		INSERT INTO stock_ledger (movement_date, warehouse_no, part_code, unit, qty, source_type, source_ref, comment)
+		VALUES ($1, $2, $3, $4, $5, 'adjustment', $6, NULLIF($7, ''))
+		RETURNING id
+	`, movementDate, warehouseNo, partCode, unit, qty, sourceRef, comment).Scan(&id)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return id, nil
+}
+
+// GetPeriods returns every month that has ledger entries or has been closed,
+// newest first.
+func (r *Repository) GetPeriods(ctx context.Context) ([]domain.AccountingPeriod, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH months AS (
+			SELECT date_trunc('month', movement_date)::date AS period, COUNT(*) AS entries
+			FROM stock_ledger
+			GROUP BY 1
+		)
+		SELECT COALESCE(m.period, p.period), COALESCE(m.entries, 0), p.closed_at, p.closed_by
+		FROM months m
+		FULL JOIN accounting_periods p ON p.period = m.period
+		ORDER BY 1 DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []domain.AccountingPeriod
+	for rows.Next() {
+		var p domain.AccountingPeriod
+		if err := rows.Scan(&p.Period, &p.Entries, &p.ClosedAt, &p.ClosedBy); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, nil
+}
+
+// ClosePeriod closes the month containing period. Closing an already closed
+// month returns ErrKeyConflict.
+func (r *Repository) ClosePeriod(ctx context.Context, period time.Time, closedBy string) error {
+	month := time.Date(period.Year(), period.Month(), 1, 0, 0, 0, 0, time.UTC)
+	_, err := r.db.Exec(ctx, "INSERT INTO accounting_periods (period, closed_by) VALUES ($1, $2)", month, closedBy)
+	return translateError(err)
+}
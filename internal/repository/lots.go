@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+const lotBalanceColumns = `warehouse_no, manager_surname, lot_no, origin_warehouse_no, origin_receipt_doc_no,
+	contract_no, part_code, unit, manufactured_date, expiry_date, qty`
+
+func scanLotBalances(rows pgx.Rows) ([]domain.LotBalance, error) {
+	defer rows.Close()
+
+	var lots []domain.LotBalance
+	for rows.Next() {
+		var l domain.LotBalance
+		err := rows.Scan(
+			&l.WarehouseNo,
+			&l.ManagerSurname,
+			&l.LotNo,
+			&l.OriginWarehouseNo,
+			&l.OriginReceiptDocNo,
+			&l.ContractNo,
+			&l.PartCode,
+			&l.Unit,
+			&l.ManufacturedDate,
+			&l.ExpiryDate,
+			&l.Qty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, rows.Err()
+}
+
+// GetDeliveryLots returns the lots received with live deliveries, only of one
+// delivery when warehouseNo and receiptDocNo are not 0.
+func (r *Repository) GetDeliveryLots(ctx context.Context, warehouseNo, receiptDocNo int) ([]domain.DeliveryLot, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT l.warehouse_no, l.receipt_doc_no, l.lot_no, l.qty, l.manufactured_date, l.expiry_date
+		FROM delivery_lots l
+		JOIN deliveries d
+			ON d.warehouse_no = l.warehouse_no AND d.receipt_doc_no = l.receipt_doc_no
+		WHERE d.deleted_at IS NULL
+		  AND ($1 = 0 OR (l.warehouse_no = $1 AND l.receipt_doc_no = $2))
+		ORDER BY l.warehouse_no, l.receipt_doc_no, l.lot_no
+	`, warehouseNo, receiptDocNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []domain.DeliveryLot
+	for rows.Next() {
+		var l domain.DeliveryLot
+		if err := rows.Scan(&l.WarehouseNo, &l.ReceiptDocNo, &l.LotNo, &l.Qty, &l.ManufacturedDate, &l.ExpiryDate); err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, nil
+}
+
+// AddDeliveryLots splits a live delivery into lots. All lots of a delivery together
+// may not exceed its accepted quantity (ErrInvalidState).
+func (r *Repository) AddDeliveryLots(ctx context.Context, warehouseNo, receiptDocNo int, lots []domain.DeliveryLot) error {
+	if len(lots) == 0 {
+		return errors.New("no lots given")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var accepted, inLots float64
+	err = tx.QueryRow(ctx, `
+		SELECT d.qty - COALESCE(i.rejected_qty, 0),
+			COALESCE((SELECT SUM(l.qty) FROM delivery_lots l
+				WHERE l.warehouse_no = d.warehouse_no AND l.receipt_doc_no = d.receipt_doc_no), 0)
+		FROM deliveries d
+		LEFT JOIN delivery_inspections i
+			ON i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no
+		WHERE d.warehouse_no = $1 AND d.receipt_doc_no = $2 AND d.deleted_at IS NULL
+		FOR UPDATE OF d
+	`, warehouseNo, receiptDocNo).Scan(&accepted, &inLots)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("delivery %d/%d: %w", warehouseNo, receiptDocNo, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lots {
+		inLots += l.Qty
+		_, err := tx.Exec(ctx, `
+			INSERT INTO delivery_lots (warehouse_no, receipt_doc_no, lot_no, qty, manufactured_date, expiry_date)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, warehouseNo, receiptDocNo, l.LotNo, l.Qty, l.ManufacturedDate, l.ExpiryDate)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+	if inLots > accepted {
+		return fmt.Errorf("delivery %d/%d: lots total %g exceeds accepted %g: %w", warehouseNo, receiptDocNo, inLots, accepted, ErrInvalidState)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteDeliveryLot removes a lot entered by mistake. It fails with ErrInUse once
+// an issue or a transfer has taken parts from the lot; the lot row stays locked
+// between the check and the delete so no issue can take from it in between.
+func (r *Repository) DeleteDeliveryLot(ctx context.Context, warehouseNo, receiptDocNo int, lotNo string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists int
+	err = tx.QueryRow(ctx, `
+		SELECT 1 FROM delivery_lots
+		WHERE warehouse_no = $1 AND receipt_doc_no = $2 AND lot_no = $3
+		FOR UPDATE
+	`, warehouseNo, receiptDocNo, lotNo).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("lot %s of delivery %d/%d: %w", lotNo, warehouseNo, receiptDocNo, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	var used bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM issues
+				WHERE origin_warehouse_no = $1 AND origin_receipt_doc_no = $2 AND lot_no = $3)
+			OR EXISTS (SELECT 1 FROM transfer_lines
+				WHERE origin_warehouse_no = $1 AND origin_receipt_doc_no = $2 AND lot_no = $3)
+	`, warehouseNo, receiptDocNo, lotNo).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return fmt.Errorf("lot %s of delivery %d/%d: %w", lotNo, warehouseNo, receiptDocNo, ErrInUse)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM delivery_lots
+		WHERE warehouse_no = $1 AND receipt_doc_no = $2 AND lot_no = $3
+	`, warehouseNo, receiptDocNo, lotNo)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// FindLot returns the warehouses holding parts of lots numbered lotNo. The same
+// number may come with several deliveries, so each row names its delivery and contract.
+func (r *Repository) FindLot(ctx context.Context, lotNo string) ([]domain.LotBalance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+lotBalanceColumns+`
+		FROM lot_balance
+		WHERE lot_no = $1 AND qty > 0
+		ORDER BY origin_warehouse_no, origin_receipt_doc_no, warehouse_no
+	`, lotNo)
+	if err != nil {
+		return nil, err
+	}
+	return scanLotBalances(rows)
+}
+
+// GetExpiringLots returns lot balances expiring within days from today, already
+// expired ones included.
+func (r *Repository) GetExpiringLots(ctx context.Context, days int) ([]domain.LotBalance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+lotBalanceColumns+`
+		FROM lot_balance
+		WHERE qty > 0 AND expiry_date <= CURRENT_DATE + $1::int
+		ORDER BY expiry_date, warehouse_no, lot_no
+	`, days)
+	if err != nil {
+		return nil, err
+	}
+	return scanLotBalances(rows)
+}
+
+// SuggestFEFO picks the lots to issue qty from, earliest expiry first. The picks
+// fall short of qty when the unexpired lots in the warehouse do not cover it.
+func (r *Repository) SuggestFEFO(ctx context.Context, warehouseNo int, partCode, unit string, qty float64, on string) ([]domain.FEFOPick, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT lot_no, origin_warehouse_no, origin_receipt_doc_no, contract_no,
+			manufactured_date, expiry_date, available_qty, take_qty
+		FROM fn_fefo_pick($1, $2, $3, $4, $5::date)
+	`, warehouseNo, partCode, unit, qty, on)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var picks []domain.FEFOPick
+	for rows.Next() {
+		var p domain.FEFOPick
+		err := rows.Scan(
+			&p.LotNo,
+			&p.OriginWarehouseNo,
+			&p.OriginReceiptDocNo,
+			&p.ContractNo,
+			&p.ManufacturedDate,
+			&p.ExpiryDate,
+			&p.AvailableQty,
+			&p.TakeQty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		picks = append(picks, p)
+	}
+	return picks, nil
+}
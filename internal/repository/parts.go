@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetParts(ctx context.Context) ([]domain.Part, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT part_code, name, description, default_unit, weight_per_piece, category
+		FROM parts
+		ORDER BY part_code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []domain.Part
+	for rows.Next() {
+		var p domain.Part
+		if err := rows.Scan(&p.PartCode, &p.Name, &p.Description, &p.DefaultUnit, &p.WeightPerPiece, &p.Category); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+func (r *Repository) CreatePart(ctx context.Context, p domain.Part) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO parts (part_code, name, description, default_unit, weight_per_piece, category)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, p.PartCode, p.Name, p.Description, p.DefaultUnit, p.WeightPerPiece, p.Category)
+	return translateError(err)
+}
+
+// UpdatePart changes everything but the code; a part is renamed through its contracts
+// (RenumberContract), never in the catalog.
+func (r *Repository) UpdatePart(ctx context.Context, p domain.Part) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE parts
+		SET name = $2, description = $3, default_unit = $4, weight_per_piece = $5, category = $6
+		WHERE part_code = $1
+	`, p.PartCode, p.Name, p.Description, p.DefaultUnit, p.WeightPerPiece, p.Category)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("part %s: %w", p.PartCode, ErrNotFound)
+	}
+	return nil
+}
+
+// DeletePart removes a part from the catalog. It fails with ErrInUse while any
+// contract or delivery, including trashed ones, refers to it.
+func (r *Repository) DeletePart(ctx context.Context, partCode string) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM parts WHERE part_code = $1", partCode)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return fmt.Errorf("part %s: %w: %s", partCode, ErrInUse, pgErr.Detail)
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("part %s: %w", partCode, ErrNotFound)
+	}
+	return nil
+}
+
+// GetPartCategories returns the distinct categories of the part catalog.
+func (r *Repository) GetPartCategories(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, "SELECT DISTINCT category FROM parts WHERE category IS NOT NULL ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
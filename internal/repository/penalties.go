@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetPenaltyTerms(ctx context.Context) ([]domain.PenaltyTerms, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT contract_no, part_code, daily_rate_pct, cap_pct
+		FROM contract_penalty_terms
+		ORDER BY contract_no, part_code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []domain.PenaltyTerms
+	for rows.Next() {
+		var t domain.PenaltyTerms
+		if err := rows.Scan(&t.ContractNo, &t.PartCode, &t.DailyRatePct, &t.CapPct); err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// SetPenaltyTerms creates or replaces the penalty terms of a contract line.
+func (r *Repository) SetPenaltyTerms(ctx context.Context, t domain.PenaltyTerms) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO contract_penalty_terms (contract_no, part_code, daily_rate_pct, cap_pct)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (contract_no, part_code)
+		DO UPDATE SET daily_rate_pct = EXCLUDED.daily_rate_pct, cap_pct = EXCLUDED.cap_pct
+	`, t.ContractNo, t.PartCode, t.DailyRatePct, t.CapPct)
+	return translateError(err)
+}
+
+func (r *Repository) DeletePenaltyTerms(ctx context.Context, contractNo int, partCode string) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM contract_penalty_terms WHERE contract_no = $1 AND part_code = $2", contractNo, partCode)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("penalty terms of contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	return nil
+}
+
+// GetPenaltyClaims returns the capped penalties as of on, only of one supplier
+// when supplierID is not 0.
+func (r *Repository) GetPenaltyClaims(ctx context.Context, on string, supplierID int) ([]domain.PenaltyClaim, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT supplier_id, supplier_name, contract_no, part_code, unit, daily_rate_pct, cap_pct,
+			late_qty, max_days_late, accrued, cap_amount, penalty
+		FROM fn_penalty_claims($1::date)
+		WHERE $2 = 0 OR supplier_id = $2
+	`, on, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claims []domain.PenaltyClaim
+	for rows.Next() {
+		var c domain.PenaltyClaim
+		err := rows.Scan(
+			&c.SupplierID,
+			&c.SupplierName,
+			&c.ContractNo,
+			&c.PartCode,
+			&c.Unit,
+			&c.DailyRatePct,
+			&c.CapPct,
+			&c.LateQty,
+			&c.MaxDaysLate,
+			&c.Accrued,
+			&c.CapAmount,
+			&c.Penalty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, nil
+}
+
+// GetPenaltyEvents returns the penalty accruals of one supplier as of on.
+func (r *Repository) GetPenaltyEvents(ctx context.Context, on string, supplierID int) ([]domain.PenaltyEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT e.contract_no, e.part_code, e.unit, e.tranche_no, e.due_date, e.warehouse_no, e.receipt_doc_no,
+			e.received_date, e.late_qty, e.days_late, e.price, e.amount
+		FROM fn_penalty_events($1::date) e
+		JOIN contract_headers h
+			ON h.contract_no = e.contract_no
+		WHERE h.supplier_id = $2
+		ORDER BY e.contract_no, e.part_code, e.due_date, e.received_date NULLS LAST, e.warehouse_no, e.receipt_doc_no
+	`, on, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.PenaltyEvent
+	for rows.Next() {
+		var e domain.PenaltyEvent
+		err := rows.Scan(
+			&e.ContractNo,
+			&e.PartCode,
+			&e.Unit,
+			&e.TrancheNo,
+			&e.DueDate,
+			&e.WarehouseNo,
+			&e.ReceiptDocNo,
+			&e.ReceivedDate,
+			&e.LateQty,
+			&e.DaysLate,
+			&e.Price,
+			&e.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
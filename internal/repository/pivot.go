@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// pivotGroups maps the pivot row groupings to their full_deliveries_view
+// expression and the expression ordering the groups within a warehouse.
+var pivotGroups = map[string]struct{ expr, order string }{
+	"":         {"''", "warehouse_no"},
+	"part":     {"part_code", "MIN(part_code)"},
+	"contract": {"contract_no::text", "MIN(contract_no)"},
+}
+
+// IsPivotGroup reports whether GetPivot accepts groupBy; empty means warehouses only.
+func IsPivotGroup(groupBy string) bool {
+	_, ok := pivotGroups[groupBy]
+	return ok
+}
+
+// IsPivotMeasure reports whether GetPivot accepts measure.
+func IsPivotMeasure(measure string) bool {
+	return measure == "qty" || measure == "value"
+}
+
+// GetPivot builds the warehouse by month cross-tab of the deliveries received
+// between from and to (both optional, YYYY-MM-DD). It sums accepted quantities
+// and their value at the contract price in effect on the received date, like the
+// dashboard. Every month of the period gets a column, with or without receipts;
+// rows are split by groupBy and, for quantities, by unit, and each warehouse
+// gets subtotal rows when split.
+func (r *Repository) GetPivot(ctx context.Context, measure, groupBy, from, to string) (*domain.Pivot, error) {
+	group, ok := pivotGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown grouping %q", groupBy)
+	}
+	if !IsPivotMeasure(measure) {
+		return nil, fmt.Errorf("unknown measure %q", measure)
+	}
+	unit := "''"
+	if measure == "qty" {
+		unit = "delivery_unit"
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT warehouse_no, COALESCE(manager_surname, ''), `+group.expr+` AS grp, `+unit+` AS unit,
+			to_char(received_date, 'YYYY-MM') AS month,
+			SUM(accepted_qty), COALESCE(SUM(base_value), 0), bool_or(base_value IS NULL)
+		FROM full_deliveries_view
+		WHERE (NULLIF($1, '')::date IS NULL OR received_date >= NULLIF($1, '')::date)
+			AND (NULLIF($2, '')::date IS NULL OR received_date <= NULLIF($2, '')::date)
+		GROUP BY warehouse_no, manager_surname, grp, unit, month
+		ORDER BY warehouse_no, `+group.order+`, unit, month
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type cell struct {
+		row     domain.PivotRow
+		month   string
+		amount  float64
+		missing bool
+	}
+	var cells []cell
+	for rows.Next() {
+		var c cell
+		var qty, value float64
+		err := rows.Scan(
+			&c.row.WarehouseNo,
+			&c.row.ManagerSurname,
+			&c.row.Group,
+			&c.row.Unit,
+			&c.month,
+			&qty,
+			&value,
+			&c.missing,
+		)
+		if err != nil {
+			return nil, err
+		}
+		c.amount = value
+		if measure == "qty" {
+			c.amount, c.missing = qty, false
+		}
+		cells = append(cells, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pivot := &domain.Pivot{Measure: measure, GroupBy: groupBy}
+	if pivot.Months, err = r.pivotMonths(ctx, from, to); err != nil {
+		return nil, err
+	}
+	column := make(map[string]int, len(pivot.Months))
+	for i, m := range pivot.Months {
+		column[m] = i
+	}
+	add := func(row *domain.PivotRow, c cell) {
+		if row.Cells == nil {
+			row.Cells = make([]float64, len(pivot.Months))
+		}
+		row.Cells[column[c.month]] += c.amount
+		row.Total += c.amount
+		row.RateMissing = row.RateMissing || c.missing
+	}
+
+	// Cells arrive ordered by warehouse, so subtotals are flushed whenever the
+	// warehouse changes.
+	type rowKey struct {
+		warehouseNo int
+		group, unit string
+	}
+	index := map[rowKey]int{}
+	var subtotals []domain.PivotRow
+	subtotalIndex := map[string]int{}
+	totalIndex := map[string]int{}
+	flush := func() {
+		if groupBy != "" {
+			pivot.Rows = append(pivot.Rows, subtotals...)
+		}
+		subtotals, subtotalIndex = nil, map[string]int{}
+	}
+
+	for i, c := range cells {
+		if i > 0 && c.row.WarehouseNo != cells[i-1].row.WarehouseNo {
+			flush()
+		}
+
+		key := rowKey{c.row.WarehouseNo, c.row.Group, c.row.Unit}
+		n, ok := index[key]
+		if !ok {
+			n = len(pivot.Rows)
+			index[key] = n
+			pivot.Rows = append(pivot.Rows, domain.PivotRow{
+				WarehouseNo:    c.row.WarehouseNo,
+				ManagerSurname: c.row.ManagerSurname,
+				Group:          c.row.Group,
+				Unit:           c.row.Unit,
+			})
+		}
+		add(&pivot.Rows[n], c)
+
+		n, ok = subtotalIndex[c.row.Unit]
+		if !ok {
+			n = len(subtotals)
+			subtotalIndex[c.row.Unit] = n
+			subtotals = append(subtotals, domain.PivotRow{
+				WarehouseNo:    c.row.WarehouseNo,
+				ManagerSurname: c.row.ManagerSurname,
+				Unit:           c.row.Unit,
+				Subtotal:       true,
+			})
+		}
+		add(&subtotals[n], c)
+
+		n, ok = totalIndex[c.row.Unit]
+		if !ok {
+			n = len(pivot.Totals)
+			totalIndex[c.row.Unit] = n
+			pivot.Totals = append(pivot.Totals, domain.PivotRow{Unit: c.row.Unit})
+		}
+		add(&pivot.Totals[n], c)
+	}
+	flush()
+	sort.Slice(pivot.Totals, func(i, j int) bool { return pivot.Totals[i].Unit < pivot.Totals[j].Unit })
+
+	return pivot, nil
+}
+
+// pivotMonths lists the months (YYYY-MM) from from to to; an open end of the
+// period is taken from the first or last delivery received.
+func (r *Repository) pivotMonths(ctx context.Context, from, to string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT to_char(m, 'YYYY-MM')
+		FROM generate_series(
+			date_trunc('month', COALESCE(NULLIF($1, '')::date, (SELECT MIN(received_date) FROM full_deliveries_view))),
+			date_trunc('month', COALESCE(NULLIF($2, '')::date, (SELECT MAX(received_date) FROM full_deliveries_view))),
+			interval '1 month') m
+		ORDER BY m
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var months []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, err
+		}
+		months = append(months, m)
+	}
+	return months, rows.Err()
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/railgorail/kpfu-db-app/internal/reports"
+)
+
+// RunReport runs the query of rep with the named arguments args and returns
+// its rows in the order of the declared columns. Numerics come back as
+// float64 and smaller integers as int64, so rows format and encode uniformly.
+func (r *Repository) RunReport(ctx context.Context, rep *reports.Report, args map[string]any) ([][]any, error) {
+	rows, err := r.db.Query(ctx, rep.SQL, pgx.NamedArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	if len(fields) != len(rep.Columns) {
+		return nil, fmt.Errorf("report %s returns %d columns, %d declared", rep.Name, len(fields), len(rep.Columns))
+	}
+	for i, f := range fields {
+		if f.Name != rep.Columns[i].Name {
+			return nil, fmt.Errorf("report %s returns column %q where %q is declared", rep.Name, f.Name, rep.Columns[i].Name)
+		}
+	}
+
+	var result [][]any
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			switch v := v.(type) {
+			case pgtype.Numeric:
+				f, err := v.Float64Value()
+				if err != nil {
+					return nil, err
+				}
+				values[i] = nil
+				if f.Valid {
+					values[i] = f.Float64
+				}
+			case int16:
+				values[i] = int64(v)
+			case int32:
+				values[i] = int64(v)
+			case float32:
+				values[i] = float64(v)
+			}
+		}
+		result = append(result, values)
+	}
+	return result, rows.Err()
+}
@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/railgorail/kpfu-db-app/internal/domain"
 	"gorm.io/gorm"
@@ -15,6 +20,13 @@ type Repository struct {
 	gormDB *gorm.DB
 }
 
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 func New(db *pgxpool.Pool) *Repository {
 	return &Repository{db: db}
 }
@@ -24,7 +36,7 @@ func NewWithGORM(db *pgxpool.Pool, gormDB *gorm.DB) *Repository {
 }
 
 func (r *Repository) GetWarehouses(ctx context.Context) ([]domain.Warehouse, error) {
-	rows, err := r.db.Query(ctx, "SELECT warehouse_no, manager_surname FROM warehouses")
+	rows, err := r.db.Query(ctx, "SELECT warehouse_no, manager_surname FROM warehouses WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +54,7 @@ func (r *Repository) GetWarehouses(ctx context.Context) ([]domain.Warehouse, err
 }
 
 func (r *Repository) GetContracts(ctx context.Context) ([]domain.Contract, error) {
-	rows, err := r.db.Query(ctx, "SELECT contract_no, part_code, unit, start_date, end_date, plan_qty, contract_price FROM contracts")
+	rows, err := r.db.Query(ctx, "SELECT contract_no, part_code, unit, start_date, end_date, plan_qty, contract_price, currency FROM contracts WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +63,7 @@ func (r *Repository) GetContracts(ctx context.Context) ([]domain.Contract, error
 	var contracts []domain.Contract
 	for rows.Next() {
 		var c domain.Contract
-		if err := rows.Scan(&c.ContractNo, &c.PartCode, &c.Unit, &c.StartDate, &c.EndDate, &c.PlanQty, &c.ContractPrice); err != nil {
+		if err := rows.Scan(&c.ContractNo, &c.PartCode, &c.Unit, &c.StartDate, &c.EndDate, &c.PlanQty, &c.ContractPrice, &c.Currency); err != nil {
 			return nil, err
 		}
 		contracts = append(contracts, c)
@@ -60,7 +72,7 @@ func (r *Repository) GetContracts(ctx context.Context) ([]domain.Contract, error
 }
 
 func (r *Repository) GetDeliveries(ctx context.Context) ([]domain.Delivery, error) {
-	rows, err := r.db.Query(ctx, "SELECT warehouse_no, receipt_doc_no, contract_no, part_code, unit, qty, received_date FROM deliveries")
+	rows, err := r.db.Query(ctx, "SELECT warehouse_no, receipt_doc_no, contract_no, part_code, unit, qty, received_date FROM deliveries WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -77,13 +89,21 @@ func (r *Repository) GetDeliveries(ctx context.Context) ([]domain.Delivery, erro
 	return deliveries, nil
 }
 
-func (r *Repository) GetView(ctx context.Context) ([]domain.View, error) {
-	rows, err := r.db.Query(ctx, `
-		SELECT * FROM full_deliveries_view;
-	`)
+// GetView returns full_deliveries_view, or its state on asOf when asOf is not nil.
+func (r *Repository) GetView(ctx context.Context, asOf *time.Time) ([]domain.View, error) {
+	query, args := `SELECT * FROM full_deliveries_view;`, []any{}
+	if asOf != nil {
+		query, args = `SELECT * FROM fn_full_deliveries_as_of($1);`, []any{*asOf}
+	}
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	return scanView(rows)
+}
+
+// scanView reads rows with the columns of full_deliveries_view.
+func scanView(rows pgx.Rows) ([]domain.View, error) {
 	defer rows.Close()
 
 	var view []domain.View
@@ -95,6 +115,7 @@ func (r *Repository) GetView(ctx context.Context) ([]domain.View, error) {
 			&v.ReceiptDocNo,
 			&v.ReceivedDate,
 			&v.Qty,
+			&v.AcceptedQty,
 			&v.DeliveryUnit,
 			&v.ContractNo,
 			&v.PartCode,
@@ -103,6 +124,11 @@ func (r *Repository) GetView(ctx context.Context) ([]domain.View, error) {
 			&v.EndDate,
 			&v.PlanQty,
 			&v.ContractPrice,
+			&v.SupplierName,
+			&v.Currency,
+			&v.ExchangeRate,
+			&v.Value,
+			&v.BaseValue,
 		)
 		if err != nil {
 			return nil, err
@@ -111,166 +137,200 @@ func (r *Repository) GetView(ctx context.Context) ([]domain.View, error) {
 	}
 	return view, nil
 }
-func (r *Repository) GetTask1(ctx context.Context, price float64) ([]domain.Task1, error) {
-	rows, err := r.db.Query(ctx, `
-		SELECT d.warehouse_no, d.part_code, d.receipt_doc_no, d.received_date, d.qty, d.contract_no, c.contract_price
-		FROM deliveries d
-		JOIN contracts c
-		ON d.contract_no = c.contract_no
-		WHERE contract_price > $1
-		ORDER BY d.received_date;
-	`, price)
-	if err != nil {
+
+// ORMGetTask1 is Task 1 built with GORM: deliveries whose contract price in effect
+// on the received date, converted to the base currency, is above price. The
+// contract versions and exchange rates are loaded once and matched to each
+// delivery in Go, the way fn_contract_version_at and fn_exchange_rate do.
+func (r *Repository) ORMGetTask1(ctx context.Context, price float64, asOf *time.Time) ([]domain.Task1, error) {
+	db := r.gormDB.WithContext(ctx)
+
+	query := db.Where("deleted_at IS NULL").Order("received_date")
+	if asOf != nil {
+		query = query.Where("received_date <= ?", *asOf)
+	}
+	var deliveries []domain.Delivery
+	if err := query.Find(&deliveries).Error; err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var task1 []domain.Task1
-	for rows.Next() {
-		var t domain.Task1
-		err := rows.Scan(
-			&t.WarehouseNo,
-			&t.PartCode,
-			&t.ReceiptDocNo,
-			&t.ReceivedDate,
-			&t.Qty,
-			&t.ContractNo,
-			&t.ContractPrice,
-		)
-		if err != nil {
-			return nil, err
-		}
-		task1 = append(task1, t)
+	var contracts []domain.Contract
+	if err := db.Where("deleted_at IS NULL").Find(&contracts).Error; err != nil {
+		return nil, err
 	}
-	return task1, nil
-}
-
-func (r *Repository) ORMGetTask1(ctx context.Context, price float64) ([]domain.Task1, error) {
-	var deliveries []domain.Delivery
-	err := r.gormDB.WithContext(ctx).
-		Preload("Contract").
-		Order("received_date").
-		Find(&deliveries).Error
-	if err != nil {
+	var versions []domain.ContractVersion
+	if err := db.Order("valid_from").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	var rates []domain.ExchangeRate
+	if err := db.Order("rate_date").Find(&rates).Error; err != nil {
 		return nil, err
 	}
 
+	type lineKey struct {
+		contractNo int
+		partCode   string
+	}
+	currency := make(map[lineKey]string, len(contracts))
+	for _, c := range contracts {
+		currency[lineKey{c.ContractNo, c.PartCode}] = c.Currency
+	}
+	lineVersions := make(map[lineKey][]domain.ContractVersion)
+	for _, v := range versions {
+		k := lineKey{v.ContractNo, v.PartCode}
+		lineVersions[k] = append(lineVersions[k], v)
+	}
+	currencyRates := make(map[string][]domain.ExchangeRate)
+	for _, x := range rates {
+		currencyRates[x.Currency] = append(currencyRates[x.Currency], x)
+	}
+
 	var task1 []domain.Task1
 	for _, d := range deliveries {
-		if d.Contract.ContractPrice > price {
-			task1 = append(task1, domain.Task1{
-				WarehouseNo:   d.WarehouseNo,
-				PartCode:      d.PartCode,
-				ReceiptDocNo:  d.ReceiptDocNo,
-				ReceivedDate:  d.ReceivedDate,
-				Qty:           d.Qty,
-				ContractNo:    d.ContractNo,
-				ContractPrice: d.Contract.ContractPrice,
-			})
+		k := lineKey{d.ContractNo, d.PartCode}
+		cur, ok := currency[k]
+		if !ok || len(lineVersions[k]) == 0 {
+			continue
+		}
+		v := versionAt(lineVersions[k], d.ReceivedDate)
+		rate := rateAt(cur, currencyRates[cur], d.ReceivedDate)
+		if rate == nil || v.ContractPrice**rate <= price {
+			continue
 		}
+		base := math.Round(v.ContractPrice**rate*100) / 100
+		task1 = append(task1, domain.Task1{
+			WarehouseNo:   d.WarehouseNo,
+			PartCode:      d.PartCode,
+			ReceiptDocNo:  d.ReceiptDocNo,
+			ReceivedDate:  d.ReceivedDate,
+			Qty:           d.Qty,
+			ContractNo:    d.ContractNo,
+			ContractPrice: v.ContractPrice,
+			Currency:      cur,
+			ExchangeRate:  rate,
+			BasePrice:     &base,
+		})
 	}
-
 	return task1, nil
 }
 
-func (r *Repository) GetTask2(ctx context.Context) ([]domain.Task2, error) {
-	rows, err := r.db.Query(ctx, `
-		SELECT contract_no, part_code, plan_qty, end_date, 
-			SUM(plan_qty) OVER(PARTITION BY contract_no) AS total_plan_qty,
-			DENSE_RANK() OVER (ORDER BY end_date) AS priority
-		FROM contracts
-		WHERE contract_price > 100
-		ORDER BY end_date;
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var task2 []domain.Task2
-	for rows.Next() {
-		var t domain.Task2
-		err := rows.Scan(
-			&t.ContractNo,
-			&t.PartCode,
-			&t.PlanQty,
-			&t.EndDate,
-			&t.SumQty,
-			&t.Priotity,
-		)
-		if err != nil {
-			return nil, err
+// versionAt picks from versions, ordered by valid_from, the one in effect on
+// date, or the earliest before the first one, like fn_contract_version_at.
+func versionAt(versions []domain.ContractVersion, date time.Time) domain.ContractVersion {
+	v := versions[0]
+	for _, next := range versions[1:] {
+		if next.ValidFrom.After(date) {
+			break
 		}
-		task2 = append(task2, t)
+		v = next
 	}
-	return task2, nil
+	return v
 }
 
-func (r *Repository) GetTask3(ctx context.Context, planQty, deliveryQty int) ([]domain.Contract, error) {
-	rows, err := r.db.Query(ctx, `SELECT *
-	FROM contracts c
-	WHERE c.plan_qty > $1
-	AND EXISTS (
-		SELECT 1
-		FROM deliveries d
-		WHERE d.contract_no = c.contract_no
-		AND d.part_code = c.part_code
-			AND $2 < ALL (
-				SELECT d2.qty
-				FROM deliveries d2
-				WHERE d2.contract_no = c.contract_no
-				AND d2.part_code = c.part_code
-				AND d2.warehouse_no = d.warehouse_no
-        )
-  );
-
-	`, planQty, deliveryQty)
-	if err != nil {
-		return nil, err
+// rateAt returns the last of rates, ordered by rate_date, loaded on or before
+// date, 1 for the base currency and nil when there is none, like fn_exchange_rate.
+func rateAt(currency string, rates []domain.ExchangeRate, date time.Time) *float64 {
+	if currency == domain.BaseCurrency {
+		one := 1.0
+		return &one
 	}
-	defer rows.Close()
-
-	var task3 []domain.Contract
-	for rows.Next() {
-		var t domain.Contract
-		err := rows.Scan(
-			&t.ContractNo,
-			&t.PartCode,
-			&t.Unit,
-			&t.StartDate,
-			&t.EndDate,
-			&t.PlanQty,
-			&t.ContractPrice,
-		)
-		if err != nil {
-			return nil, err
+	var rate *float64
+	for _, x := range rates {
+		if x.RateDate.After(date) {
+			break
 		}
-		task3 = append(task3, t)
+		rate = &x.Rate
 	}
-	return task3, nil
+	return rate
 }
 
 func (r *Repository) UpdateWarehouse(ctx context.Context, warehouseNo int, managerSurname string) error {
-	_, err := r.db.Exec(ctx, "UPDATE warehouses SET manager_surname = $1 WHERE warehouse_no = $2", managerSurname, warehouseNo)
+	_, err := r.db.Exec(ctx, "UPDATE warehouses SET manager_surname = $1 WHERE warehouse_no = $2 AND deleted_at IS NULL", managerSurname, warehouseNo)
 	return err
 }
 
-func (r *Repository) UpdateContract(ctx context.Context, contractNo int, partCode string, unit string, startDate, endDate string, planQty, contractPrice float64) error {
-	_, err := r.db.Exec(ctx, `
-		UPDATE contracts 
-		SET unit = $1, start_date = $2, end_date = $3, plan_qty = $4, contract_price = $5 
-		WHERE contract_no = $6 AND part_code = $7
-	`, unit, startDate, endDate, planQty, contractPrice, contractNo, partCode)
-	return err
+// UpdateContract changes the unit of a contract line. The commercial terms may be
+// passed along but must match the current ones: changing them takes an approved
+// amendment, otherwise ErrAmendmentRequired is returned.
+func (r *Repository) UpdateContract(ctx context.Context, contractNo int, partCode string, unit string, startDate, endDate *string, planQty, contractPrice *float64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current domain.Contract
+	err = tx.QueryRow(ctx, `
+		SELECT start_date, end_date, plan_qty, contract_price
+		FROM contracts
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, contractNo, partCode).Scan(&current.StartDate, &current.EndDate, &current.PlanQty, &current.ContractPrice)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	if (startDate != nil && *startDate != current.StartDate.Format("2006-01-02")) ||
+		(endDate != nil && *endDate != current.EndDate.Format("2006-01-02")) ||
+		(planQty != nil && !sameAmount(*planQty, current.PlanQty)) ||
+		(contractPrice != nil && !sameAmount(*contractPrice, current.ContractPrice)) {
+		return ErrAmendmentRequired
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE contracts SET unit = $1 WHERE contract_no = $2 AND part_code = $3", unit, contractNo, partCode); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// sameAmount compares two DECIMAL(10,2) values.
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
 }
 
+// UpdateDelivery changes a live delivery. The new qty must still hold the rejected
+// quantity of its inspection and the recorded lots (ErrInvalidState).
 func (r *Repository) UpdateDelivery(ctx context.Context, warehouseNo, receiptDocNo int, contractNo int, partCode, unit string, qty float64, receivedDate string) error {
-	_, err := r.db.Exec(ctx, `
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var rejected, inLots float64
+	err = tx.QueryRow(ctx, `
+		SELECT
+			COALESCE((SELECT i.rejected_qty FROM delivery_inspections i
+				WHERE i.warehouse_no = d.warehouse_no AND i.receipt_doc_no = d.receipt_doc_no), 0),
+			COALESCE((SELECT SUM(l.qty) FROM delivery_lots l
+				WHERE l.warehouse_no = d.warehouse_no AND l.receipt_doc_no = d.receipt_doc_no), 0)
+		FROM deliveries d
+		WHERE d.warehouse_no = $1 AND d.receipt_doc_no = $2 AND d.deleted_at IS NULL
+		FOR UPDATE OF d
+	`, warehouseNo, receiptDocNo).Scan(&rejected, &inLots)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("delivery %d/%d: %w", warehouseNo, receiptDocNo, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if qty < rejected+inLots {
+		return fmt.Errorf("delivery %d/%d: qty %g is below rejected %g plus lots %g: %w",
+			warehouseNo, receiptDocNo, qty, rejected, inLots, ErrInvalidState)
+	}
+
+	_, err = tx.Exec(ctx, `
 		UPDATE deliveries
 		SET contract_no = $1, part_code = $2, unit = $3, qty = $4, received_date = $5
 		WHERE warehouse_no = $6 AND receipt_doc_no = $7
 	`, contractNo, partCode, unit, qty, receivedDate, warehouseNo, receiptDocNo)
-	return err
+	if err != nil {
+		return translateError(err)
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *Repository) CreateWarehouse(ctx context.Context, managerSurname string) (int, error) {
@@ -279,12 +339,31 @@ func (r *Repository) CreateWarehouse(ctx context.Context, managerSurname string)
 	return warehouseNo, err
 }
 
-func (r *Repository) CreateContract(ctx context.Context, contractNo int, partCode string, unit string, startDate, endDate string, planQty, contractPrice float64) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO contracts (contract_no, part_code, unit, start_date, end_date, plan_qty, contract_price)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, contractNo, partCode, unit, startDate, endDate, planQty, contractPrice)
-	return err
+// CreateContract adds a contract line. It returns the budgets the new commitment
+// pushes over their limit; a blocking one fails the insert with ErrBudgetExceeded.
+func (r *Repository) CreateContract(ctx context.Context, contractNo int, partCode string, unit string, startDate, endDate string, planQty, contractPrice float64, currency string) ([]domain.Budget, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockBudgets(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO contracts (contract_no, part_code, unit, start_date, end_date, plan_qty, contract_price, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, contractNo, partCode, unit, startDate, endDate, planQty, contractPrice, currency)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	exceeded, err := checkBudgets(ctx, tx, before)
+	if err != nil {
+		return nil, err
+	}
+	return exceeded, tx.Commit(ctx)
 }
 
 func (r *Repository) CreateDelivery(ctx context.Context, warehouseNo, receiptDocNo int, contractNo int, partCode, unit string, qty float64, receivedDate string) error {
@@ -292,26 +371,30 @@ func (r *Repository) CreateDelivery(ctx context.Context, warehouseNo, receiptDoc
 		INSERT INTO deliveries (warehouse_no, receipt_doc_no, contract_no, part_code, unit, qty, received_date)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`, warehouseNo, receiptDocNo, contractNo, partCode, unit, qty, receivedDate)
-	return err
+	return translateError(err)
 }
 
-func (r *Repository) CallContractSummary(ctx context.Context, contractNo int, partCode string) (*domain.ContractSummary, error) {
+func (r *Repository) CallContractSummary(ctx context.Context, contractNo int, partCode string, asOf *time.Time) (*domain.ContractSummary, error) {
 	var result domain.ContractSummary
 	result.ContractNo = contractNo
 	result.PartCode = partCode
 
 	escapedPartCode := fmt.Sprintf("'%s'", strings.ReplaceAll(partCode, "'", "''"))
+	asOfLiteral := "NULL"
+	if asOf != nil {
+		asOfLiteral = fmt.Sprintf("'%s'::date", asOf.Format("2006-01-02"))
+	}
 	query := fmt.Sprintf(`
 		DO $$
 		DECLARE
 			v_total_delivered DECIMAL(10,2);
 			v_contract_price DECIMAL(10,2);
 		BEGIN
-			CALL p_contract_summary(%d, %s, v_total_delivered, v_contract_price);
+			CALL p_contract_summary(%d, %s, %s, v_total_delivered, v_contract_price);
 			DELETE FROM proc_result;
 			INSERT INTO proc_result VALUES (v_total_delivered, v_contract_price);
 		END $$;
-	`, contractNo, escapedPartCode)
+	`, contractNo, escapedPartCode, asOfLiteral)
 	_, err := r.db.Exec(ctx, query)
 	if err != nil {
 		return nil, err
@@ -328,17 +411,72 @@ func (r *Repository) CallContractSummary(ctx context.Context, contractNo int, pa
 	return &result, nil
 }
 
-func (r *Repository) DeleteWarehouse(ctx context.Context, warehouseNo int) error {
-	_, err := r.db.Exec(ctx, "DELETE FROM warehouses WHERE warehouse_no = $1", warehouseNo)
-	return err
+// DeleteWarehouse moves a warehouse to the trash after checking confirmToken against
+// a fresh preview. With reassignTo > 0 its deliveries and issues are moved to that
+// warehouse first, otherwise the deliveries go to the trash together with the warehouse.
+func (r *Repository) DeleteWarehouse(ctx context.Context, warehouseNo int, confirmToken string, reassignTo int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	preview, err := previewWarehouseDelete(ctx, tx, warehouseNo, true)
+	if err != nil {
+		return err
+	}
+	if confirmToken != preview.Token {
+		return ErrStaleConfirmation
+	}
+
+	// The warehouse goes to the trash first: its stock no longer counts, so moving
+	// or trashing its deliveries is not held back by the issues made from them.
+	if _, err := tx.Exec(ctx, "UPDATE warehouses SET deleted_at = now() WHERE warehouse_no = $1", warehouseNo); err != nil {
+		return err
+	}
+
+	if reassignTo > 0 {
+		if err := reassignDeliveries(ctx, tx, warehouseNo, reassignTo); err != nil {
+			return err
+		}
+		if err := reassignIssues(ctx, tx, warehouseNo, reassignTo); err != nil {
+			return err
+		}
+		if err := reassignTransfers(ctx, tx, warehouseNo, reassignTo); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE deliveries SET deleted_at = now() WHERE warehouse_no = $1 AND deleted_at IS NULL", warehouseNo); err != nil {
+		return translateError(err)
+	}
+	return tx.Commit(ctx)
 }
 
+// DeleteContract moves a contract line to the trash. It refuses with ErrInUse while
+// live deliveries reference it.
 func (r *Repository) DeleteContract(ctx context.Context, contractNo int, partCode string) error {
-	_, err := r.db.Exec(ctx, "DELETE FROM contracts WHERE contract_no = $1 AND part_code = $2", contractNo, partCode)
-	return err
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	preview, err := previewContractDelete(ctx, tx, contractNo, partCode, true)
+	if err != nil {
+		return err
+	}
+	if len(preview.Deliveries) > 0 {
+		return fmt.Errorf("contract %s has %d deliveries: %w", preview.Key, len(preview.Deliveries), ErrInUse)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE contracts SET deleted_at = now() WHERE contract_no = $1 AND part_code = $2", contractNo, partCode); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *Repository) DeleteDelivery(ctx context.Context, warehouseNo int, receiptDocNo int) error {
-	_, err := r.db.Exec(ctx, "DELETE FROM deliveries WHERE warehouse_no = $1 AND receipt_doc_no = $2", warehouseNo, receiptDocNo)
-	return err
+	_, err := r.db.Exec(ctx, "UPDATE deliveries SET deleted_at = now() WHERE warehouse_no = $1 AND receipt_doc_no = $2 AND deleted_at IS NULL", warehouseNo, receiptDocNo)
+	return translateError(err)
 }
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetSchedule returns the tranches of one contract line in due date order.
+func (r *Repository) GetSchedule(ctx context.Context, contractNo int, partCode string) ([]domain.ScheduleTranche, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT contract_no, part_code, tranche_no, due_date, planned_qty
+		FROM contract_schedules
+		WHERE contract_no = $1 AND part_code = $2
+		ORDER BY due_date, tranche_no
+	`, contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tranches []domain.ScheduleTranche
+	for rows.Next() {
+		var t domain.ScheduleTranche
+		if err := rows.Scan(&t.ContractNo, &t.PartCode, &t.TrancheNo, &t.DueDate, &t.PlannedQty); err != nil {
+			return nil, err
+		}
+		tranches = append(tranches, t)
+	}
+	return tranches, nil
+}
+
+// SetSchedule replaces the schedule of a live contract line. The tranches must fall
+// between the line's start and end dates and add up to its plan_qty, otherwise
+// ErrInvalidState. An empty list removes the schedule. Commitment follows the
+// tranche months, so it returns the budgets the new schedule pushes over their
+// limit; a blocking one fails it with ErrBudgetExceeded.
+func (r *Repository) SetSchedule(ctx context.Context, contractNo int, partCode string, tranches []domain.ScheduleTranche) ([]domain.Budget, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockBudgets(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var line domain.Contract
+	err = tx.QueryRow(ctx, `
+		SELECT start_date, end_date, plan_qty
+		FROM contracts
+		WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, contractNo, partCode).Scan(&line.StartDate, &line.EndDate, &line.PlanQty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("contract %d/%s: %w", contractNo, partCode, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tranches) > 0 {
+		var total float64
+		for _, t := range tranches {
+			if t.DueDate.Before(line.StartDate) || t.DueDate.After(line.EndDate) {
+				return nil, fmt.Errorf("contract %d/%s: tranche due %s is outside %s..%s: %w", contractNo, partCode,
+					t.DueDate.Format("2006-01-02"), line.StartDate.Format("2006-01-02"), line.EndDate.Format("2006-01-02"), ErrInvalidState)
+			}
+			total += t.PlannedQty
+		}
+		if math.Abs(total-line.PlanQty) > 0.005 {
+			return nil, fmt.Errorf("contract %d/%s: tranches total %g, plan is %g: %w", contractNo, partCode, total, line.PlanQty, ErrInvalidState)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM contract_schedules WHERE contract_no = $1 AND part_code = $2", contractNo, partCode); err != nil {
+		return nil, err
+	}
+	for i, t := range tranches {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO contract_schedules (contract_no, part_code, tranche_no, due_date, planned_qty)
+			VALUES ($1, $2, $3, $4, $5)
+		`, contractNo, partCode, i+1, t.DueDate, t.PlannedQty)
+		if err != nil {
+			return nil, translateError(err)
+		}
+	}
+	exceeded, err := checkBudgets(ctx, tx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	return exceeded, tx.Commit(ctx)
+}
+
+// GetTrancheStatus matches deliveries received up to on against the schedules.
+// With problemsOnly it keeps the late and short tranches.
+func (r *Repository) GetTrancheStatus(ctx context.Context, on string, problemsOnly bool) ([]domain.TrancheStatus, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT contract_no, part_code, unit, tranche_no, due_date, planned_qty, matched_qty,
+			covered_date, status, days_late
+		FROM fn_tranche_status($1::date)
+		WHERE NOT $2 OR status IN ('late', 'short')
+		ORDER BY contract_no, part_code, due_date, tranche_no
+	`, on, problemsOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tranches []domain.TrancheStatus
+	for rows.Next() {
+		var t domain.TrancheStatus
+		err := rows.Scan(
+			&t.ContractNo,
+			&t.PartCode,
+			&t.Unit,
+			&t.TrancheNo,
+			&t.DueDate,
+			&t.PlannedQty,
+			&t.MatchedQty,
+			&t.CoveredDate,
+			&t.Status,
+			&t.DaysLate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tranches = append(tranches, t)
+	}
+	return tranches, nil
+}
+
+// GetSchedulePriorities ranks the contract lines still owed deliveries as of on,
+// overdue tranches first.
+func (r *Repository) GetSchedulePriorities(ctx context.Context, on string) ([]domain.SchedulePriority, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT contract_no, part_code, unit, plan_qty, end_date, next_due_date,
+			overdue_qty, outstanding_qty, priority
+		FROM fn_schedule_priority($1::date)
+	`, on)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var priorities []domain.SchedulePriority
+	for rows.Next() {
+		var p domain.SchedulePriority
+		err := rows.Scan(
+			&p.ContractNo,
+			&p.PartCode,
+			&p.Unit,
+			&p.PlanQty,
+			&p.EndDate,
+			&p.NextDueDate,
+			&p.OverdueQty,
+			&p.OutstandingQty,
+			&p.Priority,
+		)
+		if err != nil {
+			return nil, err
+		}
+		priorities = append(priorities, p)
+	}
+	return priorities, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetSupplierScorecard ranks suppliers on their contract lines due between from
+// and to (both inclusive, empty means unbounded).
+func (r *Repository) GetSupplierScorecard(ctx context.Context, from, to string) ([]domain.SupplierScore, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT supplier_id, supplier_name, lines, deliveries, on_time_rate, fill_rate,
+			partial_deliveries, reject_rate, score, rank
+		FROM fn_supplier_scorecard(NULLIF($1, '')::date, NULLIF($2, '')::date)
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scorecard []domain.SupplierScore
+	for rows.Next() {
+		var s domain.SupplierScore
+		err := rows.Scan(
+			&s.SupplierID,
+			&s.SupplierName,
+			&s.Lines,
+			&s.Deliveries,
+			&s.OnTimeRate,
+			&s.FillRate,
+			&s.PartialDeliveries,
+			&s.RejectRate,
+			&s.Score,
+			&s.Rank,
+		)
+		if err != nil {
+			return nil, err
+		}
+		scorecard = append(scorecard, s)
+	}
+	return scorecard, nil
+}
+
+// GetSupplierLinePerformance returns the contract lines of one supplier behind
+// its scorecard row.
+func (r *Repository) GetSupplierLinePerformance(ctx context.Context, supplierID int, from, to string) ([]domain.SupplierLinePerformance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT contract_no, part_code, unit, end_date, plan_qty, delivered_qty,
+			rejected_qty, returned_qty, deliveries, due_tranches, on_time_tranches, partial_deliveries, last_received_date
+		FROM fn_supplier_line_performance(NULLIF($2, '')::date, NULLIF($3, '')::date)
+		WHERE supplier_id = $1
+		ORDER BY end_date, contract_no, part_code
+	`, supplierID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []domain.SupplierLinePerformance
+	for rows.Next() {
+		var l domain.SupplierLinePerformance
+		err := rows.Scan(
+			&l.ContractNo,
+			&l.PartCode,
+			&l.Unit,
+			&l.EndDate,
+			&l.PlanQty,
+			&l.DeliveredQty,
+			&l.RejectedQty,
+			&l.ReturnedQty,
+			&l.Deliveries,
+			&l.DueTranches,
+			&l.OnTimeTranches,
+			&l.PartialDeliveries,
+			&l.LastReceivedDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
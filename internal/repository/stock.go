@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+// GetStockBalance returns stock_balance rows, only for warehouseNo when it is not 0.
+func (r *Repository) GetStockBalance(ctx context.Context, warehouseNo int) ([]domain.StockBalance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT warehouse_no, manager_surname, part_code, unit, received_qty, issued_qty, balance_qty
+		FROM stock_balance
+		WHERE $1 = 0 OR warehouse_no = $1
+	`, warehouseNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balance []domain.StockBalance
+	for rows.Next() {
+		var b domain.StockBalance
+		err := rows.Scan(
+			&b.WarehouseNo,
+			&b.ManagerSurname,
+			&b.PartCode,
+			&b.Unit,
+			&b.ReceivedQty,
+			&b.IssuedQty,
+			&b.BalanceQty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		balance = append(balance, b)
+	}
+	return balance, nil
+}
+
+// GetIssues returns issue documents, only for warehouseNo when it is not 0.
+func (r *Repository) GetIssues(ctx context.Context, warehouseNo int) ([]domain.Issue, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT warehouse_no, issue_doc_no, part_code, unit, qty, issued_date, recipient,
+			origin_warehouse_no, origin_receipt_doc_no, lot_no
+		FROM issues
+		WHERE $1 = 0 OR warehouse_no = $1
+		ORDER BY issued_date DESC, warehouse_no, issue_doc_no
+	`, warehouseNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []domain.Issue
+	for rows.Next() {
+		var i domain.Issue
+		err := rows.Scan(
+			&i.WarehouseNo,
+			&i.IssueDocNo,
+			&i.PartCode,
+			&i.Unit,
+			&i.Qty,
+			&i.IssuedDate,
+			&i.Recipient,
+			&i.OriginWarehouseNo,
+			&i.OriginReceiptDocNo,
+			&i.LotNo,
+		)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, i)
+	}
+	return issues, nil
+}
+
+// CreateIssue records an issue document. trg_check_issue_stock refuses it with
+// ErrInsufficientStock when the balance, or the balance of the given lot, does not
+// cover qty.
+func (r *Repository) CreateIssue(ctx context.Context, i domain.Issue) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO issues (warehouse_no, issue_doc_no, part_code, unit, qty, issued_date, recipient,
+			origin_warehouse_no, origin_receipt_doc_no, lot_no)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, i.WarehouseNo, i.IssueDocNo, i.PartCode, i.Unit, i.Qty, i.IssuedDate, i.Recipient,
+		i.OriginWarehouseNo, i.OriginReceiptDocNo, i.LotNo)
+	return translateError(err)
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+const stockCountColumns = `count_no, warehouse_no, manager_surname, count_date, status, created_at, approved_by, approved_at`
+
+func scanStockCount(row pgx.Row) (domain.StockCount, error) {
+	var sc domain.StockCount
+	err := row.Scan(
+		&sc.CountNo,
+		&sc.WarehouseNo,
+		&sc.ManagerSurname,
+		&sc.CountDate,
+		&sc.Status,
+		&sc.CreatedAt,
+		&sc.ApprovedBy,
+		&sc.ApprovedAt,
+	)
+	return sc, err
+}
+
+// GetStockCounts returns count sheet headers, newest first.
+func (r *Repository) GetStockCounts(ctx context.Context) ([]domain.StockCount, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+stockCountColumns+` FROM stock_counts ORDER BY count_no DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []domain.StockCount
+	for rows.Next() {
+		sc, err := scanStockCount(rows)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, sc)
+	}
+	return counts, nil
+}
+
+// GetStockCount returns a count sheet with its lines.
+func (r *Repository) GetStockCount(ctx context.Context, countNo int) (*domain.StockCount, error) {
+	sc, err := scanStockCount(r.db.QueryRow(ctx, `SELECT `+stockCountColumns+` FROM stock_counts WHERE count_no = $1`, countNo))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("stock count %d: %w", countNo, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT part_code, unit, expected_qty, counted_qty, counted_qty - expected_qty
+		FROM stock_count_lines
+		WHERE count_no = $1
+		ORDER BY part_code, unit
+	`, countNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l domain.StockCountLine
+		if err := rows.Scan(&l.PartCode, &l.Unit, &l.ExpectedQty, &l.CountedQty, &l.VarianceQty); err != nil {
+			return nil, err
+		}
+		sc.Lines = append(sc.Lines, l)
+	}
+	return &sc, nil
+}
+
+// CreateStockCount generates a count sheet for a warehouse with one line per part
+// that has a non-zero ledger balance on countDate, and returns its number.
+func (r *Repository) CreateStockCount(ctx context.Context, warehouseNo int, countDate string) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var countNo int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO stock_counts (warehouse_no, manager_surname, count_date)
+		SELECT warehouse_no, manager_surname, $2
+		FROM warehouses
+		WHERE warehouse_no = $1 AND deleted_at IS NULL
+		RETURNING count_no
+	`, warehouseNo, countDate).Scan(&countNo)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("warehouse %d: %w", warehouseNo, ErrNotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// A reversing entry carries the date of the entry it cancels, so the plain sum
+	// up to countDate already nets reversal pairs out.
+	_, err = tx.Exec(ctx, `
+		INSERT INTO stock_count_lines (count_no, part_code, unit, expected_qty)
+		SELECT $1, part_code, unit, SUM(qty)
+		FROM stock_ledger
+		WHERE warehouse_no = $2 AND movement_date <= $3
+		GROUP BY part_code, unit
+		HAVING SUM(qty) <> 0
+	`, countNo, warehouseNo, countDate)
+	if err != nil {
+		return 0, err
+	}
+
+	return countNo, tx.Commit(ctx)
+}
+
+// SetCountedQty records the counted quantity of a part on a draft sheet. A part
+// that was not expected is added to the sheet with expected quantity 0.
+func (r *Repository) SetCountedQty(ctx context.Context, countNo int, partCode, unit string, countedQty float64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockDraftStockCount(ctx, tx, countNo); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO stock_count_lines (count_no, part_code, unit, expected_qty, counted_qty)
+		VALUES ($1, $2, $3, 0, $4)
+		ON CONFLICT (count_no, part_code, unit) DO UPDATE SET counted_qty = EXCLUDED.counted_qty
+	`, countNo, partCode, unit, countedQty)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ApproveStockCount approves a fully counted sheet and posts an adjustment to the
+// ledger for every line whose counted quantity differs from the expected one.
+func (r *Repository) ApproveStockCount(ctx context.Context, countNo int, approvedBy string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sc, err := lockDraftStockCount(ctx, tx, countNo)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT part_code, unit, expected_qty, counted_qty
+		FROM stock_count_lines
+		WHERE count_no = $1
+		ORDER BY part_code, unit
+	`, countNo)
+	if err != nil {
+		return err
+	}
+	var lines []domain.StockCountLine
+	for rows.Next() {
+		var l domain.StockCountLine
+		if err := rows.Scan(&l.PartCode, &l.Unit, &l.ExpectedQty, &l.CountedQty); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		if l.CountedQty == nil {
+			return fmt.Errorf("stock count %d: %s %s is not counted yet: %w", countNo, l.PartCode, l.Unit, ErrInvalidState)
+		}
+	}
+
+	sourceRef := fmt.Sprintf("count/%d", countNo)
+	comment := fmt.Sprintf("stock count %d", countNo)
+	countDate := sc.CountDate.Format("2006-01-02")
+	for _, l := range lines {
+		variance := math.Round((*l.CountedQty-l.ExpectedQty)*100) / 100
+		if variance == 0 {
+			continue
+		}
+		if _, err := postAdjustment(ctx, tx, sc.WarehouseNo, l.PartCode, l.Unit, variance, countDate, sourceRef, comment); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE stock_counts
+		SET status = 'approved', approved_by = $2, approved_at = now()
+		WHERE count_no = $1
+	`, countNo, approvedBy)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockDraftStockCount locks a count sheet header and checks it is still a draft.
+func lockDraftStockCount(ctx context.Context, q querier, countNo int) (domain.StockCount, error) {
+	sc, err := scanStockCount(q.QueryRow(ctx, `SELECT `+stockCountColumns+` FROM stock_counts WHERE count_no = $1 FOR UPDATE`, countNo))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sc, fmt.Errorf("stock count %d: %w", countNo, ErrNotFound)
+	}
+	if err != nil {
+		return sc, err
+	}
+	if sc.Status != domain.StockCountDraft {
+		return sc, fmt.Errorf("stock count %d is %s, expected %s: %w", countNo, sc.Status, domain.StockCountDraft, ErrInvalidState)
+	}
+	return sc, nil
+}
+
+// GetStockCountVariances returns the variances of approved counts, only for
+// managerSurname when it is not empty.
+func (r *Repository) GetStockCountVariances(ctx context.Context, managerSurname string) ([]domain.StockCountVariance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT count_no, warehouse_no, manager_surname, count_date, approved_by,
+			part_code, unit, expected_qty, counted_qty, variance_qty
+		FROM stock_count_variances
+		WHERE $1 = '' OR manager_surname = $1
+		ORDER BY count_date DESC, count_no DESC, part_code, unit
+	`, managerSurname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variances []domain.StockCountVariance
+	for rows.Next() {
+		var v domain.StockCountVariance
+		err := rows.Scan(
+			&v.CountNo,
+			&v.WarehouseNo,
+			&v.ManagerSurname,
+			&v.CountDate,
+			&v.ApprovedBy,
+			&v.PartCode,
+			&v.Unit,
+			&v.ExpectedQty,
+			&v.CountedQty,
+			&v.VarianceQty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		variances = append(variances, v)
+	}
+	return variances, nil
+}
+
+// GetManagerVariances sums up approved counts per manager surname.
+func (r *Repository) GetManagerVariances(ctx context.Context) ([]domain.ManagerVariance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.manager_surname,
+			COUNT(DISTINCT c.count_no),
+			COUNT(v.count_no),
+			COALESCE(-SUM(v.variance_qty) FILTER (WHERE v.variance_qty < 0), 0),
+			COALESCE(SUM(v.variance_qty) FILTER (WHERE v.variance_qty > 0), 0)
+		FROM stock_counts c
+		LEFT JOIN stock_count_variances v ON v.count_no = c.count_no
+		WHERE c.status = 'approved'
+		GROUP BY c.manager_surname
+		ORDER BY c.manager_surname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var managers []domain.ManagerVariance
+	for rows.Next() {
+		var m domain.ManagerVariance
+		if err := rows.Scan(&m.ManagerSurname, &m.Counts, &m.VarianceLines, &m.ShortageQty, &m.SurplusQty); err != nil {
+			return nil, err
+		}
+		managers = append(managers, m)
+	}
+	return managers, nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetSuppliers(ctx context.Context) ([]domain.Supplier, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT supplier_id, name, inn, address, contact_person, phone, email
+		FROM suppliers
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []domain.Supplier
+	for rows.Next() {
+		var s domain.Supplier
+		if err := rows.Scan(&s.SupplierID, &s.Name, &s.INN, &s.Address, &s.ContactPerson, &s.Phone, &s.Email); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, nil
+}
+
+func (r *Repository) GetSupplier(ctx context.Context, supplierID int) (*domain.Supplier, error) {
+	var s domain.Supplier
+	err := r.db.QueryRow(ctx, `
+		SELECT supplier_id, name, inn, address, contact_person, phone, email
+		FROM suppliers
+		WHERE supplier_id = $1
+	`, supplierID).Scan(&s.SupplierID, &s.Name, &s.INN, &s.Address, &s.ContactPerson, &s.Phone, &s.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("supplier %d: %w", supplierID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *Repository) CreateSupplier(ctx context.Context, s domain.Supplier) (int, error) {
+	var supplierID int
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO suppliers (name, inn, address, contact_person, phone, email)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING supplier_id
+	`, s.Name, s.INN, s.Address, s.ContactPerson, s.Phone, s.Email).Scan(&supplierID)
+	return supplierID, translateError(err)
+}
+
+func (r *Repository) UpdateSupplier(ctx context.Context, s domain.Supplier) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE suppliers
+		SET name = $2, inn = $3, address = $4, contact_person = $5, phone = $6, email = $7
+		WHERE supplier_id = $1
+	`, s.SupplierID, s.Name, s.INN, s.Address, s.ContactPerson, s.Phone, s.Email)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("supplier %d: %w", s.SupplierID, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteSupplier removes a supplier that has no contracts; otherwise it returns ErrInUse.
+func (r *Repository) DeleteSupplier(ctx context.Context, supplierID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM suppliers WHERE supplier_id = $1", supplierID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return fmt.Errorf("supplier %d: %w: %s", supplierID, ErrInUse, pgErr.Detail)
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("supplier %d: %w", supplierID, ErrNotFound)
+	}
+	return nil
+}
+
+// GetContractHeaders returns contract headers with the number of live lines.
+func (r *Repository) GetContractHeaders(ctx context.Context) ([]domain.ContractHeader, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT h.contract_no, h.supplier_id, s.name, h.signed_date, h.status,
+			(SELECT COUNT(*) FROM contracts c WHERE c.contract_no = h.contract_no AND c.deleted_at IS NULL)
+		FROM contract_headers h
+		JOIN suppliers s ON s.supplier_id = h.supplier_id
+		ORDER BY h.contract_no
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var headers []domain.ContractHeader
+	for rows.Next() {
+		var h domain.ContractHeader
+		if err := rows.Scan(&h.ContractNo, &h.SupplierID, &h.SupplierName, &h.SignedDate, &h.Status, &h.Lines); err != nil {
+			return nil, err
+		}
+		headers = append(headers, h)
+	}
+	return headers, nil
+}
+
+func (r *Repository) CreateContractHeader(ctx context.Context, contractNo, supplierID int, signedDate, status string) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO contract_headers (contract_no, supplier_id, signed_date, status)
+		VALUES ($1, $2, $3, $4)
+	`, contractNo, supplierID, signedDate, status)
+	return translateError(err)
+}
+
+func (r *Repository) UpdateContractHeader(ctx context.Context, contractNo, supplierID int, signedDate, status string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE contract_headers
+		SET supplier_id = $2, signed_date = $3, status = $4
+		WHERE contract_no = $1
+	`, contractNo, supplierID, signedDate, status)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("contract %d: %w", contractNo, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteContractHeader removes a header without lines, trashed lines included;
+// otherwise it returns ErrInUse.
+func (r *Repository) DeleteContractHeader(ctx context.Context, contractNo int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM contract_headers WHERE contract_no = $1", contractNo)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return fmt.Errorf("contract %d: %w: %s", contractNo, ErrInUse, pgErr.Detail)
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("contract %d: %w", contractNo, ErrNotFound)
+	}
+	return nil
+}
+
+// GetDeliveriesBySupplier sums up live deliveries received between from and to
+// (both inclusive, empty means unbounded) per supplier, part and contract currency.
+// The base currency value converts each delivery at the rate of its received_date.
+func (r *Repository) GetDeliveriesBySupplier(ctx context.Context, from, to string) ([]domain.SupplierDeliveries, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT s.supplier_id, s.name, s.inn, d.part_code, d.unit,
+			COUNT(*), SUM(d.qty), c.currency, SUM(d.qty * v.contract_price),
+			CASE WHEN bool_and(x.rate IS NOT NULL) THEN round(SUM(d.qty * v.contract_price * x.rate), 2) END
+		FROM deliveries d
+		JOIN contracts c ON c.contract_no = d.contract_no AND c.part_code = d.part_code
+		JOIN contract_headers h ON h.contract_no = d.contract_no
+		JOIN suppliers s ON s.supplier_id = h.supplier_id
+		CROSS JOIN LATERAL fn_contract_version_at(d.contract_no, d.part_code, d.received_date) v
+		CROSS JOIN LATERAL (SELECT fn_exchange_rate(c.currency, d.received_date) AS rate) x
+		WHERE d.deleted_at IS NULL
+			AND (NULLIF($1, '')::date IS NULL OR d.received_date >= NULLIF($1, '')::date)
+			AND (NULLIF($2, '')::date IS NULL OR d.received_date <= NULLIF($2, '')::date)
+		GROUP BY s.supplier_id, s.name, s.inn, d.part_code, d.unit, c.currency
+		ORDER BY s.name, d.part_code, d.unit, c.currency
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []domain.SupplierDeliveries
+	for rows.Next() {
+		var sd domain.SupplierDeliveries
+		err := rows.Scan(
+			&sd.SupplierID,
+			&sd.SupplierName,
+			&sd.INN,
+			&sd.PartCode,
+			&sd.Unit,
+			&sd.Deliveries,
+			&sd.Qty,
+			&sd.Currency,
+			&sd.Value,
+			&sd.BaseValue,
+		)
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, sd)
+	}
+	return report, nil
+}
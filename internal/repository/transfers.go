@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetTransfers(ctx context.Context) ([]domain.Transfer, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT t.transfer_no, t.from_warehouse_no, t.to_warehouse_no, t.status, t.shipped_date, t.received_date,
+			l.line_no, l.part_code, l.unit, l.qty, l.origin_warehouse_no, l.origin_receipt_doc_no, l.lot_no
+		FROM transfers t
+		JOIN transfer_lines l ON l.transfer_no = t.transfer_no
+		ORDER BY t.transfer_no DESC, l.line_no
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []domain.Transfer
+	for rows.Next() {
+		var t domain.Transfer
+		var l domain.TransferLine
+		err := rows.Scan(
+			&t.TransferNo,
+			&t.FromWarehouseNo,
+			&t.ToWarehouseNo,
+			&t.Status,
+			&t.ShippedDate,
+			&t.ReceivedDate,
+			&l.LineNo,
+			&l.PartCode,
+			&l.Unit,
+			&l.Qty,
+			&l.OriginWarehouseNo,
+			&l.OriginReceiptDocNo,
+			&l.LotNo,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(transfers); n > 0 && transfers[n-1].TransferNo == t.TransferNo {
+			transfers[n-1].Lines = append(transfers[n-1].Lines, l)
+			continue
+		}
+		t.Lines = []domain.TransferLine{l}
+		transfers = append(transfers, t)
+	}
+	return transfers, nil
+}
+
+// CreateTransfer ships a transfer with all its lines in one transaction. Each line is
+// checked against the source balance by trg_check_transfer_stock, so either the whole
+// transfer is shipped or nothing is.
+func (r *Repository) CreateTransfer(ctx context.Context, fromWarehouseNo, toWarehouseNo int, shippedDate string, lines []domain.TransferLine) (int, error) {
+	if len(lines) == 0 {
+		return 0, errors.New("transfer has no lines")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, warehouseNo := range []int{fromWarehouseNo, toWarehouseNo} {
+		var exists int
+		err := tx.QueryRow(ctx, "SELECT warehouse_no FROM warehouses WHERE warehouse_no = $1 AND deleted_at IS NULL FOR SHARE", warehouseNo).Scan(&exists)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("warehouse %d: %w", warehouseNo, ErrReferenceMissing)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var transferNo int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transfers (from_warehouse_no, to_warehouse_no, shipped_date)
+		VALUES ($1, $2, $3)
+		RETURNING transfer_no
+	`, fromWarehouseNo, toWarehouseNo, shippedDate).Scan(&transferNo)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	for i, l := range lines {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO transfer_lines (transfer_no, line_no, part_code, unit, qty, origin_warehouse_no, origin_receipt_doc_no, lot_no)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, transferNo, i+1, l.PartCode, l.Unit, l.Qty, l.OriginWarehouseNo, l.OriginReceiptDocNo, l.LotNo)
+		if err != nil {
+			return 0, translateError(err)
+		}
+	}
+
+	return transferNo, tx.Commit(ctx)
+}
+
+func (r *Repository) ReceiveTransfer(ctx context.Context, transferNo int, receivedDate string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE transfers
+		SET status = 'received', received_date = $2
+		WHERE transfer_no = $1 AND status = 'shipped'
+	`, transferNo, receivedDate)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var status string
+	err = r.db.QueryRow(ctx, "SELECT status FROM transfers WHERE transfer_no = $1", transferNo).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("transfer %d: %w", transferNo, ErrNotFound)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+	return fmt.Errorf("transfer %d is %s: %w", transferNo, status, ErrInvalidState)
+}
+
+// GetStockMovements returns the movement history, only for warehouseNo when it is not 0.
+func (r *Repository) GetStockMovements(ctx context.Context, warehouseNo int) ([]domain.StockMovement, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT warehouse_no, movement_date, movement_type, doc_no, part_code, unit, qty,
+			contract_no, origin_warehouse_no, origin_receipt_doc_no
+		FROM stock_movements
+		WHERE $1 = 0 OR warehouse_no = $1
+		ORDER BY movement_date, warehouse_no, movement_type, doc_no
+	`, warehouseNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []domain.StockMovement
+	for rows.Next() {
+		var m domain.StockMovement
+		err := rows.Scan(
+			&m.WarehouseNo,
+			&m.MovementDate,
+			&m.MovementType,
+			&m.DocNo,
+			&m.PartCode,
+			&m.Unit,
+			&m.Qty,
+			&m.ContractNo,
+			&m.OriginWarehouseNo,
+			&m.OriginReceiptDocNo,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
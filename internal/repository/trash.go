@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetTrash(ctx context.Context) (*domain.Trash, error) {
+	var trash domain.Trash
+
+	rows, err := r.db.Query(ctx, "SELECT warehouse_no, manager_surname, deleted_at FROM warehouses WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var w domain.Warehouse
+		if err := rows.Scan(&w.WarehouseNo, &w.ManagerSurname, &w.DeletedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		trash.Warehouses = append(trash.Warehouses, w)
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(ctx, "SELECT contract_no, part_code, unit, start_date, end_date, plan_qty, contract_price, deleted_at FROM contracts WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c domain.Contract
+		if err := rows.Scan(&c.ContractNo, &c.PartCode, &c.Unit, &c.StartDate, &c.EndDate, &c.PlanQty, &c.ContractPrice, &c.DeletedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		trash.Contracts = append(trash.Contracts, c)
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(ctx, "SELECT warehouse_no, receipt_doc_no, contract_no, part_code, unit, qty, received_date, deleted_at FROM deliveries WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d domain.Delivery
+		if err := rows.Scan(&d.WarehouseNo, &d.ReceiptDocNo, &d.ContractNo, &d.PartCode, &d.Unit, &d.Qty, &d.ReceivedDate, &d.DeletedAt); err != nil {
+			return nil, err
+		}
+		trash.Deliveries = append(trash.Deliveries, d)
+	}
+
+	return &trash, nil
+}
+
+// RestoreWarehouse takes a warehouse out of the trash together with the deliveries
+// that were trashed along with it.
+func (r *Repository) RestoreWarehouse(ctx context.Context, warehouseNo int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, "SELECT deleted_at FROM warehouses WHERE warehouse_no = $1 AND deleted_at IS NOT NULL FOR UPDATE", warehouseNo).Scan(&deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("warehouse %d in trash: %w", warehouseNo, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE warehouses SET deleted_at = NULL WHERE warehouse_no = $1", warehouseNo); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE deliveries SET deleted_at = NULL WHERE warehouse_no = $1 AND deleted_at = $2", warehouseNo, deletedAt); err != nil {
+		return translateError(err)
+	}
+	return tx.Commit(ctx)
+}
+
+// RestoreContract takes a contract line out of the trash. Its commitment counts
+// again, so it returns the budgets the line pushes over their limit; a blocking
+// one fails the restore with ErrBudgetExceeded.
+func (r *Repository) RestoreContract(ctx context.Context, contractNo int, partCode string) ([]domain.Budget, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockBudgets(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := tx.Exec(ctx, "UPDATE contracts SET deleted_at = NULL WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NOT NULL", contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("contract %d/%s in trash: %w", contractNo, partCode, ErrNotFound)
+	}
+	exceeded, err := checkBudgets(ctx, tx, before)
+	if err != nil {
+		return nil, err
+	}
+	return exceeded, tx.Commit(ctx)
+}
+
+// RestoreDelivery takes a delivery out of the trash. trg_check_live_references refuses
+// it while its warehouse or contract is still in the trash.
+func (r *Repository) RestoreDelivery(ctx context.Context, warehouseNo, receiptDocNo int) error {
+	tag, err := r.db.Exec(ctx, "UPDATE deliveries SET deleted_at = NULL WHERE warehouse_no = $1 AND receipt_doc_no = $2 AND deleted_at IS NOT NULL", warehouseNo, receiptDocNo)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delivery %d/%d in trash: %w", warehouseNo, receiptDocNo, ErrNotFound)
+	}
+	return nil
+}
+
+// PurgeWarehouse physically deletes a trashed warehouse; its deliveries go with it
+// through ON DELETE CASCADE. Transfers are kept as stock history, so a warehouse
+// that was ever part of a transfer cannot be purged (ErrInUse).
+func (r *Repository) PurgeWarehouse(ctx context.Context, warehouseNo int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists int
+	err = tx.QueryRow(ctx, "SELECT warehouse_no FROM warehouses WHERE warehouse_no = $1 AND deleted_at IS NOT NULL FOR UPDATE", warehouseNo).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("warehouse %d in trash: %w", warehouseNo, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	var transfers int
+	err = tx.QueryRow(ctx, "SELECT count(*) FROM transfers WHERE from_warehouse_no = $1 OR to_warehouse_no = $1", warehouseNo).Scan(&transfers)
+	if err != nil {
+		return err
+	}
+	if transfers > 0 {
+		return fmt.Errorf("warehouse %d has %d transfers: %w", warehouseNo, transfers, ErrInUse)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM warehouses WHERE warehouse_no = $1", warehouseNo); err != nil {
+		return translateError(err)
+	}
+	return tx.Commit(ctx)
+}
+
+// PurgeContract physically deletes a trashed contract line and the trashed deliveries referencing it.
+func (r *Repository) PurgeContract(ctx context.Context, contractNo int, partCode string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM deliveries WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NOT NULL", contractNo, partCode); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM contracts WHERE contract_no = $1 AND part_code = $2 AND deleted_at IS NOT NULL", contractNo, partCode)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("contract %d/%s in trash: %w", contractNo, partCode, ErrNotFound)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) PurgeDelivery(ctx context.Context, warehouseNo, receiptDocNo int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM deliveries WHERE warehouse_no = $1 AND receipt_doc_no = $2 AND deleted_at IS NOT NULL", warehouseNo, receiptDocNo)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delivery %d/%d in trash: %w", warehouseNo, receiptDocNo, ErrNotFound)
+	}
+	return nil
+}
+
+// PurgeExpiredTrash physically deletes everything that has been in the trash for
+// more than days days and returns the number of purged rows. Contracts that still
+// have deliveries and warehouses that took part in transfers are kept.
+func (r *Repository) PurgeExpiredTrash(ctx context.Context, days int) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var purged int64
+	for _, sql := range []string{
+		"DELETE FROM deliveries WHERE deleted_at < now() - make_interval(days => $1)",
+		`DELETE FROM contracts c WHERE c.deleted_at < now() - make_interval(days => $1)
+			AND NOT EXISTS (SELECT 1 FROM deliveries d WHERE d.contract_no = c.contract_no AND d.part_code = c.part_code)`,
+		`DELETE FROM warehouses w WHERE w.deleted_at < now() - make_interval(days => $1)
+			AND NOT EXISTS (SELECT 1 FROM transfers t WHERE w.warehouse_no IN (t.from_warehouse_no, t.to_warehouse_no))`,
+	} {
+		tag, err := tx.Exec(ctx, sql, days)
+		if err != nil {
+			return 0, err
+		}
+		purged += tag.RowsAffected()
+	}
+
+	return purged, tx.Commit(ctx)
+}
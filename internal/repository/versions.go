@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/railgorail/kpfu-db-app/internal/domain"
+)
+
+func (r *Repository) GetContractHistory(ctx context.Context, contractNo int, partCode string) ([]domain.ContractVersion, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT contract_no, part_code, valid_from, valid_to, unit, start_date, end_date, plan_qty, contract_price
+		FROM contract_versions
+		WHERE contract_no = $1 AND part_code = $2
+		ORDER BY valid_from
+	`, contractNo, partCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []domain.ContractVersion
+	for rows.Next() {
+		var v domain.ContractVersion
+		err := rows.Scan(
+			&v.ContractNo,
+			&v.PartCode,
+			&v.ValidFrom,
+			&v.ValidTo,
+			&v.Unit,
+			&v.StartDate,
+			&v.EndDate,
+			&v.PlanQty,
+			&v.ContractPrice,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, v)
+	}
+	return history, nil
+}
@@ -0,0 +1,132 @@
+// charts.js - minimal SVG line and bar charts for the dashboard.
+//
+//   Charts.line(element, {labels: [...], series: [{name, values: [...]}, ...]})
+//   Charts.bar(element, {labels: [...], values: [...]})
+//
+// The chart fills the width of element; the height comes from options.height.
+(function (global) {
+    'use strict';
+
+    var SVG = 'http://www.w3.org/2000/svg';
+    var COLORS = ['#007bff', '#28a745', '#dc3545', '#ffc107', '#17a2b8', '#6f42c1', '#fd7e14', '#6c757d'];
+    var PAD = {top: 10, right: 10, bottom: 40, left: 60};
+
+    function el(name, attrs, text) {
+        var node = document.createElementNS(SVG, name);
+        for (var k in attrs) {
+            node.setAttribute(k, attrs[k]);
+        }
+        if (text !== undefined) {
+            node.textContent = text;
+        }
+        return node;
+    }
+
+    // niceMax rounds max up to 1, 2 or 5 times a power of ten.
+    function niceMax(max) {
+        if (max <= 0) {
+            return 1;
+        }
+        var p = Math.pow(10, Math.floor(Math.log10(max)));
+        var steps = [1, 2, 5, 10];
+        for (var i = 0; i < steps.length; i++) {
+            if (steps[i] * p >= max) {
+                return steps[i] * p;
+            }
+        }
+        return 10 * p;
+    }
+
+    function format(v) {
+        return Math.abs(v) >= 1000 ? v.toLocaleString() : String(Math.round(v * 100) / 100);
+    }
+
+    // frame draws the axes and grid and returns the plot geometry.
+    function frame(element, labels, max, options) {
+        var width = element.clientWidth || 600;
+        var height = (options && options.height) || 260;
+        var svg = el('svg', {width: width, height: height, 'font-size': 11, 'font-family': 'sans-serif'});
+        var plot = {
+            svg: svg,
+            x: PAD.left,
+            y: PAD.top,
+            w: width - PAD.left - PAD.right,
+            h: height - PAD.top - PAD.bottom,
+            max: niceMax(max)
+        };
+
+        for (var i = 0; i <= 4; i++) {
+            var v = plot.max * i / 4;
+            var y = plot.y + plot.h - plot.h * i / 4;
+            svg.appendChild(el('line', {x1: plot.x, x2: plot.x + plot.w, y1: y, y2: y, stroke: '#e9ecef'}));
+            svg.appendChild(el('text', {x: plot.x - 6, y: y + 4, 'text-anchor': 'end', fill: '#6c757d'}, format(v)));
+        }
+        svg.appendChild(el('line', {x1: plot.x, x2: plot.x + plot.w, y1: plot.y + plot.h, y2: plot.y + plot.h, stroke: '#6c757d'}));
+
+        var every = Math.max(1, Math.ceil(labels.length / Math.max(1, Math.floor(plot.w / 70))));
+        plot.step = plot.w / Math.max(1, labels.length);
+        for (var j = 0; j < labels.length; j += every) {
+            svg.appendChild(el('text', {
+                x: plot.x + plot.step * (j + 0.5),
+                y: plot.y + plot.h + 16,
+                'text-anchor': 'middle',
+                fill: '#6c757d'
+            }, labels[j]));
+        }
+
+        element.innerHTML = '';
+        element.appendChild(svg);
+        return plot;
+    }
+
+    function scaleY(plot, v) {
+        return plot.y + plot.h - plot.h * v / plot.max;
+    }
+
+    function line(element, data, options) {
+        var max = 0;
+        data.series.forEach(function (s) {
+            s.values.forEach(function (v) {
+                max = Math.max(max, v);
+            });
+        });
+        var plot = frame(element, data.labels, max, options);
+
+        data.series.forEach(function (s, i) {
+            var color = COLORS[i % COLORS.length];
+            var points = s.values.map(function (v, j) {
+                return (plot.x + plot.step * (j + 0.5)) + ',' + scaleY(plot, v);
+            });
+            plot.svg.appendChild(el('polyline', {points: points.join(' '), fill: 'none', stroke: color, 'stroke-width': 2}));
+            s.values.forEach(function (v, j) {
+                var dot = el('circle', {cx: plot.x + plot.step * (j + 0.5), cy: scaleY(plot, v), r: 3, fill: color});
+                dot.appendChild(el('title', {}, s.name + ' ' + data.labels[j] + ': ' + format(v)));
+                plot.svg.appendChild(dot);
+            });
+            var lx = plot.x + 10 + i * 90;
+            plot.svg.appendChild(el('rect', {x: lx, y: plot.y + plot.h + 26, width: 10, height: 10, fill: color}));
+            plot.svg.appendChild(el('text', {x: lx + 14, y: plot.y + plot.h + 35}, s.name));
+        });
+    }
+
+    function bar(element, data, options) {
+        var max = Math.max.apply(null, [0].concat(data.values));
+        var plot = frame(element, data.labels, max, options);
+        var color = (options && options.color) || COLORS[0];
+
+        data.values.forEach(function (v, j) {
+            var y = scaleY(plot, v);
+            var rect = el('rect', {
+                x: plot.x + plot.step * j + plot.step * 0.15,
+                y: y,
+                width: plot.step * 0.7,
+                height: plot.y + plot.h - y,
+                fill: color
+            });
+            rect.appendChild(el('title', {}, data.labels[j] + ': ' + format(v)));
+            plot.svg.appendChild(rect);
+        });
+    }
+
+    global.Charts = {line: line, bar: bar};
+})(window);